@@ -0,0 +1,118 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bkp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// Version of the backup document written by this pack
+const Version = 1
+
+type Backup struct {
+	Version int    `json:"version"`
+	Rooms   []Room `json:"rooms"`
+}
+
+type Room struct {
+	Id            string            `json:"id"`
+	JoinedAt      time.Time         `json:"joinedAt"`
+	JoinedBy      string            `json:"joinedBy,omitempty"`
+	LastMessageId string            `json:"lastMessageId,omitempty"`
+	Options       map[string]string `json:"options,omitempty"`
+	Tags          []string          `json:"tags,omitempty"`
+}
+
+// legacyRoom is a room in unversioned backup, either room id or room id with last message id
+type legacyRoom struct {
+	RoomId        string `json:"roomId"`
+	LastMessageId string `json:"lastMessageId"`
+}
+
+func (r *legacyRoom) UnmarshalJSON(data []byte) error {
+
+	var roomId string
+	if err := json.Unmarshal(data, &roomId); err == nil {
+		r.RoomId = roomId
+		return nil
+	}
+
+	type room legacyRoom
+	return json.Unmarshal(data, (*room)(r))
+}
+
+func NewBackup() Backup {
+	return Backup{Version: Version, Rooms: []Room{}}
+}
+
+// Decode reads backup document, unversioned backups (JSON array of rooms) are migrated to the current version
+func Decode(b []byte) (Backup, error) {
+
+	b = bytes.TrimSpace(b)
+	if len(b) == 0 {
+		return Backup{}, fmt.Errorf("backup is empty")
+	}
+
+	if b[0] == '[' {
+		return decodeLegacy(b)
+	}
+
+	probe := struct {
+		Version *int `json:"version"`
+	}{}
+	if err := json.Unmarshal(b, &probe); err != nil {
+		return Backup{}, fmt.Errorf("backup is not valid JSON: %v", err)
+	}
+	if probe.Version == nil {
+		return Backup{}, fmt.Errorf("backup has no version")
+	}
+	if *probe.Version != Version {
+		return Backup{}, fmt.Errorf("unknown backup version=%d, supported version=%d", *probe.Version, Version)
+	}
+
+	backup := Backup{}
+	if err := json.Unmarshal(b, &backup); err != nil {
+		return Backup{}, fmt.Errorf("backup version=%d is not valid: %v", Version, err)
+	}
+	if backup.Rooms == nil {
+		backup.Rooms = []Room{}
+	}
+	return backup, nil
+}
+
+func decodeLegacy(b []byte) (Backup, error) {
+
+	rooms := []legacyRoom{}
+	if err := json.Unmarshal(b, &rooms); err != nil {
+		return Backup{}, fmt.Errorf("unversioned backup is not valid: %v", err)
+	}
+
+	backup := NewBackup()
+	for _, r := range rooms {
+		backup.Rooms = append(backup.Rooms, Room{Id: r.RoomId, LastMessageId: r.LastMessageId})
+	}
+	return backup, nil
+}
+
+func Encode(backup Backup) ([]byte, error) {
+
+	backup.Version = Version
+	return json.Marshal(backup)
+}
@@ -0,0 +1,101 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bkp
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestDecodeRoomIdsBackup(t *testing.T) {
+
+	backup, err := Decode([]byte(`["123", "456"]`))
+
+	assert.Nil(t, err)
+	assert.Equal(t, Version, backup.Version)
+	assert.Equal(t, []Room{{Id: "123"}, {Id: "456"}}, backup.Rooms)
+}
+
+func TestDecodeLastMessageIdsBackup(t *testing.T) {
+
+	backup, err := Decode([]byte(`[{"roomId": "123", "lastMessageId": "msg-1"}, "456"]`))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []Room{{Id: "123", LastMessageId: "msg-1"}, {Id: "456"}}, backup.Rooms)
+}
+
+func TestDecodeVersionedBackup(t *testing.T) {
+
+	backup, err := Decode([]byte(`{"version": 1, "rooms": [{"id": "123", "joinedAt": "2018-01-02T10:00:00Z", ` +
+		`"joinedBy": "config", "lastMessageId": "msg-1", "options": {"colour": "red"}}]}`))
+
+	assert.Nil(t, err)
+	expected := Room{
+		Id:            "123",
+		JoinedAt:      time.Date(2018, 1, 2, 10, 0, 0, 0, time.UTC),
+		JoinedBy:      "config",
+		LastMessageId: "msg-1",
+		Options:       map[string]string{"colour": "red"},
+	}
+	assert.Equal(t, []Room{expected}, backup.Rooms)
+}
+
+func TestEncodeAndDecode(t *testing.T) {
+
+	backup := NewBackup()
+	backup.Rooms = append(backup.Rooms, Room{Id: "123", JoinedAt: time.Date(2018, 1, 2, 10, 0, 0, 0, time.UTC)})
+
+	b, err := Encode(backup)
+	assert.Nil(t, err)
+
+	decoded, err := Decode(b)
+	assert.Nil(t, err)
+	assert.Equal(t, backup, decoded)
+}
+
+func TestDecodeEmptyVersionedBackup(t *testing.T) {
+
+	backup, err := Decode([]byte(`{"version": 1}`))
+
+	assert.Nil(t, err)
+	assert.Equal(t, []Room{}, backup.Rooms)
+}
+
+func TestDecodeUnknownVersion(t *testing.T) {
+
+	_, err := Decode([]byte(`{"version": 2, "rooms": []}`))
+	assert.EqualError(t, err, "unknown backup version=2, supported version=1")
+}
+
+func TestDecodeMissingVersion(t *testing.T) {
+
+	_, err := Decode([]byte(`{"rooms": []}`))
+	assert.EqualError(t, err, "backup has no version")
+}
+
+func TestDecodeMalformedBackup(t *testing.T) {
+
+	_, err := Decode([]byte(`{"version": 1, "rooms": [`))
+	assert.Contains(t, err.Error(), "backup is not valid JSON: ")
+
+	_, err = Decode([]byte(`[123]`))
+	assert.Contains(t, err.Error(), "unversioned backup is not valid: ")
+
+	_, err = Decode([]byte(``))
+	assert.EqualError(t, err, "backup is empty")
+}
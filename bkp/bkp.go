@@ -30,7 +30,8 @@ func CreateBkpFile(dir, fileName string) string {
 		if _, err := os.Create(path); err != nil {
 			logger.Fatalf("cannot create bkp path %s: %v", path, err)
 		}
-		if err = ioutil.WriteFile(path, []byte(`[]`), 0644); err != nil {
+		b, _ := Encode(NewBackup())
+		if err = ioutil.WriteFile(path, b, 0644); err != nil {
 			logger.Fatalf("cannot write initial bkp file %s: %v", path, err)
 		}
 	}
@@ -0,0 +1,159 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bkp
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HotelsDotCom/go-logger"
+	"github.com/boltdb/bolt"
+	"strconv"
+	"time"
+)
+
+var (
+	metaBucket    = []byte("meta")
+	roomsBucket   = []byte("rooms")
+	invalidBucket = []byte("rooms.invalid")
+	versionKey    = []byte("version")
+)
+
+// BoltStore keeps joined rooms in an embedded BoltDB database, one key per room
+type BoltStore struct {
+	db *bolt.DB
+}
+
+// NewBoltStore opens (or creates) database at path, the database is locked until the store is closed
+func NewBoltStore(path string) (*BoltStore, error) {
+
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("cannot open database=%q: %v", path, err)
+	}
+	return &BoltStore{db: db}, nil
+}
+
+// Load reads rooms from the database. Rooms written by a newer version are moved to the rooms.invalid bucket and no
+// rooms are returned.
+func (s *BoltStore) Load() (Backup, error) {
+
+	if err := s.checkVersion(); err != nil {
+		logger.Errorf("cannot load rooms from database=%q, starting without joined rooms: %v", s.db.Path(), err)
+		// invalid rooms are kept for inspection and not overwritten
+		if err := s.moveInvalid(); err != nil {
+			logger.Errorf("cannot move invalid rooms in database=%q: %v", s.db.Path(), err)
+		}
+		return NewBackup(), nil
+	}
+
+	backup := NewBackup()
+	err := s.db.View(func(tx *bolt.Tx) error {
+
+		rooms := tx.Bucket(roomsBucket)
+		if rooms == nil {
+			return nil
+		}
+		return rooms.ForEach(func(k, v []byte) error {
+			room := Room{}
+			if err := json.Unmarshal(v, &room); err != nil {
+				logger.Errorf("cannot load room=%s from database, skipping it: %v", k, err)
+				return nil
+			}
+			backup.Rooms = append(backup.Rooms, room)
+			return nil
+		})
+	})
+	return backup, err
+}
+
+// Save replaces all stored rooms with rooms in backup
+func (s *BoltStore) Save(backup Backup) error {
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+		if err := meta.Put(versionKey, []byte(strconv.Itoa(Version))); err != nil {
+			return err
+		}
+
+		if tx.Bucket(roomsBucket) != nil {
+			if err := tx.DeleteBucket(roomsBucket); err != nil {
+				return err
+			}
+		}
+		rooms, err := tx.CreateBucketIfNotExists(roomsBucket)
+		if err != nil {
+			return err
+		}
+
+		for _, room := range backup.Rooms {
+			v, err := json.Marshal(room)
+			if err != nil {
+				return err
+			}
+			if err := rooms.Put([]byte(room.Id), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func (s *BoltStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *BoltStore) checkVersion() error {
+
+	return s.db.View(func(tx *bolt.Tx) error {
+
+		if meta := tx.Bucket(metaBucket); meta != nil {
+			if v := string(meta.Get(versionKey)); v != strconv.Itoa(Version) {
+				return fmt.Errorf("unknown backup version=%s, supported version=%d", v, Version)
+			}
+		}
+		return nil
+	})
+}
+
+// moveInvalid moves rooms to the invalid bucket, replacing rooms moved there before
+func (s *BoltStore) moveInvalid() error {
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+
+		rooms := tx.Bucket(roomsBucket)
+		if rooms == nil {
+			return nil
+		}
+		if tx.Bucket(invalidBucket) != nil {
+			if err := tx.DeleteBucket(invalidBucket); err != nil {
+				return err
+			}
+		}
+		invalid, err := tx.CreateBucketIfNotExists(invalidBucket)
+		if err != nil {
+			return err
+		}
+		if err := rooms.ForEach(invalid.Put); err != nil {
+			return err
+		}
+		return tx.DeleteBucket(roomsBucket)
+	})
+}
@@ -0,0 +1,129 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bkp
+
+import (
+	"github.com/boltdb/bolt"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestBoltStoreSaveAndLoad(t *testing.T) {
+
+	dir := createTestDir()
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rooms.db")
+
+	store, err := NewBoltStore(path)
+	assert.Nil(t, err)
+
+	backup := NewBackup()
+	backup.Rooms = append(backup.Rooms,
+		Room{Id: "123", JoinedAt: time.Date(2018, 1, 2, 10, 0, 0, 0, time.UTC), LastMessageId: "msg-1"},
+		Room{Id: "456", JoinedBy: "config", Options: map[string]string{"colour": "red"}})
+	assert.Nil(t, store.Save(backup))
+	assert.Nil(t, store.Close())
+
+	store, err = NewBoltStore(path)
+	assert.Nil(t, err)
+	defer store.Close()
+
+	loaded, err := store.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, backup, loaded)
+}
+
+func TestBoltStoreSaveRemovesLeftRooms(t *testing.T) {
+
+	dir := createTestDir()
+	defer os.RemoveAll(dir)
+
+	store, _ := NewBoltStore(filepath.Join(dir, "rooms.db"))
+	defer store.Close()
+
+	backup := NewBackup()
+	backup.Rooms = append(backup.Rooms, Room{Id: "123"}, Room{Id: "456"})
+	store.Save(backup)
+	backup.Rooms = backup.Rooms[1:]
+	store.Save(backup)
+
+	loaded, err := store.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, []Room{{Id: "456"}}, loaded.Rooms)
+}
+
+func TestBoltStoreLoadEmptyDatabase(t *testing.T) {
+
+	dir := createTestDir()
+	defer os.RemoveAll(dir)
+
+	store, _ := NewBoltStore(filepath.Join(dir, "rooms.db"))
+	defer store.Close()
+
+	loaded, err := store.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, NewBackup(), loaded)
+}
+
+func TestBoltStoreUnknownVersion(t *testing.T) {
+
+	dir := createTestDir()
+	defer os.RemoveAll(dir)
+
+	store, _ := NewBoltStore(filepath.Join(dir, "rooms.db"))
+	defer store.Close()
+	backup := NewBackup()
+	backup.Rooms = append(backup.Rooms, Room{Id: "123"})
+	store.Save(backup)
+	store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(metaBucket).Put(versionKey, []byte("2"))
+	})
+
+	loaded, err := store.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, NewBackup(), loaded)
+
+	var invalid []byte
+	store.db.View(func(tx *bolt.Tx) error {
+		assert.Nil(t, tx.Bucket(roomsBucket))
+		invalid = tx.Bucket(invalidBucket).Get([]byte("123"))
+		return nil
+	})
+	assert.Contains(t, string(invalid), `"id":"123"`)
+}
+
+func TestBoltStoreSkipsInvalidRoom(t *testing.T) {
+
+	dir := createTestDir()
+	defer os.RemoveAll(dir)
+
+	store, _ := NewBoltStore(filepath.Join(dir, "rooms.db"))
+	defer store.Close()
+	backup := NewBackup()
+	backup.Rooms = append(backup.Rooms, Room{Id: "123"})
+	store.Save(backup)
+	store.db.Update(func(tx *bolt.Tx) error {
+		return tx.Bucket(roomsBucket).Put([]byte("456"), []byte(`{"id": `))
+	})
+
+	loaded, err := store.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, []Room{{Id: "123"}}, loaded.Rooms)
+}
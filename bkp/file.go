@@ -0,0 +1,127 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bkp
+
+import (
+	"fmt"
+	"github.com/HotelsDotCom/go-logger"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// suffix of the previous backup generation, kept next to the backup
+const PrevSuffix = ".bak"
+
+// mode of backup files, temp files are created with 0600
+const fileMode = 0644
+
+// write is replaced in tests to simulate crash while writing
+var write = func(f *os.File, b []byte) (int, error) {
+	return f.Write(b)
+}
+
+// replaced in tests to simulate file systems without hard links
+var link = os.Link
+
+// WriteFile atomically replaces backup at path with b. b is written and synced to a temp file which is then renamed
+// over path, so path contains either previous or new backup and never partially written one. Previous backup is kept
+// as path.bak if possible.
+func WriteFile(path string, b []byte) error {
+
+	dir := filepath.Dir(path)
+	tmp, err := ioutil.TempFile(dir, filepath.Base(path)+".tmp")
+	if err != nil {
+		return fmt.Errorf("cannot create temp file: %v", err)
+	}
+
+	if err := writeTemp(tmp, b); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if err := keepPrevious(path); err != nil {
+		logger.Errorf("backup=%q is replaced without keeping the previous backup: %v", path, err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("cannot rename temp file: %v", err)
+	}
+	syncDir(dir)
+	return nil
+}
+
+func writeTemp(f *os.File, b []byte) error {
+
+	if err := f.Chmod(fileMode); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot change temp file mode: %v", err)
+	}
+	if _, err := write(f, b); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot write temp file: %v", err)
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return fmt.Errorf("cannot sync temp file: %v", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("cannot close temp file: %v", err)
+	}
+	return nil
+}
+
+// keepPrevious hard links current backup to path.bak, path itself is replaced by rename so the link keeps previous
+// content. Backup is copied if the file system does not support hard links.
+func keepPrevious(path string) error {
+
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return nil
+	}
+
+	prev := path + PrevSuffix
+	if err := os.Remove(prev); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("cannot remove previous backup: %v", err)
+	}
+	if err := link(path, prev); err != nil {
+		if err := copyFile(path, prev); err != nil {
+			return fmt.Errorf("cannot keep previous backup: %v", err)
+		}
+	}
+	return nil
+}
+
+func copyFile(src, dst string) error {
+
+	b, err := ioutil.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(dst, b, fileMode)
+}
+
+// syncDir makes rename durable, not all platforms support syncing directories so errors are ignored
+func syncDir(dir string) {
+
+	d, err := os.Open(dir)
+	if err != nil {
+		return
+	}
+	d.Sync()
+	d.Close()
+}
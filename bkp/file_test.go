@@ -0,0 +1,141 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bkp
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestWriteFile(t *testing.T) {
+
+	dir := createTestDir()
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rooms.json")
+
+	assert.Nil(t, WriteFile(path, []byte(`first`)))
+	b, _ := ioutil.ReadFile(path)
+	assert.Equal(t, "first", string(b))
+	_, err := os.Stat(path + PrevSuffix)
+	assert.True(t, os.IsNotExist(err))
+
+	assert.Nil(t, WriteFile(path, []byte(`second`)))
+	b, _ = ioutil.ReadFile(path)
+	assert.Equal(t, "second", string(b))
+	b, _ = ioutil.ReadFile(path + PrevSuffix)
+	assert.Equal(t, "first", string(b))
+
+	assert.Nil(t, WriteFile(path, []byte(`third`)))
+	b, _ = ioutil.ReadFile(path + PrevSuffix)
+	assert.Equal(t, "second", string(b))
+
+	assert.Equal(t, []string{"rooms.json", "rooms.json.bak"}, fileNames(dir))
+}
+
+func TestWriteFileInterrupted(t *testing.T) {
+
+	dir := createTestDir()
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rooms.json")
+	assert.Nil(t, WriteFile(path, []byte(`{"version": 1, "rooms": []}`)))
+
+	prevWrite := write
+	defer func() { write = prevWrite }()
+	write = func(f *os.File, b []byte) (int, error) {
+		n, _ := f.Write(b[:len(b)/2])
+		return n, errors.New("no space left on device")
+	}
+
+	err := WriteFile(path, []byte(`{"version": 1, "rooms": [{"id": "123"}]}`))
+
+	assert.EqualError(t, err, "cannot write temp file: no space left on device")
+	b, _ := ioutil.ReadFile(path)
+	assert.Equal(t, `{"version": 1, "rooms": []}`, string(b), "backup should not be partially written")
+	assert.Equal(t, []string{"rooms.json"}, fileNames(dir), "temp file should be removed")
+}
+
+func TestWriteFileMode(t *testing.T) {
+
+	dir := createTestDir()
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rooms.json")
+
+	assert.Nil(t, WriteFile(path, []byte(`first`)))
+	info, _ := os.Stat(path)
+	assert.Equal(t, os.FileMode(0644), info.Mode().Perm())
+}
+
+func TestWriteFileWithoutHardLinks(t *testing.T) {
+
+	dir := createTestDir()
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rooms.json")
+
+	prevLink := link
+	defer func() { link = prevLink }()
+	link = func(string, string) error { return errors.New("operation not permitted") }
+
+	assert.Nil(t, WriteFile(path, []byte(`first`)))
+	assert.Nil(t, WriteFile(path, []byte(`second`)))
+
+	b, _ := ioutil.ReadFile(path)
+	assert.Equal(t, "second", string(b))
+	b, _ = ioutil.ReadFile(path + PrevSuffix)
+	assert.Equal(t, "first", string(b), "previous backup should be copied")
+}
+
+func TestWriteFileWhenPreviousBackupCannotBeKept(t *testing.T) {
+
+	dir := createTestDir()
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rooms.json")
+	assert.Nil(t, WriteFile(path, []byte(`first`)))
+
+	// previous backup cannot be removed
+	os.Mkdir(path+PrevSuffix, 0755)
+	ioutil.WriteFile(filepath.Join(path+PrevSuffix, "file"), []byte(`x`), 0644)
+
+	assert.Nil(t, WriteFile(path, []byte(`second`)))
+	b, _ := ioutil.ReadFile(path)
+	assert.Equal(t, "second", string(b))
+}
+
+func TestWriteFileNonExistingDir(t *testing.T) {
+
+	err := WriteFile("/non-existing-dir/rooms.json", []byte(`[]`))
+	assert.Contains(t, err.Error(), "cannot create temp file: ")
+}
+
+func createTestDir() string {
+
+	dir, _ := ioutil.TempDir("", "flyte-hipchat-bkp")
+	return dir
+}
+
+func fileNames(dir string) []string {
+
+	names := []string{}
+	files, _ := ioutil.ReadDir(dir)
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	return names
+}
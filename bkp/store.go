@@ -0,0 +1,98 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bkp
+
+import (
+	"github.com/HotelsDotCom/go-logger"
+	"io/ioutil"
+	"os"
+)
+
+// RoomStore persists joined rooms and their last processed message ids
+type RoomStore interface {
+	Load() (Backup, error)
+	Save(backup Backup) error
+	Close() error
+}
+
+// FileStore keeps joined rooms in a JSON file
+type FileStore struct {
+	path string
+}
+
+func NewFileStore(path string) FileStore {
+	return FileStore{path: path}
+}
+
+// Load reads rooms from the file. Invalid file (malformed or written by a newer version) is moved to path.invalid and
+// rooms are loaded from the previous backup (path.bak) instead, if that cannot be read either no rooms are returned.
+func (s FileStore) Load() (Backup, error) {
+
+	b, err := ioutil.ReadFile(s.path)
+	if err != nil {
+		return Backup{}, err
+	}
+
+	backup, err := Decode(b)
+	if err == nil {
+		return backup, nil
+	}
+
+	logger.Errorf("cannot load rooms from backup=%q: %v", s.path, err)
+	// invalid backup is kept for inspection and not overwritten
+	s.moveInvalid()
+
+	prevPath := s.path + PrevSuffix
+	if backup, err = readFile(prevPath); err != nil {
+		logger.Errorf("cannot load rooms from previous backup=%q, starting without joined rooms: %v", prevPath, err)
+		return NewBackup(), nil
+	}
+	logger.Infof("loaded rooms from previous backup=%q", prevPath)
+	return backup, nil
+}
+
+func (s FileStore) Save(backup Backup) error {
+
+	b, err := Encode(backup)
+	if err != nil {
+		return err
+	}
+	return WriteFile(s.path, b)
+}
+
+func (s FileStore) Close() error {
+	return nil
+}
+
+func (s FileStore) moveInvalid() {
+
+	invalidPath := s.path + ".invalid"
+	if err := os.Rename(s.path, invalidPath); err != nil {
+		logger.Errorf("cannot move invalid backup=%q: %v", s.path, err)
+		return
+	}
+	logger.Infof("invalid backup=%q moved to %q", s.path, invalidPath)
+}
+
+func readFile(path string) (Backup, error) {
+
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return Backup{}, err
+	}
+	return Decode(b)
+}
@@ -0,0 +1,80 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package bkp
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStoreSaveAndLoad(t *testing.T) {
+
+	dir := createTestDir()
+	defer os.RemoveAll(dir)
+	store := NewFileStore(filepath.Join(dir, "rooms.json"))
+
+	backup := NewBackup()
+	backup.Rooms = append(backup.Rooms, Room{Id: "123", LastMessageId: "msg-1"})
+	assert.Nil(t, store.Save(backup))
+
+	loaded, err := store.Load()
+	assert.Nil(t, err)
+	assert.Equal(t, backup, loaded)
+}
+
+func TestFileStoreLoadNonExistingFile(t *testing.T) {
+
+	_, err := NewFileStore("/non-existing-dir/rooms.json").Load()
+	assert.NotNil(t, err)
+}
+
+func TestFileStoreLoadsPreviousBackup(t *testing.T) {
+
+	dir := createTestDir()
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rooms.json")
+	store := NewFileStore(path)
+
+	backup := NewBackup()
+	backup.Rooms = append(backup.Rooms, Room{Id: "123"})
+	store.Save(backup)
+	store.Save(NewBackup())
+	ioutil.WriteFile(path, []byte(`{"version": 1, "roo`), 0644)
+
+	loaded, err := store.Load()
+
+	assert.Nil(t, err)
+	assert.Equal(t, backup, loaded)
+	b, _ := ioutil.ReadFile(path + ".invalid")
+	assert.Equal(t, `{"version": 1, "roo`, string(b))
+}
+
+func TestFileStoreLoadsNoRoomsWhenBackupsAreInvalid(t *testing.T) {
+
+	dir := createTestDir()
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "rooms.json")
+	ioutil.WriteFile(path, []byte(`{"version": 2, "rooms": []}`), 0644)
+
+	loaded, err := NewFileStore(path).Load()
+
+	assert.Nil(t, err)
+	assert.Equal(t, NewBackup(), loaded)
+}
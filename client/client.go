@@ -17,44 +17,117 @@ limitations under the License.
 package client
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"github.com/tbruyelle/hipchat-go/hipchat"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
+	"path/filepath"
+	"strconv"
 	"time"
 )
 
 type HipchatClient interface {
 	SendMessage(roomID, message string) error
 	SendNotification(roomID string, notification *hipchat.NotificationRequest) error
+	SendPrivateMessage(userID string, message *hipchat.MessageRequest) error
 	GetMessages(roomID string, options *hipchat.LatestHistoryOptions) ([]hipchat.Message, error)
+	GetHistory(roomID string, options *hipchat.HistoryOptions) ([]hipchat.Message, error)
+	GetPrivateMessages(userID string, options *hipchat.LatestHistoryOptions) ([]hipchat.Message, error)
+	GetUser(userID string) (*hipchat.User, error)
+	GetTokenOwner() (*hipchat.User, error)
+	SetTopic(roomID, topic string) error
+	CreateRoom(room *hipchat.CreateRoomRequest) (*hipchat.Room, error)
+	ArchiveRoom(roomID string) error
+	AddMember(roomID, userID string) error
+	RemoveMember(roomID, userID string) error
+	ShareFile(roomID, filename string, content []byte, message string) error
+	ShareLink(roomID, link, message string) error
+	CreateWebhook(roomID string, webhook *hipchat.CreateWebhookRequest) (string, error)
+	DeleteWebhook(roomID, webhookID string) error
+}
+
+// Options configures the pool of HipChat clients
+type Options struct {
+	// how long a token is held back after a call when HipChat did not return rate limit headers
+	RateLimitInterval time.Duration
+	// minimum time a token is held back after a call, regardless of the rate limit headers
+	RateLimitMinInterval time.Duration
+	// HipChat API base URL e.g. https://hipchat.example.com/v2/, defaults to HipChat cloud
+	ApiURL string
+	// API base URLs for specific tokens, overrides ApiURL
+	TokenApiURLs map[string]string
+	// PEM file with CA certificates used to verify HipChat server, system CAs are used if not set
+	CAFile string
+	// do not verify HipChat server certificate
+	InsecureSkipVerify bool
 }
 
 type hipchatClient struct {
-	clientPool chan *hipchat.Client
+	clientPool chan *pooledClient
+	clock      clock
+}
+
+// pooledClient is a hipchat client for a single token together with the token's rate limiter
+type pooledClient struct {
+	*hipchat.Client
+	token   string
+	limiter *rateLimiter
+}
+
+func NewHipChatClient(authTokens []string, opts Options) (HipchatClient, error) {
+	return newHipChatClient(authTokens, opts, realClock{})
 }
 
-func NewHipChatClient(authTokens []string) HipchatClient {
+func newHipChatClient(authTokens []string, opts Options, clock clock) (hipchatClient, error) {
 
-	pool := make(chan *hipchat.Client, len(authTokens))
+	transport, err := newTransport(opts)
+	if err != nil {
+		return hipchatClient{}, err
+	}
+
+	pool := make(chan *pooledClient, len(authTokens))
 	for _, t := range authTokens {
+		limiter := newRateLimiter(opts, clock)
 		hc := hipchat.NewClient(t)
 		hc.SetHTTPClient(&http.Client{
-			Timeout: time.Second * 15,
+			Timeout:   time.Second * 15,
+			Transport: rateLimitTransport{next: transport, limiter: limiter},
 		})
-		pool <- hc
+
+		baseURL, err := apiURL(t, opts)
+		if err != nil {
+			return hipchatClient{}, err
+		}
+		if baseURL != nil {
+			hc.BaseURL = baseURL
+		}
+		pool <- &pooledClient{Client: hc, token: t, limiter: limiter}
 	}
-	return hipchatClient{clientPool: pool}
+	return hipchatClient{clientPool: pool, clock: clock}, nil
 }
 
 // Always returnClient after use to make it available again
-func (c *hipchatClient) getClient() *hipchat.Client {
+func (c *hipchatClient) getClient() *pooledClient {
 	return <-c.clientPool
 }
 
 // Return the client for use by other operations
-func (c *hipchatClient) returnClient(client *hipchat.Client) {
-	// Return the token for use after a few seconds delay - limiting API calls/minute
+func (c *hipchatClient) returnClient(client *pooledClient) {
+
+	// Return the token once the rate limit allows it - limiting API calls/minute
+	delay := client.limiter.delay()
+	if delay <= 0 {
+		c.clientPool <- client
+		return
+	}
 	go func() {
-		time.Sleep(5 * time.Second)
+		<-c.clock.After(delay)
 		c.clientPool <- client
 	}()
 }
@@ -65,28 +138,139 @@ func (c hipchatClient) SendMessage(roomID, message string) error {
 	defer c.returnClient(hcl)
 
 	messageRequest := &hipchat.RoomMessageRequest{Message: message}
+	return do(func() error {
+		resp, err := hcl.Room.Message(roomID, messageRequest)
+		return responseError(resp, err)
+	})
+}
+
+func (c hipchatClient) GetMessages(roomID string, options *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
+
+	hcl := c.getClient()
+	defer c.returnClient(hcl)
+
+	var messages []hipchat.Message
 	err := do(func() error {
-		_, err := hcl.Room.Message(roomID, messageRequest)
+		history, resp, err := hcl.Room.Latest(roomID, options)
+		if err != nil {
+			return responseError(resp, err)
+		}
+		messages = history.Items
+		return nil
+	})
+
+	if err != nil {
+		return []hipchat.Message{}, err
+	}
+	return messages, nil
+}
+
+// GetPrivateMessages returns latest messages of the private chat between token owner and the user, userID is user id,
+// email or @mention name
+func (c hipchatClient) GetPrivateMessages(userID string, options *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
+
+	hcl := c.getClient()
+	defer c.returnClient(hcl)
+
+	var messages []hipchat.Message
+	err := do(func() error {
+		// not supported by hipchat-go
+		req, err := hcl.NewRequest("GET", fmt.Sprintf("user/%s/history/latest", userID), options, nil)
 		if err != nil {
-			time.Sleep(5 * time.Second)
 			return err
 		}
+		history := new(hipchat.History)
+		resp, err := hcl.Do(req, history)
+		if err != nil {
+			return responseError(resp, err)
+		}
+		messages = history.Items
 		return nil
 	})
 
-	return err
+	if err != nil {
+		return []hipchat.Message{}, err
+	}
+	return messages, nil
 }
 
-func (c hipchatClient) GetMessages(roomID string, options *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
+// GetUser returns user by user id, email or @mention name
+func (c hipchatClient) GetUser(userID string) (*hipchat.User, error) {
 
 	hcl := c.getClient()
 	defer c.returnClient(hcl)
 
-	if history, _, err := hcl.Room.Latest(roomID, options); err != nil {
+	var user *hipchat.User
+	err := do(func() error {
+		u, resp, err := hcl.User.View(userID)
+		if err != nil {
+			return responseError(resp, err)
+		}
+		user = u
+		return nil
+	})
+	return user, err
+}
+
+// GetTokenOwner returns the user the token was issued to, i.e. the pack's user. All tokens are expected to be
+// issued to the same user.
+func (c hipchatClient) GetTokenOwner() (*hipchat.User, error) {
+
+	hcl := c.getClient()
+	defer c.returnClient(hcl)
+
+	var user *hipchat.User
+	err := do(func() error {
+		// hipchat-go does not support oauth session endpoint
+		req, err := hcl.NewRequest("GET", fmt.Sprintf("oauth/token/%s", hcl.token), nil, nil)
+		if err != nil {
+			return err
+		}
+		session := struct {
+			Owner *hipchat.User `json:"owner"`
+		}{}
+		resp, err := hcl.Do(req, &session)
+		if err != nil {
+			return responseError(resp, err)
+		}
+		user = session.Owner
+		return nil
+	})
+	if err == nil && user == nil {
+		return nil, errors.New("token has no owner")
+	}
+	return user, err
+}
+
+func (c hipchatClient) GetHistory(roomID string, options *hipchat.HistoryOptions) ([]hipchat.Message, error) {
+
+	hcl := c.getClient()
+	defer c.returnClient(hcl)
+
+	var messages []hipchat.Message
+	err := do(func() error {
+		req, err := hcl.NewRequest("GET", fmt.Sprintf("room/%s/history", roomID), options, nil)
+		if err != nil {
+			return err
+		}
+		// hipchat-go omits reverse=false, HipChat defaults to reverse=true (oldest message first)
+		q := req.URL.Query()
+		q.Set("reverse", strconv.FormatBool(options != nil && options.Reverse))
+		req.URL.RawQuery = q.Encode()
+
+		history := hipchat.History{}
+		resp, err := hcl.Do(req, &history)
+		if err != nil {
+			return responseError(resp, err)
+		}
+		messages = history.Items
+		return nil
+	})
+
+	if err != nil {
 		return []hipchat.Message{}, err
-	} else {
-		return history.Items, nil
 	}
+	return messages, nil
 }
 
 func (c hipchatClient) SendNotification(roomID string, notification *hipchat.NotificationRequest) error {
@@ -94,13 +278,221 @@ func (c hipchatClient) SendNotification(roomID string, notification *hipchat.Not
 	hcl := c.getClient()
 	defer c.returnClient(hcl)
 
+	return do(func() error {
+		resp, err := hcl.Room.Notification(roomID, notification)
+		return responseError(resp, err)
+	})
+}
+
+func (c hipchatClient) SetTopic(roomID, topic string) error {
+
+	hcl := c.getClient()
+	defer c.returnClient(hcl)
+
+	return do(func() error {
+		resp, err := hcl.Room.SetTopic(roomID, topic)
+		return responseError(resp, err)
+	})
+}
+
+func (c hipchatClient) CreateRoom(room *hipchat.CreateRoomRequest) (*hipchat.Room, error) {
+
+	hcl := c.getClient()
+	defer c.returnClient(hcl)
+
+	var created *hipchat.Room
+	err := do(func() error {
+		r, resp, err := hcl.Room.Create(room)
+		if err != nil {
+			return responseError(resp, err)
+		}
+		created = r
+		return nil
+	})
+	return created, err
+}
+
+// ArchiveRoom archives room, HipChat updates all room settings at once so current settings are read first
+func (c hipchatClient) ArchiveRoom(roomID string) error {
+
+	hcl := c.getClient()
+	defer c.returnClient(hcl)
+
+	var room *hipchat.Room
 	err := do(func() error {
-		_, err := hcl.Room.Notification(roomID, notification)
+		r, resp, err := hcl.Room.Get(roomID)
 		if err != nil {
-			time.Sleep(5 * time.Second)
+			return responseError(resp, err)
 		}
+		room = r
 		return nil
 	})
+	if err != nil {
+		return fmt.Errorf("cannot get room: %v", err)
+	}
+
+	update := &hipchat.UpdateRoomRequest{
+		Name:          room.Name,
+		Topic:         room.Topic,
+		IsGuestAccess: room.IsGuestAccessible,
+		IsArchived:    true,
+		Privacy:       room.Privacy,
+		Owner:         hipchat.ID{ID: strconv.Itoa(room.Owner.ID)},
+	}
+	return do(func() error {
+		resp, err := hcl.Room.Update(roomID, update)
+		return responseError(resp, err)
+	})
+}
+
+// AddMember adds user (user id, email or @mention name) to private room members
+func (c hipchatClient) AddMember(roomID, userID string) error {
+
+	hcl := c.getClient()
+	defer c.returnClient(hcl)
+
+	return do(func() error {
+		// not supported by hipchat-go
+		req, err := hcl.NewRequest("PUT", fmt.Sprintf("room/%s/member/%s", roomID, userID), nil, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := hcl.Do(req, nil)
+		return responseError(resp, err)
+	})
+}
+
+// ShareFile uploads file content to the room, message is optional
+func (c hipchatClient) ShareFile(roomID, filename string, content []byte, message string) error {
+
+	hcl := c.getClient()
+	defer c.returnClient(hcl)
+
+	return do(func() error {
+		// hipchat-go shares only files read from disk
+		req, err := hcl.NewRequest("POST", fmt.Sprintf("room/%s/share/file", roomID), nil, nil)
+		if err != nil {
+			return err
+		}
+		body, contentType, err := shareFileBody(filename, content, message)
+		if err != nil {
+			return err
+		}
+		req.Body = ioutil.NopCloser(body)
+		req.ContentLength = int64(body.Len())
+		req.Header.Set("Content-Type", contentType)
+
+		resp, err := hcl.Do(req, nil)
+		return responseError(resp, err)
+	})
+}
+
+func (c hipchatClient) ShareLink(roomID, link, message string) error {
+
+	hcl := c.getClient()
+	defer c.returnClient(hcl)
+
+	return do(func() error {
+		resp, err := hcl.Room.ShareLink(roomID, &hipchat.ShareLinkRequest{Link: link, Message: message})
+		return responseError(resp, err)
+	})
+}
+
+// shareFileBody returns multipart/related body with message metadata and file parts
+func shareFileBody(filename string, content []byte, message string) (*bytes.Buffer, string, error) {
 
-	return err
+	body := &bytes.Buffer{}
+	w := multipart.NewWriter(body)
+
+	metadata, err := json.Marshal(struct {
+		Message string `json:"message,omitempty"`
+	}{message})
+	if err != nil {
+		return nil, "", err
+	}
+
+	part, err := w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":        {"application/json; charset=UTF-8"},
+		"Content-Disposition": {`attachment; name="metadata"`},
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	part.Write(metadata)
+
+	fileType := mime.TypeByExtension(filepath.Ext(filename))
+	if fileType == "" {
+		fileType = http.DetectContentType(content)
+	}
+	part, err = w.CreatePart(textproto.MIMEHeader{
+		"Content-Type":        {fileType},
+		"Content-Disposition": {fmt.Sprintf(`attachment; name="file"; filename=%q`, filename)},
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	part.Write(content)
+
+	if err := w.Close(); err != nil {
+		return nil, "", err
+	}
+	return body, "multipart/related; boundary=" + w.Boundary(), nil
+}
+
+// RemoveMember removes user (user id, email or @mention name) from private room members
+func (c hipchatClient) RemoveMember(roomID, userID string) error {
+
+	hcl := c.getClient()
+	defer c.returnClient(hcl)
+
+	return do(func() error {
+		// not supported by hipchat-go
+		req, err := hcl.NewRequest("DELETE", fmt.Sprintf("room/%s/member/%s", roomID, userID), nil, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := hcl.Do(req, nil)
+		return responseError(resp, err)
+	})
+}
+
+// SendPrivateMessage sends one-to-one message, userID is user id, email or @mention name
+func (c hipchatClient) SendPrivateMessage(userID string, message *hipchat.MessageRequest) error {
+
+	hcl := c.getClient()
+	defer c.returnClient(hcl)
+
+	return do(func() error {
+		resp, err := hcl.User.Message(userID, message)
+		return responseError(resp, err)
+	})
+}
+
+// CreateWebhook registers webhook for the room and returns its id
+func (c hipchatClient) CreateWebhook(roomID string, webhook *hipchat.CreateWebhookRequest) (string, error) {
+
+	hcl := c.getClient()
+	defer c.returnClient(hcl)
+
+	var webhookID string
+	err := do(func() error {
+		link, resp, err := hcl.Room.CreateWebhook(roomID, webhook)
+		if err != nil {
+			return responseError(resp, err)
+		}
+		webhookID = strconv.Itoa(link.ID)
+		return nil
+	})
+	return webhookID, err
+}
+
+func (c hipchatClient) DeleteWebhook(roomID, webhookID string) error {
+
+	hcl := c.getClient()
+	defer c.returnClient(hcl)
+
+	return do(func() error {
+		resp, err := hcl.Room.DeleteWebhook(roomID, webhookID)
+		return responseError(resp, err)
+	})
 }
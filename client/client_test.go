@@ -0,0 +1,570 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/tbruyelle/hipchat-go/hipchat"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+)
+
+func TestSendNotification(t *testing.T) {
+
+	var path string
+	var received hipchat.NotificationRequest
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	defer server.Close()
+
+	err := c.SendNotification("123", &hipchat.NotificationRequest{Message: "hello", Color: hipchat.ColorRed, From: "flyte"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/v2/room/123/notification", path)
+	assert.Equal(t, "hello", received.Message)
+	assert.Equal(t, hipchat.ColorRed, received.Color)
+	assert.Equal(t, "flyte", received.From)
+}
+
+func TestSendPrivateMessage(t *testing.T) {
+
+	var path string
+	var received hipchat.MessageRequest
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	defer server.Close()
+
+	err := c.SendPrivateMessage("@john", &hipchat.MessageRequest{Message: "<b>hi</b>", MessageFormat: "html", Notify: true})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/v2/user/@john/message", path)
+	assert.Equal(t, hipchat.MessageRequest{Message: "<b>hi</b>", MessageFormat: "html", Notify: true}, received)
+}
+
+func TestSendPrivateMessageFailed(t *testing.T) {
+
+	defer recordSleeps()()
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	defer server.Close()
+
+	err := c.SendPrivateMessage("john@example.com", &hipchat.MessageRequest{Message: "hi"})
+	assert.NotNil(t, err)
+}
+
+func TestSetTopic(t *testing.T) {
+
+	var method, path string
+	var received map[string]string
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	defer server.Close()
+
+	err := c.SetTopic("123", "INC-123 in progress")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "PUT", method)
+	assert.Equal(t, "/v2/room/123/topic", path)
+	assert.Equal(t, "INC-123 in progress", received["topic"])
+}
+
+func TestSetTopicFailed(t *testing.T) {
+
+	defer recordSleeps()()
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	defer server.Close()
+
+	assert.NotNil(t, c.SetTopic("123", "topic"))
+}
+
+func TestCreateRoom(t *testing.T) {
+
+	var method, path string
+	var received hipchat.CreateRoomRequest
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": 42, "links": {"self": "https://hipchat/v2/room/42"}}`))
+	})
+	defer server.Close()
+
+	request := &hipchat.CreateRoomRequest{Name: "INC-123", Topic: "investigating", Privacy: "private", OwnerUserID: "@jane", GuestAccess: true}
+	room, err := c.CreateRoom(request)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 42, room.ID)
+	assert.Equal(t, "POST", method)
+	assert.Equal(t, "/v2/room", path)
+	assert.Equal(t, *request, received)
+}
+
+func TestArchiveRoom(t *testing.T) {
+
+	var received hipchat.UpdateRoomRequest
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v2/room/42", r.URL.Path)
+		if r.Method == "GET" {
+			w.Write([]byte(`{"id": 42, "name": "INC-123", "topic": "resolved", "privacy": "private", ` +
+				`"is_guest_accessible": true, "owner": {"id": 7}}`))
+			return
+		}
+		assert.Equal(t, "PUT", r.Method)
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	defer server.Close()
+
+	err := c.ArchiveRoom("42")
+
+	assert.Nil(t, err)
+	expected := hipchat.UpdateRoomRequest{
+		Name:          "INC-123",
+		Topic:         "resolved",
+		IsGuestAccess: true,
+		IsArchived:    true,
+		Privacy:       "private",
+		Owner:         hipchat.ID{ID: "7"},
+	}
+	assert.Equal(t, expected, received)
+}
+
+func TestArchiveRoomNotFound(t *testing.T) {
+
+	defer recordSleeps()()
+	updated := false
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == "PUT" {
+			updated = true
+		}
+		w.WriteHeader(http.StatusNotFound)
+	})
+	defer server.Close()
+
+	err := c.ArchiveRoom("42")
+
+	assert.Contains(t, err.Error(), "cannot get room: ")
+	assert.False(t, updated)
+}
+
+func TestAddMember(t *testing.T) {
+
+	var method, path string
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		path = r.URL.Path
+		w.WriteHeader(http.StatusNoContent)
+	})
+	defer server.Close()
+
+	err := c.AddMember("42", "jane@example.com")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "PUT", method)
+	assert.Equal(t, "/v2/room/42/member/jane@example.com", path)
+}
+
+func TestAddMemberFailed(t *testing.T) {
+
+	defer recordSleeps()()
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	defer server.Close()
+
+	assert.NotNil(t, c.AddMember("42", "@jane"))
+}
+
+func TestRemoveMember(t *testing.T) {
+
+	var method, path string
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		path = r.URL.Path
+		w.WriteHeader(http.StatusNoContent)
+	})
+	defer server.Close()
+
+	err := c.RemoveMember("42", "@jane")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "DELETE", method)
+	assert.Equal(t, "/v2/room/42/member/@jane", path)
+}
+
+func TestShareFile(t *testing.T) {
+
+	var path, metadata, file, filename, fileType string
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		assert.Equal(t, "multipart/related", mediaType)
+
+		reader := multipart.NewReader(r.Body, params["boundary"])
+		part, _ := reader.NextPart()
+		b, _ := ioutil.ReadAll(part)
+		metadata = string(b)
+
+		part, _ = reader.NextPart()
+		b, _ = ioutil.ReadAll(part)
+		file = string(b)
+		filename = part.FileName()
+		fileType = part.Header.Get("Content-Type")
+		w.WriteHeader(http.StatusNoContent)
+	})
+	defer server.Close()
+
+	err := c.ShareFile("42", "graph.png", []byte("png"), "daily report")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/v2/room/42/share/file", path)
+	assert.Equal(t, `{"message":"daily report"}`, metadata)
+	assert.Equal(t, "png", file)
+	assert.Equal(t, "graph.png", filename)
+	assert.Equal(t, "image/png", fileType)
+}
+
+func TestShareFileRetriedOnServerError(t *testing.T) {
+
+	defer recordSleeps()()
+	var files []string
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		_, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		reader := multipart.NewReader(r.Body, params["boundary"])
+		reader.NextPart()
+		part, _ := reader.NextPart()
+		b, _ := ioutil.ReadAll(part)
+		files = append(files, string(b))
+		if len(files) == 1 {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	defer server.Close()
+
+	err := c.ShareFile("42", "graph", []byte("content"), "")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"content", "content"}, files)
+}
+
+func TestShareLink(t *testing.T) {
+
+	var method, path string
+	var received map[string]string
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusNoContent)
+	})
+	defer server.Close()
+
+	err := c.ShareLink("42", "https://ci/builds/7", "build 7 passed")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "POST", method)
+	assert.Equal(t, "/v2/room/42/share/link", path)
+	assert.Equal(t, "https://ci/builds/7", received["link"])
+	assert.Equal(t, "build 7 passed", received["message"])
+}
+
+func TestShareLinkFailed(t *testing.T) {
+
+	defer recordSleeps()()
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+	})
+	defer server.Close()
+
+	assert.NotNil(t, c.ShareLink("42", "https://ci/builds/7", ""))
+}
+
+func TestSendNotificationRetriedOnServerError(t *testing.T) {
+
+	defer recordSleeps()()
+	var requests int32
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	defer server.Close()
+
+	err := c.SendNotification("123", &hipchat.NotificationRequest{Message: "hello"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, int32(3), atomic.LoadInt32(&requests))
+}
+
+func TestSendNotificationFailed(t *testing.T) {
+
+	defer recordSleeps()()
+	var requests int32
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusInternalServerError)
+	})
+	defer server.Close()
+
+	err := c.SendNotification("123", &hipchat.NotificationRequest{Message: "hello"})
+
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(MaxRetries), atomic.LoadInt32(&requests))
+}
+
+func TestSendNotificationNotRetriedOnClientError(t *testing.T) {
+
+	defer recordSleeps()()
+	var requests int32
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusNotFound)
+	})
+	defer server.Close()
+
+	err := c.SendNotification("unknown room", &hipchat.NotificationRequest{Message: "hello"})
+
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&requests))
+}
+
+func TestSendMessageFailed(t *testing.T) {
+
+	defer recordSleeps()()
+	var requests int32
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		w.WriteHeader(http.StatusBadGateway)
+	})
+	defer server.Close()
+
+	err := c.SendMessage("123", "hello")
+
+	assert.NotNil(t, err)
+	assert.Equal(t, int32(MaxRetries), atomic.LoadInt32(&requests))
+}
+
+func TestGetMessages(t *testing.T) {
+
+	var query url.Values
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		w.Write([]byte(`{"items": [{"id": "abc", "message": "hi"}]}`))
+	})
+	defer server.Close()
+
+	messages, err := c.GetMessages("123", &hipchat.LatestHistoryOptions{MaxResults: 5, NotBefore: "xyz"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(messages))
+	assert.Equal(t, "abc", messages[0].ID)
+	assert.Equal(t, "5", query.Get("max-results"))
+	assert.Equal(t, "xyz", query.Get("not-before"))
+}
+
+func TestGetPrivateMessages(t *testing.T) {
+
+	var path string
+	var query url.Values
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		query = r.URL.Query()
+		w.Write([]byte(`{"items": [{"id": "abc", "message": "hi", "from": {"id": 7, "mention_name": "john"}}]}`))
+	})
+	defer server.Close()
+
+	messages, err := c.GetPrivateMessages("7", &hipchat.LatestHistoryOptions{MaxResults: 5, NotBefore: "xyz"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(messages))
+	assert.Equal(t, "abc", messages[0].ID)
+	assert.Equal(t, "/v2/user/7/history/latest", path)
+	assert.Equal(t, "5", query.Get("max-results"))
+	assert.Equal(t, "xyz", query.Get("not-before"))
+}
+
+func TestGetPrivateMessagesFailed(t *testing.T) {
+
+	defer recordSleeps()()
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+	})
+	defer server.Close()
+
+	messages, err := c.GetPrivateMessages("7", &hipchat.LatestHistoryOptions{})
+
+	assert.NotNil(t, err)
+	assert.Equal(t, []hipchat.Message{}, messages)
+}
+
+func TestGetUser(t *testing.T) {
+
+	var path string
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Write([]byte(`{"id": 7, "name": "John", "mention_name": "john"}`))
+	})
+	defer server.Close()
+
+	user, err := c.GetUser("@john")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/v2/user/@john", path)
+	assert.Equal(t, 7, user.ID)
+	assert.Equal(t, "john", user.MentionName)
+}
+
+func TestGetTokenOwner(t *testing.T) {
+
+	var path string
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Write([]byte(`{"id": "token", "owner": {"id": 9, "name": "Flyte", "mention_name": "flyte"}}`))
+	})
+	defer server.Close()
+
+	user, err := c.GetTokenOwner()
+
+	assert.Nil(t, err)
+	assert.Equal(t, "/v2/oauth/token/token", path)
+	assert.Equal(t, 9, user.ID)
+	assert.Equal(t, "flyte", user.MentionName)
+}
+
+func TestGetTokenOwnerMissingOwner(t *testing.T) {
+
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"id": "token"}`))
+	})
+	defer server.Close()
+
+	_, err := c.GetTokenOwner()
+
+	assert.EqualError(t, err, "token has no owner")
+}
+
+func TestGetHistory(t *testing.T) {
+
+	var path string
+	var query url.Values
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		query = r.URL.Query()
+		w.Write([]byte(`{"items": [{"id": "abc", "message": "hi"}, {"id": "def", "message": "hello"}]}`))
+	})
+	defer server.Close()
+
+	options := &hipchat.HistoryOptions{Date: "2018-01-02T10:00:00Z", EndDate: "2018-01-01T10:00:00Z"}
+	options.StartIndex = 10
+	options.MaxResults = 2
+	messages, err := c.GetHistory("123", options)
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(messages))
+	assert.Equal(t, "def", messages[1].ID)
+	assert.Equal(t, "/v2/room/123/history", path)
+	assert.Equal(t, "2018-01-02T10:00:00Z", query.Get("date"))
+	assert.Equal(t, "2018-01-01T10:00:00Z", query.Get("end-date"))
+	assert.Equal(t, "10", query.Get("start-index"))
+	assert.Equal(t, "2", query.Get("max-results"))
+	assert.Equal(t, "false", query.Get("reverse"))
+}
+
+func TestGetHistoryFailed(t *testing.T) {
+
+	defer recordSleeps()()
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	defer server.Close()
+
+	messages, err := c.GetHistory("123", &hipchat.HistoryOptions{})
+
+	assert.NotNil(t, err)
+	assert.Equal(t, []hipchat.Message{}, messages)
+}
+
+func TestCreateWebhook(t *testing.T) {
+
+	var path string
+	var received hipchat.CreateWebhookRequest
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&received)
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id": 42, "links": {"self": "https://hipchat/v2/room/123/webhook/42"}}`))
+	})
+	defer server.Close()
+
+	id, err := c.CreateWebhook("123", &hipchat.CreateWebhookRequest{Name: "flyte", Event: "room_message", URL: "https://flyte/webhook"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "42", id)
+	assert.Equal(t, "/v2/room/123/webhook", path)
+	assert.Equal(t, "room_message", received.Event)
+	assert.Equal(t, "https://flyte/webhook", received.URL)
+}
+
+func TestDeleteWebhook(t *testing.T) {
+
+	var method, path string
+	server, c := newFakeHipchat(func(w http.ResponseWriter, r *http.Request) {
+		method = r.Method
+		path = r.URL.Path
+		w.WriteHeader(http.StatusNoContent)
+	})
+	defer server.Close()
+
+	err := c.DeleteWebhook("123", "42")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "DELETE", method)
+	assert.Equal(t, "/v2/room/123/webhook/42", path)
+}
+
+// newFakeHipchat starts HipChat API server and returns client (single token) pointing to it
+func newFakeHipchat(handler http.HandlerFunc) (*httptest.Server, hipchatClient) {
+
+	server := httptest.NewServer(handler)
+	c, _ := newHipChatClient([]string{"token"}, Options{ApiURL: server.URL + "/v2/"}, newFakeClock())
+	return server, c
+}
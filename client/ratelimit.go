@@ -0,0 +1,122 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type clock interface {
+	Now() time.Time
+	After(d time.Duration) <-chan time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+// rateLimiter keeps track of HipChat's rate limit headers for a single token
+type rateLimiter struct {
+	sync.Mutex
+	interval    time.Duration
+	minInterval time.Duration
+	clock       clock
+	known       bool
+	remaining   int
+	reset       time.Time
+}
+
+func newRateLimiter(opts Options, clock clock) *rateLimiter {
+	return &rateLimiter{interval: opts.RateLimitInterval, minInterval: opts.RateLimitMinInterval, clock: clock}
+}
+
+// update records rate limit headers from the last response, nil response (e.g. timeout) resets them
+func (l *rateLimiter) update(resp *http.Response) {
+
+	l.Lock()
+	defer l.Unlock()
+
+	l.known = false
+	if resp == nil {
+		return
+	}
+
+	remaining, err := strconv.Atoi(resp.Header.Get("X-Ratelimit-Remaining"))
+	if err != nil {
+		return
+	}
+	reset, err := strconv.ParseInt(resp.Header.Get("X-Ratelimit-Reset"), 10, 64)
+	if err != nil {
+		return
+	}
+
+	l.known = true
+	l.remaining = remaining
+	l.reset = time.Unix(reset, 0)
+}
+
+// delay returns how long the token should be held back before it is used again. Remaining requests are spread
+// evenly until the rate limit resets, when there are none left the token is held until the reset.
+func (l *rateLimiter) delay() time.Duration {
+
+	l.Lock()
+	defer l.Unlock()
+
+	if !l.known {
+		return maxDuration(l.interval, l.minInterval)
+	}
+
+	untilReset := l.reset.Sub(l.clock.Now())
+	if untilReset < 0 {
+		untilReset = 0
+	}
+
+	d := untilReset
+	if l.remaining > 0 {
+		d = untilReset / time.Duration(l.remaining)
+	}
+	return maxDuration(d, l.minInterval)
+}
+
+// rateLimitTransport records rate limit headers of every response returned to the token's client
+type rateLimitTransport struct {
+	next    http.RoundTripper
+	limiter *rateLimiter
+}
+
+func (t rateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	resp, err := t.next.RoundTrip(req)
+	t.limiter.update(resp)
+	return resp, err
+}
+
+func maxDuration(a, b time.Duration) time.Duration {
+	if a > b {
+		return a
+	}
+	return b
+}
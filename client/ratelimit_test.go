@@ -0,0 +1,208 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestDelayWithoutRateLimitHeaders(t *testing.T) {
+
+	l := newRateLimiter(Options{RateLimitInterval: 5 * time.Second}, newFakeClock())
+	l.update(&http.Response{Header: http.Header{}})
+
+	assert.Equal(t, 5*time.Second, l.delay())
+}
+
+func TestDelayAfterFailedRequest(t *testing.T) {
+
+	clock := newFakeClock()
+	l := newRateLimiter(Options{RateLimitInterval: 5 * time.Second}, clock)
+	l.update(rateLimitResponse(10, clock.Now().Add(time.Minute)))
+	l.update(nil)
+
+	assert.Equal(t, 5*time.Second, l.delay())
+}
+
+func TestDelaySpreadsRemainingRequestsUntilReset(t *testing.T) {
+
+	clock := newFakeClock()
+	l := newRateLimiter(Options{RateLimitInterval: 5 * time.Second}, clock)
+	l.update(rateLimitResponse(100, clock.Now().Add(5*time.Minute)))
+
+	assert.Equal(t, 3*time.Second, l.delay())
+}
+
+func TestDelayNoRemainingRequests(t *testing.T) {
+
+	clock := newFakeClock()
+	l := newRateLimiter(Options{RateLimitInterval: 5 * time.Second}, clock)
+	l.update(rateLimitResponse(0, clock.Now().Add(42*time.Second)))
+
+	assert.Equal(t, 42*time.Second, l.delay())
+}
+
+func TestDelayResetInThePast(t *testing.T) {
+
+	clock := newFakeClock()
+	l := newRateLimiter(Options{RateLimitInterval: 5 * time.Second}, clock)
+	l.update(rateLimitResponse(0, clock.Now().Add(-time.Minute)))
+
+	assert.Equal(t, time.Duration(0), l.delay())
+}
+
+func TestDelayMinInterval(t *testing.T) {
+
+	clock := newFakeClock()
+	l := newRateLimiter(Options{RateLimitInterval: 5 * time.Second, RateLimitMinInterval: time.Second}, clock)
+	l.update(rateLimitResponse(1000, clock.Now().Add(time.Minute)))
+
+	assert.Equal(t, time.Second, l.delay())
+}
+
+func TestReturnClientImmediatelyWhenRequestsRemain(t *testing.T) {
+
+	clock := newFakeClock()
+	c, _ := newHipChatClient([]string{"token"}, Options{RateLimitInterval: 5 * time.Second}, clock)
+
+	hcl := c.getClient()
+	hcl.limiter.update(rateLimitResponse(100, clock.Now()))
+	c.returnClient(hcl)
+
+	assert.Equal(t, 1, len(c.clientPool))
+}
+
+func TestReturnClientAfterReset(t *testing.T) {
+
+	clock := newFakeClock()
+	c, _ := newHipChatClient([]string{"token"}, Options{RateLimitInterval: 5 * time.Second}, clock)
+
+	hcl := c.getClient()
+	hcl.limiter.update(rateLimitResponse(0, clock.Now().Add(30*time.Second)))
+	c.returnClient(hcl)
+
+	clock.waitForTimers(1)
+	assert.Equal(t, 0, len(c.clientPool))
+
+	clock.Advance(29 * time.Second)
+	assert.Equal(t, 0, len(c.clientPool))
+
+	clock.Advance(time.Second)
+	select {
+	case returned := <-c.clientPool:
+		assert.Equal(t, hcl, returned)
+	case <-time.After(time.Second):
+		t.Error("client was not returned to the pool after rate limit reset")
+	}
+}
+
+func TestRateLimitTransportRecordsHeaders(t *testing.T) {
+
+	clock := newFakeClock()
+	l := newRateLimiter(Options{RateLimitInterval: 5 * time.Second}, clock)
+	resp := rateLimitResponse(0, clock.Now().Add(10*time.Second))
+	transport := rateLimitTransport{next: roundTripperFunc(func(*http.Request) (*http.Response, error) { return resp, nil }), limiter: l}
+
+	req, _ := http.NewRequest("GET", "http://hipchat", nil)
+	transport.RoundTrip(req)
+
+	assert.Equal(t, 10*time.Second, l.delay())
+}
+
+func rateLimitResponse(remaining int, reset time.Time) *http.Response {
+
+	header := http.Header{}
+	header.Set("X-Ratelimit-Remaining", strconv.Itoa(remaining))
+	header.Set("X-Ratelimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+	return &http.Response{Header: header}
+}
+
+type roundTripperFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripperFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// --- fake clock ---
+
+type fakeTimer struct {
+	at time.Time
+	ch chan time.Time
+}
+
+type fakeClock struct {
+	sync.Mutex
+	now    time.Time
+	timers []fakeTimer
+}
+
+func newFakeClock() *fakeClock {
+	return &fakeClock{now: time.Unix(1500000000, 0)}
+}
+
+func (c *fakeClock) Now() time.Time {
+
+	c.Lock()
+	defer c.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) After(d time.Duration) <-chan time.Time {
+
+	c.Lock()
+	defer c.Unlock()
+
+	ch := make(chan time.Time, 1)
+	c.timers = append(c.timers, fakeTimer{at: c.now.Add(d), ch: ch})
+	return ch
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+
+	c.Lock()
+	defer c.Unlock()
+
+	c.now = c.now.Add(d)
+	pending := []fakeTimer{}
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			pending = append(pending, t)
+			continue
+		}
+		t.ch <- c.now
+	}
+	c.timers = pending
+}
+
+// waitForTimers blocks until n timers have been registered by goroutines under test
+func (c *fakeClock) waitForTimers(n int) {
+
+	for i := 0; i < 1000; i++ {
+		c.Lock()
+		registered := len(c.timers)
+		c.Unlock()
+		if registered >= n {
+			return
+		}
+		time.Sleep(time.Millisecond)
+	}
+}
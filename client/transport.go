@@ -0,0 +1,90 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// newTransport returns http transport for HipChat servers with custom CA certificates or without TLS verification
+func newTransport(opts Options) (http.RoundTripper, error) {
+
+	if opts.CAFile == "" && !opts.InsecureSkipVerify {
+		return http.DefaultTransport, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: opts.InsecureSkipVerify}
+	if opts.CAFile != "" {
+		pem, err := ioutil.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("cannot read CA file=%q: %v", opts.CAFile, err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA file=%q", opts.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	// same settings as http.DefaultTransport
+	return &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		TLSClientConfig:       tlsConfig,
+	}, nil
+}
+
+// apiURL returns HipChat API base URL for the token, nil if the default (cloud) one should be used
+func apiURL(authToken string, opts Options) (*url.URL, error) {
+
+	rawURL := opts.ApiURL
+	if u, ok := opts.TokenApiURLs[authToken]; ok {
+		rawURL = u
+	}
+	if rawURL == "" {
+		return nil, nil
+	}
+
+	// relative API paths are resolved against the base URL, it has to end with slash
+	if !strings.HasSuffix(rawURL, "/") {
+		rawURL += "/"
+	}
+
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("HipChat API url=%q is not valid: %v", rawURL, err)
+	}
+	if u.Scheme == "" || u.Host == "" {
+		return nil, fmt.Errorf("HipChat API url=%q is not absolute", rawURL)
+	}
+	return u, nil
+}
@@ -0,0 +1,156 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package client
+
+import (
+	"encoding/pem"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+)
+
+func TestApiURLDefault(t *testing.T) {
+
+	u, err := apiURL("token", Options{})
+
+	assert.Nil(t, err)
+	assert.Nil(t, u)
+}
+
+func TestApiURL(t *testing.T) {
+
+	u, err := apiURL("token", Options{ApiURL: "https://hipchat.example.com/v2/"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "https://hipchat.example.com/v2/", u.String())
+}
+
+func TestApiURLAddsTrailingSlash(t *testing.T) {
+
+	u, err := apiURL("token", Options{ApiURL: "https://hipchat.example.com/v2"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "https://hipchat.example.com/v2/", u.String())
+}
+
+func TestApiURLTokenOverride(t *testing.T) {
+
+	opts := Options{
+		ApiURL:       "https://hipchat.example.com/v2/",
+		TokenApiURLs: map[string]string{"abc": "https://other.example.com/v2/"},
+	}
+
+	abc, _ := apiURL("abc", opts)
+	def, _ := apiURL("def", opts)
+
+	assert.Equal(t, "https://other.example.com/v2/", abc.String())
+	assert.Equal(t, "https://hipchat.example.com/v2/", def.String())
+}
+
+func TestApiURLNotAbsolute(t *testing.T) {
+
+	_, err := apiURL("token", Options{ApiURL: "hipchat.example.com/v2/"})
+
+	assert.Equal(t, `HipChat API url="hipchat.example.com/v2/" is not absolute`, err.Error())
+}
+
+func TestClientPointsToApiURL(t *testing.T) {
+
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	c, err := NewHipChatClient([]string{"token"}, Options{ApiURL: server.URL + "/hipchat/v2"})
+	assert.Nil(t, err)
+
+	err = c.SendMessage("123", "hello")
+	assert.Nil(t, err)
+	assert.Equal(t, "/hipchat/v2/room/123/message", path)
+}
+
+func TestClientWithCAFile(t *testing.T) {
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	caFile := writeCAFile(server)
+	defer os.Remove(caFile)
+
+	c, err := NewHipChatClient([]string{"token"}, Options{ApiURL: server.URL + "/v2/", CAFile: caFile})
+	assert.Nil(t, err)
+	assert.Nil(t, c.SendMessage("123", "hello"))
+}
+
+func TestClientUnknownCA(t *testing.T) {
+
+	defer recordSleeps()()
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	c, err := NewHipChatClient([]string{"token"}, Options{ApiURL: server.URL + "/v2/"})
+	assert.Nil(t, err)
+	assert.NotNil(t, c.SendMessage("123", "hello"))
+}
+
+func TestClientInsecureSkipVerify(t *testing.T) {
+
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	c, err := NewHipChatClient([]string{"token"}, Options{ApiURL: server.URL + "/v2/", InsecureSkipVerify: true})
+	assert.Nil(t, err)
+	assert.Nil(t, c.SendMessage("123", "hello"))
+}
+
+func TestClientMissingCAFile(t *testing.T) {
+
+	_, err := NewHipChatClient([]string{"token"}, Options{CAFile: "/non-existing/ca.pem"})
+
+	assert.Contains(t, err.Error(), `cannot read CA file="/non-existing/ca.pem": `)
+}
+
+func TestClientInvalidCAFile(t *testing.T) {
+
+	f, _ := ioutil.TempFile("", "flyte-hipchat-ca")
+	f.WriteString("not a certificate")
+	f.Close()
+	defer os.Remove(f.Name())
+
+	_, err := NewHipChatClient([]string{"token"}, Options{CAFile: f.Name()})
+
+	assert.Equal(t, "no certificates found in CA file=\""+f.Name()+"\"", err.Error())
+}
+
+func writeCAFile(server *httptest.Server) string {
+
+	f, _ := ioutil.TempFile("", "flyte-hipchat-ca")
+	defer f.Close()
+	pem.Encode(f, &pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	return f.Name()
+}
@@ -17,26 +17,108 @@ limitations under the License.
 package client
 
 import (
-	"errors"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
 )
 
 var MaxRetries = 10
-var errMaxRetriesReached = errors.New("exceeded retry limit")
+var InitialBackoff = 500 * time.Millisecond
+var MaxBackoff = 30 * time.Second
+
+// replaced in tests
+var sleep = time.Sleep
+var jitter = rand.Float64
+var now = time.Now
 
 type unreliableFunc func() (err error)
 
+// do calls fn until it succeeds, returns an error that cannot be retried or MaxRetries is reached. The last error is
+// returned. Retryable errors are timeouts and other transport errors, 429 and 5xx responses. Responses asking to retry
+// later than MaxBackoff are not retried.
 func do(fn unreliableFunc) error {
+
 	var err error
-	attempt := 1
-	for {
+	for attempt := 1; ; attempt++ {
 		err = fn()
-		if err == nil {
-			break
+		if err == nil || !isRetryable(err) || attempt >= MaxRetries {
+			return err
+		}
+		delay := backoff(attempt, err)
+		if delay > MaxBackoff {
+			return err
+		}
+		sleep(delay)
+	}
+}
+
+// statusError is an error returned together with a HipChat response
+type statusError struct {
+	statusCode int
+	retryAfter time.Duration
+	err        error
+}
+
+func (e *statusError) Error() string {
+	return e.err.Error()
+}
+
+// responseError adds response status to the error returned by hipchat client, so it can be classified by do
+func responseError(resp *http.Response, err error) error {
+
+	if err == nil || resp == nil {
+		return err
+	}
+	return &statusError{statusCode: resp.StatusCode, retryAfter: retryAfter(resp), err: err}
+}
+
+func isRetryable(err error) bool {
+
+	if e, ok := err.(*statusError); ok {
+		return e.statusCode == http.StatusTooManyRequests || e.statusCode >= 500
+	}
+	// no response - timeout, connection refused etc. (*url.Error is net.Error), other errors (e.g. request could not
+	// be built) would fail again
+	_, ok := err.(net.Error)
+	return ok
+}
+
+// backoff returns exponential delay with jitter for the attempt, unless HipChat told us when to retry
+func backoff(attempt int, err error) time.Duration {
+
+	if e, ok := err.(*statusError); ok && e.retryAfter > 0 {
+		return e.retryAfter
+	}
+
+	d := MaxBackoff
+	if attempt < 32 {
+		if exp := InitialBackoff * time.Duration(1<<uint(attempt-1)); exp > 0 && exp < MaxBackoff {
+			d = exp
 		}
-		attempt++
-		if attempt > MaxRetries {
-			return errMaxRetriesReached
+	}
+	// equal jitter, wait at least half of the delay
+	return d/2 + time.Duration(jitter()*float64(d/2))
+}
+
+// retryAfter reads Retry-After header (seconds or http date), rate limited responses without it are retried
+// when the rate limit resets
+func retryAfter(resp *http.Response) time.Duration {
+
+	if v := resp.Header.Get("Retry-After"); v != "" {
+		if seconds, err := strconv.Atoi(v); err == nil {
+			return time.Duration(seconds) * time.Second
+		}
+		if t, err := http.ParseTime(v); err == nil {
+			return t.Sub(now())
+		}
+	}
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		if reset, err := strconv.ParseInt(resp.Header.Get("X-Ratelimit-Reset"), 10, 64); err == nil {
+			return time.Unix(reset, 0).Sub(now())
 		}
 	}
-	return err
+	return 0
 }
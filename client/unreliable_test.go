@@ -17,18 +17,27 @@ limitations under the License.
 package client
 
 import (
+	"errors"
 	"fmt"
+	"github.com/stretchr/testify/assert"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
 	"testing"
+	"time"
 )
 
 func Test_SomeFailureSucceedsInTheEnd(t *testing.T) {
+
+	defer recordSleeps()()
 	var attempt = 0
 	err := do(func() (err error) {
 		attempt++
 		if attempt > 2 {
 			return nil
 		} else {
-			return fmt.Errorf("agh - something went wrong")
+			return transportError("agh - something went wrong")
 		}
 	})
 	if err != nil {
@@ -37,10 +46,12 @@ func Test_SomeFailureSucceedsInTheEnd(t *testing.T) {
 }
 
 func Test_ExceedMaxRetriesError(t *testing.T) {
+
+	defer recordSleeps()()
 	var actualRetries = 0
 	err := do(func() (err error) {
 		actualRetries++
-		return fmt.Errorf("agh - something wrong")
+		return transportError("agh - something wrong")
 	})
 	if err == nil {
 		t.Errorf("No error returned %s", err)
@@ -58,3 +69,196 @@ func Test_NoRetriesNeeded(t *testing.T) {
 		t.Errorf("Got an error still %s", err)
 	}
 }
+
+func Test_ExceedMaxRetriesReturnsLastError(t *testing.T) {
+
+	defer recordSleeps()()
+	var attempt = 0
+	err := do(func() (err error) {
+		attempt++
+		return transportError(fmt.Sprintf("attempt %d failed", attempt))
+	})
+	assert.Equal(t, "attempt 10 failed", err.(*url.Error).Err.Error())
+}
+
+func Test_TransportErrorIsRetried(t *testing.T) {
+
+	defer recordSleeps()()
+	var attempts = 0
+	err := do(func() (err error) {
+		attempts++
+		return &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+	})
+
+	assert.NotNil(t, err)
+	assert.Equal(t, MaxRetries, attempts)
+}
+
+func Test_ErrorWithoutResponseIsNotRetried(t *testing.T) {
+
+	sleeps := []time.Duration{}
+	defer recordSleepsTo(&sleeps)()
+
+	var attempts = 0
+	err := do(func() (err error) {
+		attempts++
+		return errors.New("cannot build request")
+	})
+
+	assert.EqualError(t, err, "cannot build request")
+	assert.Equal(t, 1, attempts)
+	assert.Equal(t, 0, len(sleeps))
+}
+
+func Test_PermanentErrorIsNotRetried(t *testing.T) {
+
+	for _, code := range []int{400, 401, 403, 404} {
+		sleeps := []time.Duration{}
+		restore := recordSleepsTo(&sleeps)
+
+		var attempts = 0
+		err := do(func() (err error) {
+			attempts++
+			return responseError(&http.Response{StatusCode: code, Header: http.Header{}}, errors.New("client error"))
+		})
+
+		restore()
+		assert.EqualError(t, err, "client error")
+		assert.Equal(t, 1, attempts, "status %d", code)
+		assert.Equal(t, 0, len(sleeps), "status %d", code)
+	}
+}
+
+func Test_RetryableStatusIsRetried(t *testing.T) {
+
+	for _, code := range []int{429, 500, 502, 503} {
+		restore := recordSleepsTo(&[]time.Duration{})
+
+		var attempts = 0
+		err := do(func() (err error) {
+			attempts++
+			if attempts < 3 {
+				return responseError(&http.Response{StatusCode: code, Header: http.Header{}}, errors.New("server error"))
+			}
+			return nil
+		})
+
+		restore()
+		assert.Nil(t, err)
+		assert.Equal(t, 3, attempts, "status %d", code)
+	}
+}
+
+func Test_ExponentialBackoff(t *testing.T) {
+
+	sleeps := []time.Duration{}
+	defer recordSleepsTo(&sleeps)()
+	defer withJitter(0)()
+
+	do(func() (err error) {
+		return transportError("timeout")
+	})
+
+	assert.Equal(t, 9, len(sleeps))
+	assert.Equal(t, 250*time.Millisecond, sleeps[0])
+	assert.Equal(t, 500*time.Millisecond, sleeps[1])
+	assert.Equal(t, time.Second, sleeps[2])
+	assert.Equal(t, 2*time.Second, sleeps[3])
+	assert.Equal(t, 15*time.Second, sleeps[8], "capped at max backoff")
+}
+
+func Test_BackoffJitter(t *testing.T) {
+
+	defer withJitter(1)()
+	assert.Equal(t, 2*time.Second, backoff(3, errors.New("timeout")))
+
+	defer withJitter(0.5)()
+	assert.Equal(t, 1500*time.Millisecond, backoff(3, errors.New("timeout")))
+}
+
+func Test_RetryAfterSeconds(t *testing.T) {
+
+	header := http.Header{}
+	header.Set("Retry-After", "7")
+	err := responseError(&http.Response{StatusCode: 503, Header: header}, errors.New("unavailable"))
+
+	assert.Equal(t, 7*time.Second, backoff(1, err))
+}
+
+func Test_RetryAfterDate(t *testing.T) {
+
+	defer withNow(time.Date(2018, 1, 1, 10, 0, 0, 0, time.UTC))()
+
+	header := http.Header{}
+	header.Set("Retry-After", "Mon, 01 Jan 2018 10:00:20 GMT")
+	err := responseError(&http.Response{StatusCode: 503, Header: header}, errors.New("unavailable"))
+
+	assert.Equal(t, 20*time.Second, backoff(1, err))
+}
+
+func Test_RateLimitedRetriedAfterReset(t *testing.T) {
+
+	current := time.Date(2018, 1, 1, 10, 0, 0, 0, time.UTC)
+	defer withNow(current)()
+
+	header := http.Header{}
+	header.Set("X-Ratelimit-Reset", strconv.FormatInt(current.Add(40*time.Second).Unix(), 10))
+	err := responseError(&http.Response{StatusCode: 429, Header: header}, errors.New("too many requests"))
+
+	assert.Equal(t, 40*time.Second, backoff(1, err))
+}
+
+func Test_RetryLaterThanMaxBackoffIsNotRetried(t *testing.T) {
+
+	current := time.Date(2018, 1, 1, 10, 0, 0, 0, time.UTC)
+	defer withNow(current)()
+	sleeps := []time.Duration{}
+	defer recordSleepsTo(&sleeps)()
+
+	header := http.Header{}
+	header.Set("X-Ratelimit-Reset", strconv.FormatInt(current.Add(5*time.Minute).Unix(), 10))
+	var attempts = 0
+	err := do(func() (err error) {
+		attempts++
+		return responseError(&http.Response{StatusCode: 429, Header: header}, errors.New("too many requests"))
+	})
+
+	assert.EqualError(t, err, "too many requests")
+	assert.Equal(t, 1, attempts)
+	assert.Equal(t, 0, len(sleeps))
+}
+
+func Test_ResponseErrorWithoutError(t *testing.T) {
+	assert.Nil(t, responseError(&http.Response{StatusCode: 200}, nil))
+}
+
+// transportError is an error returned by http client when request did not get response
+func transportError(msg string) error {
+	return &url.Error{Op: "Post", URL: "https://api.hipchat.com/v2/room/123/notification", Err: errors.New(msg)}
+}
+
+// recordSleeps replaces sleep with no-op, returns function to restore it
+func recordSleeps() func() {
+	return recordSleepsTo(&[]time.Duration{})
+}
+
+func recordSleepsTo(sleeps *[]time.Duration) func() {
+
+	prev := sleep
+	sleep = func(d time.Duration) { *sleeps = append(*sleeps, d) }
+	return func() { sleep = prev }
+}
+
+func withJitter(v float64) func() {
+
+	prev := jitter
+	jitter = func() float64 { return v }
+	return func() { jitter = prev }
+}
+
+func withNow(t time.Time) func() {
+
+	prev := now
+	now = func() time.Time { return t }
+	return func() { now = prev }
+}
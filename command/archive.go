@@ -0,0 +1,86 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HotelsDotCom/flyte-client/flyte"
+)
+
+type ArchiveRoomInput struct {
+	RoomId string `json:"roomId"`
+}
+
+type ArchiveRoomOutput struct {
+	ArchiveRoomInput
+}
+
+type ArchiveRoomErrorOutput struct {
+	ArchiveRoomOutput
+	Error string `json:"error"`
+}
+
+type HipchatRoomArchiver interface {
+	ArchiveRoom(roomId string) error
+}
+
+func ArchiveRoomCommand(hc HipchatRoomArchiver) flyte.Command {
+
+	return flyte.Command{
+		Name:         "ArchiveRoom",
+		OutputEvents: []flyte.EventDef{{Name: "RoomArchived"}, {Name: "ArchiveRoomFailed"}},
+		Handler:      archiveRoomHandler(hc),
+	}
+}
+
+func archiveRoomHandler(hc HipchatRoomArchiver) flyte.CommandHandler {
+
+	return func(rawInput json.RawMessage) flyte.Event {
+
+		input := ArchiveRoomInput{}
+		if err := json.Unmarshal(rawInput, &input); err != nil {
+			return flyte.NewFatalEvent(fmt.Sprintf("input is not valid: %v", err))
+		}
+
+		if input.RoomId == "" {
+			return newArchiveRoomFailedEvent(input.RoomId, "missing room id field")
+		}
+
+		if err := hc.ArchiveRoom(input.RoomId); err != nil {
+			return newArchiveRoomFailedEvent(input.RoomId, fmt.Sprintf("cannot archive room: %v", err))
+		}
+		return newRoomArchivedEvent(input.RoomId)
+	}
+}
+
+func newRoomArchivedEvent(roomId string) flyte.Event {
+
+	return flyte.Event{
+		EventDef: flyte.EventDef{Name: "RoomArchived"},
+		Payload:  ArchiveRoomOutput{ArchiveRoomInput: ArchiveRoomInput{RoomId: roomId}},
+	}
+}
+
+func newArchiveRoomFailedEvent(roomId, err string) flyte.Event {
+
+	output := ArchiveRoomOutput{ArchiveRoomInput: ArchiveRoomInput{RoomId: roomId}}
+	return flyte.Event{
+		EventDef: flyte.EventDef{Name: "ArchiveRoomFailed"},
+		Payload:  ArchiveRoomErrorOutput{ArchiveRoomOutput: output, Error: err},
+	}
+}
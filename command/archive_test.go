@@ -0,0 +1,94 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestArchiveRoom(t *testing.T) {
+
+	hc := NewHipchatRoomArchiverMock()
+	command := ArchiveRoomCommand(hc)
+
+	event := command.Handler([]byte(`{"roomId": "42"}`))
+
+	assert.Equal(t, newRoomArchivedEvent("42"), event)
+	assert.Equal(t, "42", hc.CalledRoomId)
+}
+
+func TestArchiveRoomMissingRoomIdField(t *testing.T) {
+
+	event := ArchiveRoomCommand(NewHipchatRoomArchiverMock()).Handler([]byte(`{}`))
+	assert.Equal(t, newArchiveRoomFailedEvent("", "missing room id field"), event)
+}
+
+func TestArchiveRoomInvalidInput(t *testing.T) {
+
+	event := ArchiveRoomCommand(NewHipchatRoomArchiverMock()).Handler([]byte(`invalid input`))
+	assert.Contains(t, event.Payload.(string), "input is not valid: ")
+}
+
+func TestArchiveRoomFailed(t *testing.T) {
+
+	hc := NewHipchatRoomArchiverMock()
+	hc.archiveRoom = func(string) error { return errors.New("test error") }
+
+	event := ArchiveRoomCommand(hc).Handler([]byte(`{"roomId": "42"}`))
+
+	assert.Equal(t, newArchiveRoomFailedEvent("42", "cannot archive room: test error"), event)
+}
+
+func TestArchiveRoomOutputEventMarshal(t *testing.T) {
+
+	event := ArchiveRoomCommand(NewHipchatRoomArchiverMock()).Handler([]byte(`{"roomId": "42"}`))
+	jsonPayload, _ := json.Marshal(event.Payload)
+
+	assert.Equal(t, `{"roomId":"42"}`, string(jsonPayload))
+}
+
+func TestArchiveRoomCommand(t *testing.T) {
+
+	command := ArchiveRoomCommand(NewHipchatRoomArchiverMock())
+
+	assert.Equal(t, "ArchiveRoom", command.Name)
+	assert.Equal(t, 2, len(command.OutputEvents))
+	assert.Equal(t, "RoomArchived", command.OutputEvents[0].Name)
+	assert.Equal(t, "ArchiveRoomFailed", command.OutputEvents[1].Name)
+}
+
+type HipchatRoomArchiverMock struct {
+	CalledRoomId string
+	archiveRoom  func(string) error
+}
+
+func NewHipchatRoomArchiverMock() *HipchatRoomArchiverMock {
+
+	hc := &HipchatRoomArchiverMock{}
+	hc.archiveRoom = func(roomId string) error {
+		hc.CalledRoomId = roomId
+		return nil
+	}
+	return hc
+}
+
+func (hc *HipchatRoomArchiverMock) ArchiveRoom(roomId string) error {
+	return hc.archiveRoom(roomId)
+}
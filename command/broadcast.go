@@ -20,14 +20,32 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/HotelsDotCom/flyte-client/flyte"
+	"github.com/HotelsDotCom/flyte-hipchat/hipchat"
+	"github.com/HotelsDotCom/flyte-hipchat/templates"
 )
 
 type BroadcastInput struct {
 	Message string `json:"message"`
+	// message is rendered from the template and data if set
+	Template string      `json:"template,omitempty"`
+	Data     interface{} `json:"data,omitempty"`
+	// joined rooms tagged with any of the tags, plus include rooms, are selected; all joined rooms if neither is set
+	Tags         []string `json:"tags,omitempty"`
+	IncludeRooms []string `json:"includeRooms,omitempty"`
+	ExcludeRooms []string `json:"excludeRooms,omitempty"`
 }
 
 type BroadcastOutput struct {
 	BroadcastInput
+	SentRooms   []string     `json:"sentRooms"`
+	FailedRooms []FailedRoom `json:"failedRooms"`
+	// delivery is unknown, retrying the rooms can send the message twice
+	TimedOutRooms []string `json:"timedOutRooms"`
+}
+
+type FailedRoom struct {
+	RoomId string `json:"roomId"`
+	Error  string `json:"error"`
 }
 
 type BroadcastErrorOutput struct {
@@ -36,19 +54,19 @@ type BroadcastErrorOutput struct {
 }
 
 type HipchatBroadcaster interface {
-	BroadcastMessage(message string) error
+	BroadcastMessage(message string, filter hipchat.RoomFilter) (hipchat.BroadcastResult, error)
 }
 
-func BroadcastCommand(hc HipchatBroadcaster) flyte.Command {
+func BroadcastCommand(hc HipchatBroadcaster, t *templates.Templates) flyte.Command {
 
 	return flyte.Command{
 		Name:         "Broadcast",
-		OutputEvents: []flyte.EventDef{{Name: "BroadcastSent"}, {Name: "BroadcastFailed"}},
-		Handler:      broadcastHandler(hc),
+		OutputEvents: []flyte.EventDef{{Name: "BroadcastSent"}, {Name: "BroadcastPartiallyFailed"}, {Name: "BroadcastFailed"}},
+		Handler:      broadcastHandler(hc, t),
 	}
 }
 
-func broadcastHandler(hc HipchatBroadcaster) flyte.CommandHandler {
+func broadcastHandler(hc HipchatBroadcaster, t *templates.Templates) flyte.CommandHandler {
 
 	return func(rawInput json.RawMessage) flyte.Event {
 
@@ -57,28 +75,78 @@ func broadcastHandler(hc HipchatBroadcaster) flyte.CommandHandler {
 			return flyte.NewFatalEvent(fmt.Sprintf("input is not valid: %v", err))
 		}
 
-		if input.Message == "" {
-			return newBroadcastFailedEvent(input.Message, "missing message field")
+		output := BroadcastOutput{BroadcastInput: input, SentRooms: []string{}, FailedRooms: []FailedRoom{}, TimedOutRooms: []string{}}
+		if input.Message == "" && input.Template == "" {
+			return newBroadcastFailedEvent(output, "missing message field")
+		}
+
+		message, err := renderMessage(t, input.Message, input.Template, false, input.Data)
+		if err != nil {
+			return newBroadcastFailedEvent(output, err.Error())
 		}
+		output.Message = message
 
-		if err := hc.BroadcastMessage(input.Message); err != nil {
-			return newBroadcastFailedEvent(input.Message, fmt.Sprintf("error broadcasting message: %v", err))
+		result, err := hc.BroadcastMessage(message, toRoomFilter(input))
+		setBroadcastResult(&output, result)
+		if err != nil {
+			return newBroadcastFailedEvent(output, fmt.Sprintf("error broadcasting message: %v", err))
+		}
+		if len(output.FailedRooms) != 0 || len(output.TimedOutRooms) != 0 {
+			return newBroadcastPartiallyFailedEvent(output)
 		}
-		return newBroadcastEvent(input.Message)
+		return newBroadcastEvent(output)
+	}
+}
+
+func setBroadcastResult(output *BroadcastOutput, result hipchat.BroadcastResult) {
+
+	if result.Sent != nil {
+		output.SentRooms = result.Sent
+	}
+	output.FailedRooms = append(output.FailedRooms, toFailedRooms(result.Failed)...)
+	if result.TimedOut != nil {
+		output.TimedOutRooms = result.TimedOut
 	}
 }
 
-func newBroadcastEvent(message string) flyte.Event {
+func toFailedRooms(roomErrors []hipchat.RoomError) []FailedRoom {
+
+	failed := []FailedRoom{}
+	for _, f := range roomErrors {
+		failed = append(failed, FailedRoom{RoomId: f.RoomId, Error: f.Error})
+	}
+	return failed
+}
+
+func toRoomFilter(input BroadcastInput) hipchat.RoomFilter {
+
+	return hipchat.RoomFilter{
+		Tags:         input.Tags,
+		IncludeRooms: input.IncludeRooms,
+		ExcludeRooms: input.ExcludeRooms,
+	}
+}
+
+func newBroadcastEvent(output BroadcastOutput) flyte.Event {
 
 	return flyte.Event{
 		EventDef: flyte.EventDef{Name: "BroadcastSent"},
-		Payload:  BroadcastOutput{BroadcastInput: BroadcastInput{Message: message}},
+		Payload:  output,
+	}
+}
+
+// newBroadcastPartiallyFailedEvent is returned when the message was sent to some of the rooms, failed rooms can be
+// retried with includeRooms, timed out rooms may have received the message
+func newBroadcastPartiallyFailedEvent(output BroadcastOutput) flyte.Event {
+
+	return flyte.Event{
+		EventDef: flyte.EventDef{Name: "BroadcastPartiallyFailed"},
+		Payload:  output,
 	}
 }
 
-func newBroadcastFailedEvent(message, err string) flyte.Event {
+func newBroadcastFailedEvent(output BroadcastOutput, err string) flyte.Event {
 
-	output := BroadcastOutput{BroadcastInput: BroadcastInput{Message: message}}
 	return flyte.Event{
 		EventDef: flyte.EventDef{Name: "BroadcastFailed"},
 		Payload:  BroadcastErrorOutput{BroadcastOutput: output, Error: err},
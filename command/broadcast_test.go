@@ -21,16 +21,23 @@ import (
 	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/HotelsDotCom/flyte-client/flyte"
+	"github.com/HotelsDotCom/flyte-hipchat/hipchat"
+	"github.com/HotelsDotCom/flyte-hipchat/templates"
 	"testing"
 )
 
 func TestBroadcastMessage(t *testing.T) {
 
 	input := []byte(`{"message": "the message"}`)
-	command := BroadcastCommand(NewHipchatBroadcasterMock())
+	command := BroadcastCommand(NewHipchatBroadcasterMock(), templates.New())
 	event := command.Handler(input)
 
-	payload := BroadcastOutput{BroadcastInput: BroadcastInput{Message: "the message"}}
+	payload := BroadcastOutput{
+		BroadcastInput: BroadcastInput{Message: "the message"},
+		SentRooms:      []string{"1", "2"},
+		FailedRooms:    []FailedRoom{},
+		TimedOutRooms:  []string{},
+	}
 	expected := flyte.Event{EventDef: flyte.EventDef{Name: "BroadcastSent"}, Payload: payload}
 
 	assert.Equal(t, expected, event)
@@ -39,7 +46,7 @@ func TestBroadcastMessage(t *testing.T) {
 func TestBroadcastMessageMissingMessage(t *testing.T) {
 
 	input := []byte(`{}`)
-	command := BroadcastCommand(NewHipchatBroadcasterMock())
+	command := BroadcastCommand(NewHipchatBroadcasterMock(), templates.New())
 	event := command.Handler(input)
 
 	output := event.Payload.(BroadcastErrorOutput)
@@ -49,7 +56,7 @@ func TestBroadcastMessageMissingMessage(t *testing.T) {
 func TestBroadcastMessageInvalidInput(t *testing.T) {
 
 	input := []byte(`invalid input`)
-	command := BroadcastCommand(NewHipchatBroadcasterMock())
+	command := BroadcastCommand(NewHipchatBroadcasterMock(), templates.New())
 	event := command.Handler(input)
 
 	// fatal event
@@ -61,72 +68,157 @@ func TestBroadcastMessageToHipchat(t *testing.T) {
 
 	hc := HipchatBroadcasterMock{}
 	var receivedMessage string
-	hc.broadcastMessage = func(message string) error { receivedMessage = message; return nil }
-
-	input := []byte(`{"message": "the message"}`)
-	command := BroadcastCommand(hc)
+	var receivedFilter hipchat.RoomFilter
+	hc.broadcastMessage = func(message string, filter hipchat.RoomFilter) (hipchat.BroadcastResult, error) {
+		receivedMessage = message
+		receivedFilter = filter
+		return hipchat.BroadcastResult{}, nil
+	}
+
+	input := []byte(`{"message": "the message", "tags": ["ops"], "includeRooms": ["1"], "excludeRooms": ["2"]}`)
+	command := BroadcastCommand(hc, templates.New())
 	command.Handler(input)
 
 	assert.Equal(t, "the message", receivedMessage)
+	expectedFilter := hipchat.RoomFilter{Tags: []string{"ops"}, IncludeRooms: []string{"1"}, ExcludeRooms: []string{"2"}}
+	assert.Equal(t, expectedFilter, receivedFilter)
 }
 
 func TestBroadcastMessageToHipchatFailed(t *testing.T) {
 
 	hc := HipchatBroadcasterMock{}
-	hc.broadcastMessage = func(string) error { return errors.New("test error") }
+	hc.broadcastMessage = func(string, hipchat.RoomFilter) (hipchat.BroadcastResult, error) {
+		failed := []hipchat.RoomError{{RoomId: "2", Error: "Server returns status 500"}}
+		return hipchat.BroadcastResult{Sent: []string{}, Failed: failed}, errors.New("test error")
+	}
 
 	input := []byte(`{"message": "the message"}`)
-	command := BroadcastCommand(hc)
+	command := BroadcastCommand(hc, templates.New())
 	event := command.Handler(input)
 
-	expected := newBroadcastFailedEvent("the message", "error broadcasting message: test error")
+	output := BroadcastOutput{
+		BroadcastInput: BroadcastInput{Message: "the message"},
+		SentRooms:      []string{},
+		FailedRooms:    []FailedRoom{{RoomId: "2", Error: "Server returns status 500"}},
+		TimedOutRooms:  []string{},
+	}
+	expected := newBroadcastFailedEvent(output, "error broadcasting message: test error")
 	assert.Equal(t, expected, event)
 }
 
+func TestBroadcastMessageTemplate(t *testing.T) {
+
+	hc := HipchatBroadcasterMock{}
+	var receivedMessage string
+	hc.broadcastMessage = func(message string, _ hipchat.RoomFilter) (hipchat.BroadcastResult, error) {
+		receivedMessage = message
+		return hipchat.BroadcastResult{Sent: []string{"1"}}, nil
+	}
+
+	input := []byte(`{"template": "release {{.version}}", "data": {"version": "1.2.3"}}`)
+	event := BroadcastCommand(hc, templates.New()).Handler(input)
+
+	assert.Equal(t, "release 1.2.3", receivedMessage)
+	assert.Equal(t, "release 1.2.3", event.Payload.(BroadcastOutput).Message)
+}
+
+func TestBroadcastMessageTemplateFailed(t *testing.T) {
+
+	input := []byte(`{"template": "release {{.version}}", "data": {}}`)
+	event := BroadcastCommand(NewHipchatBroadcasterMock(), templates.New()).Handler(input)
+
+	output := event.Payload.(BroadcastErrorOutput)
+	assert.Contains(t, output.Error, "cannot render template: ")
+}
+
 func TestMarshalSuccessBroadcastOutput(t *testing.T) {
 
 	input := []byte(`{"message": "the message"}`)
-	command := BroadcastCommand(NewHipchatBroadcasterMock())
+	command := BroadcastCommand(NewHipchatBroadcasterMock(), templates.New())
 	event := command.Handler(input)
 
 	jsonEvent, _ := json.Marshal(event.Payload)
-	assert.Equal(t, `{"message":"the message"}`, string(jsonEvent))
+	assert.Equal(t, `{"message":"the message","sentRooms":["1","2"],"failedRooms":[],"timedOutRooms":[]}`, string(jsonEvent))
 }
 
 func TestMarshalErrorOutput(t *testing.T) {
 
 	hc := HipchatBroadcasterMock{}
-	hc.broadcastMessage = func(string) error { return errors.New("the error") }
+	hc.broadcastMessage = func(string, hipchat.RoomFilter) (hipchat.BroadcastResult, error) {
+		return hipchat.BroadcastResult{}, errors.New("the error")
+	}
 
-	input := []byte(`{"message": "the message"}`)
-	command := BroadcastCommand(hc)
+	input := []byte(`{"message": "the message", "tags": ["ops"]}`)
+	command := BroadcastCommand(hc, templates.New())
 	event := command.Handler(input)
 
 	jsonPayload, _ := json.Marshal(event.Payload)
-	assert.Equal(t, `{"message":"the message","error":"error broadcasting message: the error"}`, string(jsonPayload))
+	expected := `{"message":"the message","tags":["ops"],"sentRooms":[],"failedRooms":[],"timedOutRooms":[],"error":"error broadcasting message: the error"}`
+	assert.Equal(t, expected, string(jsonPayload))
+}
+
+func TestBroadcastMessagePartiallyFailed(t *testing.T) {
+
+	hc := HipchatBroadcasterMock{}
+	hc.broadcastMessage = func(string, hipchat.RoomFilter) (hipchat.BroadcastResult, error) {
+		failed := []hipchat.RoomError{{RoomId: "2", Error: "Server returns status 403"}}
+		return hipchat.BroadcastResult{Sent: []string{"1"}, Failed: failed}, nil
+	}
+
+	event := BroadcastCommand(hc, templates.New()).Handler([]byte(`{"message": "the message"}`))
+
+	output := BroadcastOutput{
+		BroadcastInput: BroadcastInput{Message: "the message"},
+		SentRooms:      []string{"1"},
+		FailedRooms:    []FailedRoom{{RoomId: "2", Error: "Server returns status 403"}},
+		TimedOutRooms:  []string{},
+	}
+	assert.Equal(t, newBroadcastPartiallyFailedEvent(output), event)
+
+	jsonPayload, _ := json.Marshal(event.Payload)
+	expected := `{"message":"the message","sentRooms":["1"],"failedRooms":[{"roomId":"2","error":"Server returns status 403"}],"timedOutRooms":[]}`
+	assert.Equal(t, expected, string(jsonPayload))
+}
+
+func TestBroadcastMessageTimedOut(t *testing.T) {
+
+	hc := HipchatBroadcasterMock{}
+	hc.broadcastMessage = func(string, hipchat.RoomFilter) (hipchat.BroadcastResult, error) {
+		return hipchat.BroadcastResult{Sent: []string{"1"}, Failed: []hipchat.RoomError{}, TimedOut: []string{"2"}}, nil
+	}
+
+	event := BroadcastCommand(hc, templates.New()).Handler([]byte(`{"message": "the message"}`))
+
+	assert.Equal(t, "BroadcastPartiallyFailed", event.EventDef.Name)
+	jsonPayload, _ := json.Marshal(event.Payload)
+	expected := `{"message":"the message","sentRooms":["1"],"failedRooms":[],"timedOutRooms":["2"]}`
+	assert.Equal(t, expected, string(jsonPayload))
 }
 
 func TestBroadcastCommand(t *testing.T) {
 
-	command := BroadcastCommand(NewHipchatBroadcasterMock())
+	command := BroadcastCommand(NewHipchatBroadcasterMock(), templates.New())
 
 	assert.Equal(t, "Broadcast", command.Name)
-	assert.Equal(t, 2, len(command.OutputEvents))
+	assert.Equal(t, 3, len(command.OutputEvents))
 	assert.Equal(t, "BroadcastSent", command.OutputEvents[0].Name)
-	assert.Equal(t, "BroadcastFailed", command.OutputEvents[1].Name)
+	assert.Equal(t, "BroadcastPartiallyFailed", command.OutputEvents[1].Name)
+	assert.Equal(t, "BroadcastFailed", command.OutputEvents[2].Name)
 }
 
 type HipchatBroadcasterMock struct {
-	broadcastMessage func(string) error
+	broadcastMessage func(string, hipchat.RoomFilter) (hipchat.BroadcastResult, error)
 }
 
 func NewHipchatBroadcasterMock() HipchatBroadcasterMock {
 
 	hc := HipchatBroadcasterMock{}
-	hc.broadcastMessage = func(string) error { return nil }
+	hc.broadcastMessage = func(string, hipchat.RoomFilter) (hipchat.BroadcastResult, error) {
+		return hipchat.BroadcastResult{Sent: []string{"1", "2"}, Failed: []hipchat.RoomError{}}, nil
+	}
 	return hc
 }
 
-func (hc HipchatBroadcasterMock) BroadcastMessage(message string) error {
-	return hc.broadcastMessage(message)
+func (hc HipchatBroadcasterMock) BroadcastMessage(message string, filter hipchat.RoomFilter) (hipchat.BroadcastResult, error) {
+	return hc.broadcastMessage(message, filter)
 }
@@ -0,0 +1,148 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HotelsDotCom/flyte-client/flyte"
+	"github.com/HotelsDotCom/flyte-hipchat/hipchat"
+	"github.com/HotelsDotCom/flyte-hipchat/templates"
+)
+
+type BroadcastNotificationInput struct {
+	Message       string `json:"message"`
+	MessageFormat string `json:"messageFormat"`
+	Notify        bool   `json:"notify"`
+	Color         string `json:"color"`
+	From          string `json:"from"`
+	Card          *Card  `json:"card,omitempty"`
+	// message is rendered from the template and data if set, html template is used for html message format
+	Template string      `json:"template,omitempty"`
+	Data     interface{} `json:"data,omitempty"`
+	// rooms are selected the same way as in Broadcast
+	Tags         []string `json:"tags,omitempty"`
+	IncludeRooms []string `json:"includeRooms,omitempty"`
+	ExcludeRooms []string `json:"excludeRooms,omitempty"`
+}
+
+type BroadcastNotificationOutput struct {
+	BroadcastNotificationInput
+	SentRooms   []string     `json:"sentRooms"`
+	FailedRooms []FailedRoom `json:"failedRooms"`
+	// delivery is unknown, retrying the rooms can send the notification twice
+	TimedOutRooms []string `json:"timedOutRooms"`
+}
+
+type BroadcastNotificationErrorOutput struct {
+	BroadcastNotificationOutput
+	Error string `json:"error"`
+}
+
+type HipchatNotificationBroadcaster interface {
+	BroadcastNotification(notification hipchat.Notification, filter hipchat.RoomFilter) (hipchat.BroadcastResult, error)
+}
+
+func BroadcastNotificationCommand(hc HipchatNotificationBroadcaster, t *templates.Templates) flyte.Command {
+
+	return flyte.Command{
+		Name: "BroadcastNotification",
+		OutputEvents: []flyte.EventDef{
+			{Name: "NotificationBroadcastSent"},
+			{Name: "BroadcastNotificationPartiallyFailed"},
+			{Name: "BroadcastNotificationFailed"},
+		},
+		Handler: broadcastNotificationHandler(hc, t),
+	}
+}
+
+func broadcastNotificationHandler(hc HipchatNotificationBroadcaster, t *templates.Templates) flyte.CommandHandler {
+
+	return func(rawInput json.RawMessage) flyte.Event {
+
+		input := BroadcastNotificationInput{}
+		if err := json.Unmarshal(rawInput, &input); err != nil {
+			return flyte.NewFatalEvent(fmt.Sprintf("input is not valid: %v", err))
+		}
+
+		output := BroadcastNotificationOutput{
+			BroadcastNotificationInput: input,
+			SentRooms:                  []string{},
+			FailedRooms:                []FailedRoom{},
+			TimedOutRooms:              []string{},
+		}
+		if err := validateNotificationContent([]string{}, input.Message, input.Template, input.From, input.Card); err != nil {
+			return newBroadcastNotificationFailedEvent(output, err.Error())
+		}
+
+		message, err := renderMessage(t, input.Message, input.Template, input.MessageFormat == "html", input.Data)
+		if err != nil {
+			return newBroadcastNotificationFailedEvent(output, err.Error())
+		}
+		output.Message = message
+
+		notification := hipchat.Notification{
+			Message:       message,
+			MessageFormat: input.MessageFormat,
+			Notify:        input.Notify,
+			Color:         input.Color,
+			From:          input.From,
+			Card:          toClientCard(input.Card),
+		}
+		filter := hipchat.RoomFilter{Tags: input.Tags, IncludeRooms: input.IncludeRooms, ExcludeRooms: input.ExcludeRooms}
+
+		result, err := hc.BroadcastNotification(notification, filter)
+		if result.Sent != nil {
+			output.SentRooms = result.Sent
+		}
+		output.FailedRooms = toFailedRooms(result.Failed)
+		if result.TimedOut != nil {
+			output.TimedOutRooms = result.TimedOut
+		}
+		if err != nil {
+			return newBroadcastNotificationFailedEvent(output, fmt.Sprintf("error broadcasting notification: %v", err))
+		}
+		if len(output.FailedRooms) != 0 || len(output.TimedOutRooms) != 0 {
+			return newBroadcastNotificationPartiallyFailedEvent(output)
+		}
+		return newNotificationBroadcastEvent(output)
+	}
+}
+
+func newNotificationBroadcastEvent(output BroadcastNotificationOutput) flyte.Event {
+
+	return flyte.Event{
+		EventDef: flyte.EventDef{Name: "NotificationBroadcastSent"},
+		Payload:  output,
+	}
+}
+
+func newBroadcastNotificationPartiallyFailedEvent(output BroadcastNotificationOutput) flyte.Event {
+
+	return flyte.Event{
+		EventDef: flyte.EventDef{Name: "BroadcastNotificationPartiallyFailed"},
+		Payload:  output,
+	}
+}
+
+func newBroadcastNotificationFailedEvent(output BroadcastNotificationOutput, err string) flyte.Event {
+
+	return flyte.Event{
+		EventDef: flyte.EventDef{Name: "BroadcastNotificationFailed"},
+		Payload:  BroadcastNotificationErrorOutput{BroadcastNotificationOutput: output, Error: err},
+	}
+}
@@ -0,0 +1,165 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/HotelsDotCom/flyte-client/flyte"
+	"github.com/HotelsDotCom/flyte-hipchat/hipchat"
+	"github.com/HotelsDotCom/flyte-hipchat/templates"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBroadcastNotification(t *testing.T) {
+
+	input := []byte(`{"message": "the message", "from": "ci"}`)
+	command := BroadcastNotificationCommand(NewHipchatNotificationBroadcasterMock(), templates.New())
+	event := command.Handler(input)
+
+	payload := BroadcastNotificationOutput{
+		BroadcastNotificationInput: BroadcastNotificationInput{Message: "the message", From: "ci"},
+		SentRooms:                  []string{"1", "2"},
+		FailedRooms:                []FailedRoom{},
+		TimedOutRooms:              []string{},
+	}
+	expected := flyte.Event{EventDef: flyte.EventDef{Name: "NotificationBroadcastSent"}, Payload: payload}
+
+	assert.Equal(t, expected, event)
+}
+
+func TestBroadcastNotificationMissingFields(t *testing.T) {
+
+	command := BroadcastNotificationCommand(NewHipchatNotificationBroadcasterMock(), templates.New())
+	event := command.Handler([]byte(`{}`))
+
+	output := event.Payload.(BroadcastNotificationErrorOutput)
+	assert.Equal(t, "missing fields: [message, from]", output.Error)
+}
+
+func TestBroadcastNotificationInvalidCard(t *testing.T) {
+
+	input := []byte(`{"message": "the message", "from": "ci", "card": {"style": "link", "id": "1"}}`)
+	command := BroadcastNotificationCommand(NewHipchatNotificationBroadcasterMock(), templates.New())
+	event := command.Handler(input)
+
+	output := event.Payload.(BroadcastNotificationErrorOutput)
+	assert.Equal(t, "missing card fields: [title]", output.Error)
+}
+
+func TestBroadcastNotificationInvalidInput(t *testing.T) {
+
+	command := BroadcastNotificationCommand(NewHipchatNotificationBroadcasterMock(), templates.New())
+	event := command.Handler([]byte(`invalid input`))
+
+	// fatal event
+	output := event.Payload.(string)
+	assert.Contains(t, output, "input is not valid")
+}
+
+func TestBroadcastNotificationToHipchat(t *testing.T) {
+
+	hc := HipchatNotificationBroadcasterMock{}
+	var receivedNotification hipchat.Notification
+	var receivedFilter hipchat.RoomFilter
+	hc.broadcastNotification = func(notification hipchat.Notification, filter hipchat.RoomFilter) (hipchat.BroadcastResult, error) {
+		receivedNotification = notification
+		receivedFilter = filter
+		return hipchat.BroadcastResult{}, nil
+	}
+
+	input := []byte(`{"template": "<b>{{.service}}</b> is down", "data": {"service": "a&b"}, "messageFormat": "html",
+		"notify": true, "color": "red", "from": "ci", "card": {"style": "link", "id": "1", "title": "status"},
+		"tags": ["ops"], "includeRooms": ["1"], "excludeRooms": ["2"]}`)
+	event := BroadcastNotificationCommand(hc, templates.New()).Handler(input)
+
+	expectedNotification := hipchat.Notification{
+		Message:       "<b>a&amp;b</b> is down",
+		MessageFormat: "html",
+		Notify:        true,
+		Color:         "red",
+		From:          "ci",
+		Card:          &hipchat.Card{Style: "link", Id: "1", Title: "status"},
+	}
+	assert.Equal(t, expectedNotification, receivedNotification)
+	expectedFilter := hipchat.RoomFilter{Tags: []string{"ops"}, IncludeRooms: []string{"1"}, ExcludeRooms: []string{"2"}}
+	assert.Equal(t, expectedFilter, receivedFilter)
+	assert.Equal(t, "<b>a&amp;b</b> is down", event.Payload.(BroadcastNotificationOutput).Message)
+}
+
+func TestBroadcastNotificationPartiallyFailed(t *testing.T) {
+
+	hc := HipchatNotificationBroadcasterMock{}
+	hc.broadcastNotification = func(hipchat.Notification, hipchat.RoomFilter) (hipchat.BroadcastResult, error) {
+		failed := []hipchat.RoomError{{RoomId: "2", Error: "Server returns status 403"}}
+		return hipchat.BroadcastResult{Sent: []string{"1"}, Failed: failed}, nil
+	}
+
+	event := BroadcastNotificationCommand(hc, templates.New()).Handler([]byte(`{"message": "the message", "from": "ci"}`))
+
+	output := BroadcastNotificationOutput{
+		BroadcastNotificationInput: BroadcastNotificationInput{Message: "the message", From: "ci"},
+		SentRooms:                  []string{"1"},
+		FailedRooms:                []FailedRoom{{RoomId: "2", Error: "Server returns status 403"}},
+		TimedOutRooms:              []string{},
+	}
+	assert.Equal(t, newBroadcastNotificationPartiallyFailedEvent(output), event)
+}
+
+func TestBroadcastNotificationToHipchatFailed(t *testing.T) {
+
+	hc := HipchatNotificationBroadcasterMock{}
+	hc.broadcastNotification = func(hipchat.Notification, hipchat.RoomFilter) (hipchat.BroadcastResult, error) {
+		return hipchat.BroadcastResult{}, errors.New("the error")
+	}
+
+	event := BroadcastNotificationCommand(hc, templates.New()).Handler([]byte(`{"message": "the message", "from": "ci"}`))
+
+	jsonPayload, _ := json.Marshal(event.Payload)
+	expected := `{"message":"the message","messageFormat":"","notify":false,"color":"","from":"ci","sentRooms":[],` +
+		`"failedRooms":[],"timedOutRooms":[],"error":"error broadcasting notification: the error"}`
+	assert.Equal(t, expected, string(jsonPayload))
+}
+
+func TestBroadcastNotificationCommand(t *testing.T) {
+
+	command := BroadcastNotificationCommand(NewHipchatNotificationBroadcasterMock(), templates.New())
+
+	assert.Equal(t, "BroadcastNotification", command.Name)
+	assert.Equal(t, 3, len(command.OutputEvents))
+	assert.Equal(t, "NotificationBroadcastSent", command.OutputEvents[0].Name)
+	assert.Equal(t, "BroadcastNotificationPartiallyFailed", command.OutputEvents[1].Name)
+	assert.Equal(t, "BroadcastNotificationFailed", command.OutputEvents[2].Name)
+}
+
+type HipchatNotificationBroadcasterMock struct {
+	broadcastNotification func(hipchat.Notification, hipchat.RoomFilter) (hipchat.BroadcastResult, error)
+}
+
+func NewHipchatNotificationBroadcasterMock() HipchatNotificationBroadcasterMock {
+
+	hc := HipchatNotificationBroadcasterMock{}
+	hc.broadcastNotification = func(hipchat.Notification, hipchat.RoomFilter) (hipchat.BroadcastResult, error) {
+		return hipchat.BroadcastResult{Sent: []string{"1", "2"}, Failed: []hipchat.RoomError{}}, nil
+	}
+	return hc
+}
+
+func (hc HipchatNotificationBroadcasterMock) BroadcastNotification(notification hipchat.Notification, filter hipchat.RoomFilter) (hipchat.BroadcastResult, error) {
+	return hc.broadcastNotification(notification, filter)
+}
@@ -0,0 +1,140 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HotelsDotCom/flyte-client/flyte"
+	"github.com/HotelsDotCom/flyte-hipchat/hipchat"
+	"unicode/utf8"
+)
+
+// HipChat room name can have at most 50 characters
+const maxRoomNameLength = 50
+
+type CreateRoomInput struct {
+	Name  string `json:"name"`
+	Topic string `json:"topic,omitempty"`
+	// public or private, defaults to public
+	Privacy string `json:"privacy,omitempty"`
+	// user id, email or @mention name, defaults to the pack's user
+	OwnerUserId string `json:"ownerUserId,omitempty"`
+	GuestAccess bool   `json:"guestAccess"`
+	// join the new room, pack starts sending its messages as ReceivedMessage events
+	Join bool `json:"join"`
+}
+
+type CreateRoomOutput struct {
+	CreateRoomInput
+	RoomId string `json:"roomId"`
+	// set if the room was created but could not be joined
+	JoinError string `json:"joinError,omitempty"`
+}
+
+type CreateRoomErrorOutput struct {
+	CreateRoomOutput
+	Error string `json:"error"`
+}
+
+type HipchatRoomCreator interface {
+	CreateRoom(settings hipchat.RoomSettings) (string, error)
+	JoinRoom(roomId string, tags []string) error
+}
+
+func CreateRoomCommand(hc HipchatRoomCreator) flyte.Command {
+
+	return flyte.Command{
+		Name:         "CreateRoom",
+		OutputEvents: []flyte.EventDef{{Name: "RoomCreated"}, {Name: "CreateRoomFailed"}},
+		Handler:      createRoomHandler(hc),
+	}
+}
+
+func createRoomHandler(hc HipchatRoomCreator) flyte.CommandHandler {
+
+	return func(rawInput json.RawMessage) flyte.Event {
+
+		input := CreateRoomInput{}
+		if err := json.Unmarshal(rawInput, &input); err != nil {
+			return flyte.NewFatalEvent(fmt.Sprintf("input is not valid: %v", err))
+		}
+
+		output := CreateRoomOutput{CreateRoomInput: input}
+		if err := validateCreateRoom(input); err != nil {
+			return newCreateRoomFailedEvent(output, err.Error())
+		}
+
+		roomId, err := hc.CreateRoom(toRoomSettings(input))
+		if err != nil {
+			return newCreateRoomFailedEvent(output, fmt.Sprintf("cannot create room: %v", err))
+		}
+		output.RoomId = roomId
+
+		if input.Join {
+			// the room exists, so creating it must not be reported as failed (retried create would fail)
+			if err := hc.JoinRoom(roomId, nil); err != nil {
+				output.JoinError = fmt.Sprintf("cannot join room: %v", err)
+			}
+		}
+		return newRoomCreatedEvent(output)
+	}
+}
+
+func toRoomSettings(input CreateRoomInput) hipchat.RoomSettings {
+
+	return hipchat.RoomSettings{
+		Name:        input.Name,
+		Topic:       input.Topic,
+		Privacy:     input.Privacy,
+		OwnerUserId: input.OwnerUserId,
+		GuestAccess: input.GuestAccess,
+	}
+}
+
+func validateCreateRoom(input CreateRoomInput) error {
+
+	if input.Name == "" {
+		return fmt.Errorf("missing name field")
+	}
+	if utf8.RuneCountInString(input.Name) > maxRoomNameLength {
+		return fmt.Errorf("name is longer than %d characters", maxRoomNameLength)
+	}
+	if utf8.RuneCountInString(input.Topic) > maxTopicLength {
+		return fmt.Errorf("topic is longer than %d characters", maxTopicLength)
+	}
+	if input.Privacy != "" && input.Privacy != "public" && input.Privacy != "private" {
+		return fmt.Errorf("privacy %q is not valid, must be public or private", input.Privacy)
+	}
+	return nil
+}
+
+func newRoomCreatedEvent(output CreateRoomOutput) flyte.Event {
+
+	return flyte.Event{
+		EventDef: flyte.EventDef{Name: "RoomCreated"},
+		Payload:  output,
+	}
+}
+
+func newCreateRoomFailedEvent(output CreateRoomOutput, err string) flyte.Event {
+
+	return flyte.Event{
+		EventDef: flyte.EventDef{Name: "CreateRoomFailed"},
+		Payload:  CreateRoomErrorOutput{CreateRoomOutput: output, Error: err},
+	}
+}
@@ -0,0 +1,160 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/HotelsDotCom/flyte-hipchat/hipchat"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestCreateRoom(t *testing.T) {
+
+	hc := NewHipchatRoomCreatorMock()
+	command := CreateRoomCommand(hc)
+
+	event := command.Handler([]byte(`{"name": "INC-123", "topic": "investigating", "privacy": "private", "ownerUserId": "@jane", "guestAccess": true}`))
+
+	input := CreateRoomInput{Name: "INC-123", Topic: "investigating", Privacy: "private", OwnerUserId: "@jane", GuestAccess: true}
+	expected := newRoomCreatedEvent(CreateRoomOutput{CreateRoomInput: input, RoomId: "42"})
+	assert.Equal(t, expected, event)
+	assert.Equal(t, hipchat.RoomSettings{Name: "INC-123", Topic: "investigating", Privacy: "private", OwnerUserId: "@jane", GuestAccess: true},
+		hc.CalledSettings)
+	assert.Equal(t, "", hc.JoinedRoomId)
+}
+
+func TestCreateRoomAndJoin(t *testing.T) {
+
+	hc := NewHipchatRoomCreatorMock()
+	command := CreateRoomCommand(hc)
+
+	event := command.Handler([]byte(`{"name": "INC-123", "join": true}`))
+
+	expected := newRoomCreatedEvent(CreateRoomOutput{CreateRoomInput: CreateRoomInput{Name: "INC-123", Join: true}, RoomId: "42"})
+	assert.Equal(t, expected, event)
+	assert.Equal(t, "42", hc.JoinedRoomId)
+}
+
+func TestCreateRoomJoinFailed(t *testing.T) {
+
+	hc := NewHipchatRoomCreatorMock()
+	hc.joinRoom = func(string) error { return errors.New("test error") }
+	command := CreateRoomCommand(hc)
+
+	event := command.Handler([]byte(`{"name": "INC-123", "join": true}`))
+
+	output := CreateRoomOutput{
+		CreateRoomInput: CreateRoomInput{Name: "INC-123", Join: true},
+		RoomId:          "42",
+		JoinError:       "cannot join room: test error",
+	}
+	assert.Equal(t, newRoomCreatedEvent(output), event)
+
+	jsonPayload, _ := json.Marshal(event.Payload)
+	assert.Equal(t, `{"name":"INC-123","guestAccess":false,"join":true,"roomId":"42","joinError":"cannot join room: test error"}`,
+		string(jsonPayload))
+}
+
+func TestCreateRoomFailed(t *testing.T) {
+
+	hc := NewHipchatRoomCreatorMock()
+	hc.createRoom = func(hipchat.RoomSettings) (string, error) { return "", errors.New("room name already taken") }
+	command := CreateRoomCommand(hc)
+
+	event := command.Handler([]byte(`{"name": "INC-123", "join": true}`))
+
+	output := CreateRoomOutput{CreateRoomInput: CreateRoomInput{Name: "INC-123", Join: true}}
+	assert.Equal(t, newCreateRoomFailedEvent(output, "cannot create room: room name already taken"), event)
+	assert.Equal(t, "", hc.JoinedRoomId)
+}
+
+func TestCreateRoomInvalidFields(t *testing.T) {
+
+	inputs := map[string]string{
+		`{}`: "missing name field",
+		`{"name": "` + strings.Repeat("a", 51) + `"}`:                      "name is longer than 50 characters",
+		`{"name": "INC-123", "topic": "` + strings.Repeat("a", 251) + `"}`: "topic is longer than 250 characters",
+		`{"name": "INC-123", "privacy": "secret"}`:                         `privacy "secret" is not valid, must be public or private`,
+	}
+
+	for input, expected := range inputs {
+		hc := NewHipchatRoomCreatorMock()
+		event := CreateRoomCommand(hc).Handler([]byte(input))
+
+		assert.Equal(t, "CreateRoomFailed", event.EventDef.Name)
+		assert.Equal(t, expected, event.Payload.(CreateRoomErrorOutput).Error)
+		assert.Equal(t, hipchat.RoomSettings{}, hc.CalledSettings)
+	}
+}
+
+func TestCreateRoomInvalidInput(t *testing.T) {
+
+	event := CreateRoomCommand(NewHipchatRoomCreatorMock()).Handler([]byte(`invalid input`))
+	assert.Contains(t, event.Payload.(string), "input is not valid: ")
+}
+
+func TestCreateRoomOutputEventMarshal(t *testing.T) {
+
+	command := CreateRoomCommand(NewHipchatRoomCreatorMock())
+
+	event := command.Handler([]byte(`{"name": "INC-123"}`))
+	jsonPayload, _ := json.Marshal(event.Payload)
+
+	assert.Equal(t, `{"name":"INC-123","guestAccess":false,"join":false,"roomId":"42"}`, string(jsonPayload))
+}
+
+func TestCreateRoomCommand(t *testing.T) {
+
+	command := CreateRoomCommand(NewHipchatRoomCreatorMock())
+
+	assert.Equal(t, "CreateRoom", command.Name)
+	assert.Equal(t, 2, len(command.OutputEvents))
+	assert.Equal(t, "RoomCreated", command.OutputEvents[0].Name)
+	assert.Equal(t, "CreateRoomFailed", command.OutputEvents[1].Name)
+}
+
+type HipchatRoomCreatorMock struct {
+	CalledSettings hipchat.RoomSettings
+	JoinedRoomId   string
+	createRoom     func(hipchat.RoomSettings) (string, error)
+	joinRoom       func(string) error
+}
+
+func NewHipchatRoomCreatorMock() *HipchatRoomCreatorMock {
+
+	hc := &HipchatRoomCreatorMock{}
+	hc.createRoom = func(settings hipchat.RoomSettings) (string, error) {
+		hc.CalledSettings = settings
+		return "42", nil
+	}
+	hc.joinRoom = func(roomId string) error {
+		hc.JoinedRoomId = roomId
+		return nil
+	}
+	return hc
+}
+
+func (hc *HipchatRoomCreatorMock) CreateRoom(settings hipchat.RoomSettings) (string, error) {
+	return hc.createRoom(settings)
+}
+
+func (hc *HipchatRoomCreatorMock) JoinRoom(roomId string, tags []string) error {
+	return hc.joinRoom(roomId)
+}
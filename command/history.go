@@ -0,0 +1,117 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HotelsDotCom/flyte-client/flyte"
+	"github.com/HotelsDotCom/flyte-hipchat/hipchat"
+	"time"
+)
+
+type GetHistoryInput struct {
+	RoomId string `json:"roomId"`
+	// RFC 3339 dates e.g. 2018-01-02T10:00:00Z
+	Since          string `json:"since,omitempty"`
+	Until          string `json:"until,omitempty"`
+	AfterMessageId string `json:"afterMessageId,omitempty"`
+	MaxResults     int    `json:"maxResults,omitempty"`
+}
+
+type GetHistoryOutput struct {
+	GetHistoryInput
+	Messages []hipchat.Message `json:"messages"`
+}
+
+type GetHistoryErrorOutput struct {
+	GetHistoryInput
+	Error string `json:"error"`
+}
+
+type HipchatHistoryGetter interface {
+	GetHistory(roomId string, query hipchat.HistoryQuery) ([]hipchat.Message, error)
+}
+
+func GetHistoryCommand(hc HipchatHistoryGetter) flyte.Command {
+
+	return flyte.Command{
+		Name:         "GetHistory",
+		OutputEvents: []flyte.EventDef{{Name: "HistoryRetrieved"}, {Name: "GetHistoryFailed"}},
+		Handler:      getHistoryHandler(hc),
+	}
+}
+
+func getHistoryHandler(hc HipchatHistoryGetter) flyte.CommandHandler {
+
+	return func(rawInput json.RawMessage) flyte.Event {
+
+		input := GetHistoryInput{}
+		if err := json.Unmarshal(rawInput, &input); err != nil {
+			return flyte.NewFatalEvent(fmt.Sprintf("input is not valid: %v", err))
+		}
+
+		if input.RoomId == "" {
+			return newGetHistoryFailedEvent(input, "missing room id field")
+		}
+
+		query, err := toHistoryQuery(input)
+		if err != nil {
+			return newGetHistoryFailedEvent(input, err.Error())
+		}
+
+		messages, err := hc.GetHistory(input.RoomId, query)
+		if err != nil {
+			return newGetHistoryFailedEvent(input, fmt.Sprintf("cannot get history: %v", err))
+		}
+		return newHistoryRetrievedEvent(input, messages)
+	}
+}
+
+func toHistoryQuery(input GetHistoryInput) (hipchat.HistoryQuery, error) {
+
+	query := hipchat.HistoryQuery{AfterMessageId: input.AfterMessageId, MaxResults: input.MaxResults}
+
+	var err error
+	if input.Since != "" {
+		if query.Since, err = time.Parse(time.RFC3339, input.Since); err != nil {
+			return query, fmt.Errorf("since is not valid date: %v", err)
+		}
+	}
+	if input.Until != "" {
+		if query.Until, err = time.Parse(time.RFC3339, input.Until); err != nil {
+			return query, fmt.Errorf("until is not valid date: %v", err)
+		}
+	}
+	return query, nil
+}
+
+func newHistoryRetrievedEvent(input GetHistoryInput, messages []hipchat.Message) flyte.Event {
+
+	return flyte.Event{
+		EventDef: flyte.EventDef{Name: "HistoryRetrieved"},
+		Payload:  GetHistoryOutput{GetHistoryInput: input, Messages: messages},
+	}
+}
+
+func newGetHistoryFailedEvent(input GetHistoryInput, err string) flyte.Event {
+
+	return flyte.Event{
+		EventDef: flyte.EventDef{Name: "GetHistoryFailed"},
+		Payload:  GetHistoryErrorOutput{GetHistoryInput: input, Error: err},
+	}
+}
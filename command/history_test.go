@@ -0,0 +1,138 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/HotelsDotCom/flyte-hipchat/hipchat"
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func TestGetHistory(t *testing.T) {
+
+	hc := NewHipchatHistoryGetterMock()
+	command := GetHistoryCommand(hc)
+
+	event := command.Handler([]byte(`{"roomId": "123", "since": "2018-01-01T10:00:00Z", "until": "2018-01-02T10:00:00+01:00", "maxResults": 50}`))
+
+	input := GetHistoryInput{RoomId: "123", Since: "2018-01-01T10:00:00Z", Until: "2018-01-02T10:00:00+01:00", MaxResults: 50}
+	expected := newHistoryRetrievedEvent(input, []hipchat.Message{{Id: "1", RoomId: "123", Message: "hello"}})
+	assert.Equal(t, expected, event)
+
+	assert.Equal(t, "123", hc.CalledRoomId)
+	assert.True(t, time.Date(2018, 1, 1, 10, 0, 0, 0, time.UTC).Equal(hc.CalledQuery.Since))
+	assert.True(t, time.Date(2018, 1, 2, 9, 0, 0, 0, time.UTC).Equal(hc.CalledQuery.Until))
+	assert.Equal(t, 50, hc.CalledQuery.MaxResults)
+}
+
+func TestGetHistoryAfterMessageId(t *testing.T) {
+
+	hc := NewHipchatHistoryGetterMock()
+	command := GetHistoryCommand(hc)
+
+	command.Handler([]byte(`{"roomId": "123", "afterMessageId": "abc"}`))
+
+	assert.Equal(t, hipchat.HistoryQuery{AfterMessageId: "abc"}, hc.CalledQuery)
+}
+
+func TestGetHistoryMissingRoomIdField(t *testing.T) {
+
+	command := GetHistoryCommand(NewHipchatHistoryGetterMock())
+
+	event := command.Handler([]byte(`{"since": "2018-01-01T10:00:00Z"}`))
+
+	expected := newGetHistoryFailedEvent(GetHistoryInput{Since: "2018-01-01T10:00:00Z"}, "missing room id field")
+	assert.Equal(t, expected, event)
+}
+
+func TestGetHistoryInvalidDate(t *testing.T) {
+
+	command := GetHistoryCommand(NewHipchatHistoryGetterMock())
+
+	event := command.Handler([]byte(`{"roomId": "123", "until": "yesterday"}`))
+
+	assert.Equal(t, "GetHistoryFailed", event.EventDef.Name)
+	assert.Contains(t, event.Payload.(GetHistoryErrorOutput).Error, "until is not valid date: ")
+}
+
+func TestGetHistoryInvalidInput(t *testing.T) {
+
+	command := GetHistoryCommand(NewHipchatHistoryGetterMock())
+
+	event := command.Handler([]byte(`invalid input`))
+
+	assert.Contains(t, event.Payload.(string), "input is not valid: ")
+}
+
+func TestGetHistoryFailed(t *testing.T) {
+
+	hc := NewHipchatHistoryGetterMock()
+	hc.getHistory = func(string, hipchat.HistoryQuery) ([]hipchat.Message, error) {
+		return []hipchat.Message{}, errors.New("room not found")
+	}
+	command := GetHistoryCommand(hc)
+
+	event := command.Handler([]byte(`{"roomId": "123"}`))
+
+	expected := newGetHistoryFailedEvent(GetHistoryInput{RoomId: "123"}, "cannot get history: room not found")
+	assert.Equal(t, expected, event)
+}
+
+func TestGetHistoryOutputEventMarshal(t *testing.T) {
+
+	command := GetHistoryCommand(NewHipchatHistoryGetterMock())
+
+	event := command.Handler([]byte(`{"roomId": "123"}`))
+	jsonPayload, _ := json.Marshal(event.Payload)
+
+	assert.Equal(t, `{"roomId":"123","messages":[{"id":"1","roomId":"123","date":"","from":{"id":0,"name":"","mentionName":""},`+
+		`"mentions":null,"message":"hello","messageFormat":"","type":""}]}`, string(jsonPayload))
+}
+
+func TestGetHistoryCommand(t *testing.T) {
+
+	command := GetHistoryCommand(NewHipchatHistoryGetterMock())
+
+	assert.Equal(t, "GetHistory", command.Name)
+	assert.Equal(t, 2, len(command.OutputEvents))
+	assert.Equal(t, "HistoryRetrieved", command.OutputEvents[0].Name)
+	assert.Equal(t, "GetHistoryFailed", command.OutputEvents[1].Name)
+}
+
+type HipchatHistoryGetterMock struct {
+	CalledRoomId string
+	CalledQuery  hipchat.HistoryQuery
+	getHistory   func(string, hipchat.HistoryQuery) ([]hipchat.Message, error)
+}
+
+func NewHipchatHistoryGetterMock() *HipchatHistoryGetterMock {
+
+	hc := &HipchatHistoryGetterMock{}
+	hc.getHistory = func(roomId string, query hipchat.HistoryQuery) ([]hipchat.Message, error) {
+		hc.CalledRoomId = roomId
+		hc.CalledQuery = query
+		return []hipchat.Message{{Id: "1", RoomId: roomId, Message: "hello"}}, nil
+	}
+	return hc
+}
+
+func (hc *HipchatHistoryGetterMock) GetHistory(roomId string, query hipchat.HistoryQuery) ([]hipchat.Message, error) {
+	return hc.getHistory(roomId, query)
+}
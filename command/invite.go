@@ -0,0 +1,105 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HotelsDotCom/flyte-client/flyte"
+	"strings"
+)
+
+type InviteUserInput struct {
+	RoomId string `json:"roomId"`
+	// user id, email or @mention name
+	UserId string `json:"userId"`
+}
+
+type InviteUserOutput struct {
+	InviteUserInput
+}
+
+type InviteUserErrorOutput struct {
+	InviteUserOutput
+	Error string `json:"error"`
+}
+
+type HipchatUserInviter interface {
+	InviteUser(roomId, userId string) error
+}
+
+func InviteUserCommand(hc HipchatUserInviter) flyte.Command {
+
+	return flyte.Command{
+		Name:         "InviteUser",
+		OutputEvents: []flyte.EventDef{{Name: "UserInvited"}, {Name: "InviteUserFailed"}},
+		Handler:      inviteUserHandler(hc),
+	}
+}
+
+func inviteUserHandler(hc HipchatUserInviter) flyte.CommandHandler {
+
+	return func(rawInput json.RawMessage) flyte.Event {
+
+		input := InviteUserInput{}
+		if err := json.Unmarshal(rawInput, &input); err != nil {
+			return flyte.NewFatalEvent(fmt.Sprintf("input is not valid: %v", err))
+		}
+
+		if err := validateMember(input.RoomId, input.UserId); err != nil {
+			return newInviteUserFailedEvent(input, err.Error())
+		}
+
+		if err := hc.InviteUser(input.RoomId, input.UserId); err != nil {
+			return newInviteUserFailedEvent(input, fmt.Sprintf("cannot invite user: %v", err))
+		}
+		return newUserInvitedEvent(input)
+	}
+}
+
+func newUserInvitedEvent(input InviteUserInput) flyte.Event {
+
+	return flyte.Event{
+		EventDef: flyte.EventDef{Name: "UserInvited"},
+		Payload:  InviteUserOutput{InviteUserInput: input},
+	}
+}
+
+func newInviteUserFailedEvent(input InviteUserInput, err string) flyte.Event {
+
+	output := InviteUserOutput{InviteUserInput: input}
+	return flyte.Event{
+		EventDef: flyte.EventDef{Name: "InviteUserFailed"},
+		Payload:  InviteUserErrorOutput{InviteUserOutput: output, Error: err},
+	}
+}
+
+func validateMember(roomId, userId string) error {
+
+	fields := []string{}
+	if roomId == "" {
+		fields = append(fields, "room id")
+	}
+	if userId == "" {
+		fields = append(fields, "user id")
+	}
+
+	if len(fields) != 0 {
+		return fmt.Errorf("missing fields: [%s]", strings.Join(fields, ", "))
+	}
+	return nil
+}
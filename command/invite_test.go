@@ -0,0 +1,97 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestInviteUser(t *testing.T) {
+
+	hc := NewHipchatUserInviterMock()
+	command := InviteUserCommand(hc)
+
+	event := command.Handler([]byte(`{"roomId": "42", "userId": "@jane"}`))
+
+	assert.Equal(t, newUserInvitedEvent(InviteUserInput{RoomId: "42", UserId: "@jane"}), event)
+	assert.Equal(t, "42", hc.CalledRoomId)
+	assert.Equal(t, "@jane", hc.CalledUserId)
+}
+
+func TestInviteUserMissingFields(t *testing.T) {
+
+	event := InviteUserCommand(NewHipchatUserInviterMock()).Handler([]byte(`{}`))
+	assert.Equal(t, newInviteUserFailedEvent(InviteUserInput{}, "missing fields: [room id, user id]"), event)
+}
+
+func TestInviteUserInvalidInput(t *testing.T) {
+
+	event := InviteUserCommand(NewHipchatUserInviterMock()).Handler([]byte(`invalid input`))
+	assert.Contains(t, event.Payload.(string), "input is not valid: ")
+}
+
+func TestInviteUserFailed(t *testing.T) {
+
+	hc := NewHipchatUserInviterMock()
+	hc.inviteUser = func(string, string) error { return errors.New("test error") }
+
+	event := InviteUserCommand(hc).Handler([]byte(`{"roomId": "42", "userId": "jane@example.com"}`))
+
+	assert.Equal(t, newInviteUserFailedEvent(InviteUserInput{RoomId: "42", UserId: "jane@example.com"}, "cannot invite user: test error"), event)
+}
+
+func TestInviteUserOutputEventMarshal(t *testing.T) {
+
+	event := InviteUserCommand(NewHipchatUserInviterMock()).Handler([]byte(`{"roomId": "42", "userId": "@jane"}`))
+	jsonPayload, _ := json.Marshal(event.Payload)
+
+	assert.Equal(t, `{"roomId":"42","userId":"@jane"}`, string(jsonPayload))
+}
+
+func TestInviteUserCommand(t *testing.T) {
+
+	command := InviteUserCommand(NewHipchatUserInviterMock())
+
+	assert.Equal(t, "InviteUser", command.Name)
+	assert.Equal(t, 2, len(command.OutputEvents))
+	assert.Equal(t, "UserInvited", command.OutputEvents[0].Name)
+	assert.Equal(t, "InviteUserFailed", command.OutputEvents[1].Name)
+}
+
+type HipchatUserInviterMock struct {
+	CalledRoomId string
+	CalledUserId string
+	inviteUser   func(string, string) error
+}
+
+func NewHipchatUserInviterMock() *HipchatUserInviterMock {
+
+	hc := &HipchatUserInviterMock{}
+	hc.inviteUser = func(roomId, userId string) error {
+		hc.CalledRoomId = roomId
+		hc.CalledUserId = userId
+		return nil
+	}
+	return hc
+}
+
+func (hc *HipchatUserInviterMock) InviteUser(roomId, userId string) error {
+	return hc.inviteUser(roomId, userId)
+}
@@ -24,6 +24,8 @@ import (
 
 type JoinRoomInput struct {
 	RoomId string `json:"roomId"`
+	// tags select the room for broadcast
+	Tags []string `json:"tags,omitempty"`
 }
 
 type JoinRoomOutput struct {
@@ -36,7 +38,7 @@ type JoinRoomErrorOutput struct {
 }
 
 type HipchatRoomJoiner interface {
-	JoinRoom(roomId string) error
+	JoinRoom(roomId string, tags []string) error
 }
 
 func JoinCommand(hc HipchatRoomJoiner) flyte.Command {
@@ -58,27 +60,27 @@ func joinHandler(hc HipchatRoomJoiner) flyte.CommandHandler {
 		}
 
 		if input.RoomId == "" {
-			return newJoinedFailedEvent(input.RoomId, "missing room id field")
+			return newJoinedFailedEvent(input, "missing room id field")
 		}
 
-		if err := hc.JoinRoom(input.RoomId); err != nil {
-			return newJoinedFailedEvent(input.RoomId, fmt.Sprintf("cannot join room: %v", err))
+		if err := hc.JoinRoom(input.RoomId, input.Tags); err != nil {
+			return newJoinedFailedEvent(input, fmt.Sprintf("cannot join room: %v", err))
 		}
-		return newJoinedEvent(input.RoomId)
+		return newJoinedEvent(input)
 	}
 }
 
-func newJoinedEvent(roomId string) flyte.Event {
+func newJoinedEvent(input JoinRoomInput) flyte.Event {
 
 	return flyte.Event{
 		EventDef: flyte.EventDef{Name: "RoomJoined"},
-		Payload:  JoinRoomOutput{JoinRoomInput: JoinRoomInput{RoomId: roomId}},
+		Payload:  JoinRoomOutput{JoinRoomInput: input},
 	}
 }
 
-func newJoinedFailedEvent(roomId, err string) flyte.Event {
+func newJoinedFailedEvent(input JoinRoomInput, err string) flyte.Event {
 
-	output := JoinRoomOutput{JoinRoomInput: JoinRoomInput{RoomId: roomId}}
+	output := JoinRoomOutput{JoinRoomInput: input}
 	return flyte.Event{
 		EventDef: flyte.EventDef{Name: "JoinRoomFailed"},
 		Payload:  JoinRoomErrorOutput{JoinRoomOutput: output, Error: err},
@@ -32,7 +32,7 @@ func TestGetJoinedEvent(t *testing.T) {
 		Payload:  payload,
 	}
 
-	actual := newJoinedEvent("123")
+	actual := newJoinedEvent(JoinRoomInput{RoomId: "123"})
 
 	assert.Equal(t, expected, actual)
 }
@@ -40,7 +40,7 @@ func TestGetJoinedEvent(t *testing.T) {
 func TestJoin(t *testing.T) {
 
 	command := JoinCommand(NewHipchatRoomJoinerMock())
-	expected := newJoinedEvent("123")
+	expected := newJoinedEvent(JoinRoomInput{RoomId: "123"})
 
 	event := command.Handler([]byte(`{"roomId": "123"}`))
 
@@ -50,7 +50,7 @@ func TestJoin(t *testing.T) {
 func TestJoinMissingRoomIdField(t *testing.T) {
 
 	command := JoinCommand(NewHipchatRoomJoinerMock())
-	expected := newJoinedFailedEvent("", "missing room id field")
+	expected := newJoinedFailedEvent(JoinRoomInput{}, "missing room id field")
 
 	event := command.Handler([]byte(`{}`))
 
@@ -78,11 +78,25 @@ func TestJoinToHipchat(t *testing.T) {
 
 }
 
+func TestJoinWithTags(t *testing.T) {
+
+	hc := NewHipchatRoomJoinerMock()
+
+	event := JoinCommand(hc).Handler([]byte(`{"roomId": "456", "tags": ["ops", "eu"]}`))
+
+	assert.Equal(t, newJoinedEvent(JoinRoomInput{RoomId: "456", Tags: []string{"ops", "eu"}}), event)
+	assert.Equal(t, "456", hc.CalledRoomId)
+	assert.Equal(t, []string{"ops", "eu"}, hc.CalledTags)
+
+	jsonPayload, _ := json.Marshal(event.Payload)
+	assert.Equal(t, `{"roomId":"456","tags":["ops","eu"]}`, string(jsonPayload))
+}
+
 func TestJoinToHipchatFailed(t *testing.T) {
 
 	hc := &HipchatRoomJoinerMock{}
-	hc.joinRoom = func(string) error { return errors.New("test error") }
-	expected := newJoinedFailedEvent("456", "cannot join room: test error")
+	hc.joinRoom = func(string, []string) error { return errors.New("test error") }
+	expected := newJoinedFailedEvent(JoinRoomInput{RoomId: "456"}, "cannot join room: test error")
 
 	command := JoinCommand(hc)
 	event := command.Handler([]byte(`{"roomId": "456"}`))
@@ -112,19 +126,21 @@ func TestJoinCommand(t *testing.T) {
 
 type HipchatRoomJoinerMock struct {
 	CalledRoomId string
-	joinRoom     func(string) error
+	CalledTags   []string
+	joinRoom     func(string, []string) error
 }
 
 func NewHipchatRoomJoinerMock() *HipchatRoomJoinerMock {
 
 	hc := &HipchatRoomJoinerMock{}
-	hc.joinRoom = func(roomId string) error {
+	hc.joinRoom = func(roomId string, tags []string) error {
 		hc.CalledRoomId = roomId
+		hc.CalledTags = tags
 		return nil
 	}
 	return hc
 }
 
-func (hc *HipchatRoomJoinerMock) JoinRoom(roomId string) error {
-	return hc.joinRoom(roomId)
+func (hc *HipchatRoomJoinerMock) JoinRoom(roomId string, tags []string) error {
+	return hc.joinRoom(roomId, tags)
 }
@@ -20,12 +20,16 @@ import (
 	"encoding/json"
 	"fmt"
 	"github.com/HotelsDotCom/flyte-client/flyte"
+	"github.com/HotelsDotCom/flyte-hipchat/templates"
 	"strings"
 )
 
 type SendMessageInput struct {
 	RoomId  string `json:"roomId"`
 	Message string `json:"message"`
+	// message is rendered from the template and data if set
+	Template string      `json:"template,omitempty"`
+	Data     interface{} `json:"data,omitempty"`
 }
 
 type SendMessageOutput struct {
@@ -41,16 +45,16 @@ type HipchatMessageSender interface {
 	SendMessage(roomId string, message string) error
 }
 
-func SendMessageCommand(hc HipchatMessageSender) flyte.Command {
+func SendMessageCommand(hc HipchatMessageSender, t *templates.Templates) flyte.Command {
 
 	return flyte.Command{
 		Name:         "SendMessage",
 		OutputEvents: []flyte.EventDef{{Name: "MessageSent"}, {Name: "SendMessageFailed"}},
-		Handler:      sendMessageHandler(hc),
+		Handler:      sendMessageHandler(hc, t),
 	}
 }
 
-func sendMessageHandler(hc HipchatMessageSender) flyte.CommandHandler {
+func sendMessageHandler(hc HipchatMessageSender, t *templates.Templates) flyte.CommandHandler {
 
 	return func(rawInput json.RawMessage) flyte.Event {
 
@@ -63,10 +67,15 @@ func sendMessageHandler(hc HipchatMessageSender) flyte.CommandHandler {
 			return newSendMessageFailedEvent(input.RoomId, input.Message, err.Error())
 		}
 
-		if err := hc.SendMessage(input.RoomId, input.Message); err != nil {
-			return newSendMessageFailedEvent(input.RoomId, input.Message, fmt.Sprintf("error sending message: %v", err))
+		message, err := renderMessage(t, input.Message, input.Template, false, input.Data)
+		if err != nil {
+			return newSendMessageFailedEvent(input.RoomId, input.Message, err.Error())
+		}
+
+		if err := hc.SendMessage(input.RoomId, message); err != nil {
+			return newSendMessageFailedEvent(input.RoomId, message, fmt.Sprintf("error sending message: %v", err))
 		}
-		return newMessageSentEvent(input.RoomId, input.Message)
+		return newMessageSentEvent(input.RoomId, message)
 	}
 }
 
@@ -76,7 +85,7 @@ func validateMessage(input SendMessageInput) error {
 	if input.RoomId == "" {
 		errors = append(errors, "room id")
 	}
-	if input.Message == "" {
+	if input.Message == "" && input.Template == "" {
 		errors = append(errors, "message")
 	}
 	if len(errors) != 0 {
@@ -21,6 +21,7 @@ import (
 	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/HotelsDotCom/flyte-client/flyte"
+	"github.com/HotelsDotCom/flyte-hipchat/templates"
 	"testing"
 )
 
@@ -61,7 +62,7 @@ func TestSendMessage(t *testing.T) {
 		return nil
 	}
 
-	command := SendMessageCommand(hc)
+	command := SendMessageCommand(hc, templates.New())
 	command.Handler([]byte(`{"roomId": "123", "message": "Hello"}`))
 
 	assert.Equal(t, "123", hcInput.sentRoomId)
@@ -75,7 +76,7 @@ func TestSendMessageFailure(t *testing.T) {
 		return errors.New("the error")
 	}
 
-	command := SendMessageCommand(hc)
+	command := SendMessageCommand(hc, templates.New())
 	event := command.Handler([]byte(`{"roomId": "123", "message": "Hello"}`))
 
 	expectedEvent := newSendMessageFailedEvent("123", "Hello", "error sending message: the error")
@@ -87,7 +88,7 @@ func TestSendMessageFailure(t *testing.T) {
 
 func TestSendMessageSuccessOutputAlsoContainsInput(t *testing.T) {
 
-	command := SendMessageCommand(getHCMock())
+	command := SendMessageCommand(getHCMock(), templates.New())
 	event := command.Handler([]byte(`{"roomId": "123", "message": "Hello"}`))
 
 	expected := newMessageSentEvent("123", "Hello")
@@ -96,7 +97,7 @@ func TestSendMessageSuccessOutputAlsoContainsInput(t *testing.T) {
 
 func TestSendInvalidMessage(t *testing.T) {
 
-	command := SendMessageCommand(getHCMock())
+	command := SendMessageCommand(getHCMock(), templates.New())
 	event := command.Handler([]byte(`invalid message`))
 
 	output := event.Payload.(string)
@@ -105,7 +106,7 @@ func TestSendInvalidMessage(t *testing.T) {
 
 func TestSendNilMessage(t *testing.T) {
 
-	command := SendMessageCommand(getHCMock())
+	command := SendMessageCommand(getHCMock(), templates.New())
 	event := command.Handler(nil)
 
 	output := event.Payload.(string)
@@ -124,7 +125,7 @@ func TestSendMessageWithMissingFields(t *testing.T) {
 		{input: SendMessageInput{RoomId: "the room id", Message: "the message"}, expectedError: ""},
 	}
 
-	command := SendMessageCommand(getHCMock())
+	command := SendMessageCommand(getHCMock(), templates.New())
 	for _, c := range cases {
 		b, _ := json.Marshal(c.input)
 		event := command.Handler(b)
@@ -136,9 +137,50 @@ func TestSendMessageWithMissingFields(t *testing.T) {
 	}
 }
 
+func TestSendMessageTemplate(t *testing.T) {
+
+	var sentMessage string
+	hc := getHCMock()
+	hc.sendMessage = func(roomId string, message string) error {
+		sentMessage = message
+		return nil
+	}
+
+	command := SendMessageCommand(hc, templates.New())
+	event := command.Handler([]byte(`{"roomId": "123", "template": "{{.service}} is {{.status}}", "data": {"service": "api", "status": "down"}}`))
+
+	assert.Equal(t, "api is down", sentMessage)
+	assert.Equal(t, newMessageSentEvent("123", "api is down"), event)
+}
+
+func TestSendMessageTemplateFailed(t *testing.T) {
+
+	cases := []struct {
+		input         string
+		expectedError string // expected error contains
+	}{
+		{`{"roomId": "123", "message": "Hello", "template": "{{.service}}"}`, "message and template cannot be both set"},
+		{`{"roomId": "123", "template": "{{.service}"}`, "cannot render template: template: message:1: "},
+		{`{"roomId": "123", "template": "{{.service}}", "data": {}}`, `map has no entry for key "service"`},
+		{`{"roomId": "123", "template": "{{/* nothing */}}"}`, "template rendered empty message"},
+	}
+
+	for _, c := range cases {
+		sent := false
+		hc := getHCMock()
+		hc.sendMessage = func(string, string) error { sent = true; return nil }
+
+		event := SendMessageCommand(hc, templates.New()).Handler([]byte(c.input))
+
+		assert.Equal(t, "SendMessageFailed", event.EventDef.Name)
+		assert.Contains(t, event.Payload.(SendMessageErrorOutput).Error, c.expectedError)
+		assert.False(t, sent)
+	}
+}
+
 func TestMarshalSendMessageOutput(t *testing.T) {
 
-	command := SendMessageCommand(getHCMock())
+	command := SendMessageCommand(getHCMock(), templates.New())
 	event := command.Handler([]byte(`{"roomId": "123", "message": "Hello"}`))
 	payloadJson, _ := json.Marshal(event.Payload)
 
@@ -147,7 +189,7 @@ func TestMarshalSendMessageOutput(t *testing.T) {
 
 func TestMessageCommand(t *testing.T) {
 
-	command := SendMessageCommand(getHCMock())
+	command := SendMessageCommand(getHCMock(), templates.New())
 
 	assert.Equal(t, "SendMessage", command.Name)
 	assert.Equal(t, 2, len(command.OutputEvents))
@@ -162,7 +204,7 @@ func TestMarshalOutputContainingError(t *testing.T) {
 		return errors.New("the error")
 	}
 
-	command := SendMessageCommand(hc)
+	command := SendMessageCommand(hc, templates.New())
 	event := command.Handler([]byte(`{"roomId": "123", "message": "Hello"}`))
 	payloadJson, _ := json.Marshal(event.Payload) // event is handled by client, only payload is ours
 
@@ -172,7 +214,7 @@ func TestMarshalOutputContainingError(t *testing.T) {
 
 func TestCommandDefinition(t *testing.T) {
 
-	command := SendMessageCommand(nil)
+	command := SendMessageCommand(nil, templates.New())
 
 	assert.Equal(t, "SendMessage", command.Name)
 	assert.Len(t, command.OutputEvents, 2)
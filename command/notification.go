@@ -21,7 +21,9 @@ import (
 	"fmt"
 	"github.com/HotelsDotCom/flyte-client/flyte"
 	"github.com/HotelsDotCom/flyte-hipchat/hipchat"
+	"github.com/HotelsDotCom/flyte-hipchat/templates"
 	"strings"
+	"unicode/utf8"
 )
 
 type SendNotificationInput struct {
@@ -31,8 +33,47 @@ type SendNotificationInput struct {
 	Notify        bool   `json:"notify"`
 	Color         string `json:"color"`
 	From          string `json:"from"`
+	Card          *Card  `json:"card,omitempty"`
+	// message is rendered from the template and data if set, html template is used for html message format
+	Template string      `json:"template,omitempty"`
+	Data     interface{} `json:"data,omitempty"`
 }
 
+type Card struct {
+	Style             string          `json:"style"`
+	Id                string          `json:"id"`
+	Title             string          `json:"title"`
+	Description       string          `json:"description,omitempty"`
+	DescriptionFormat string          `json:"descriptionFormat,omitempty"`
+	Format            string          `json:"format,omitempty"`
+	Url               string          `json:"url,omitempty"`
+	Icon              string          `json:"icon,omitempty"`
+	Thumbnail         string          `json:"thumbnail,omitempty"`
+	Activity          *CardActivity   `json:"activity,omitempty"`
+	Attributes        []CardAttribute `json:"attributes,omitempty"`
+}
+
+type CardActivity struct {
+	Html string `json:"html"`
+	Icon string `json:"icon,omitempty"`
+}
+
+type CardAttribute struct {
+	Label string `json:"label,omitempty"`
+	Value string `json:"value"`
+	Url   string `json:"url,omitempty"`
+	Style string `json:"style,omitempty"`
+	Icon  string `json:"icon,omitempty"`
+}
+
+const maxCardTitleLength = 500
+
+var (
+	cardStyles          = []string{"file", "image", "application", "link", "media"}
+	cardFormats         = []string{"compact", "medium"}
+	cardAttributeStyles = []string{"lozenge", "lozenge-success", "lozenge-error", "lozenge-current", "lozenge-complete", "lozenge-moved"}
+)
+
 type SendNotificationOutput struct {
 	SendNotificationInput
 }
@@ -46,16 +87,16 @@ type HipchatNotificationSender interface {
 	SendNotification(roomId string, notification hipchat.Notification) error
 }
 
-func SendNotificationCommand(hc HipchatNotificationSender) flyte.Command {
+func SendNotificationCommand(hc HipchatNotificationSender, t *templates.Templates) flyte.Command {
 
 	return flyte.Command{
 		Name:         "SendNotification",
 		OutputEvents: []flyte.EventDef{{Name: "NotificationSent"}, {Name: "SendNotificationFailed"}},
-		Handler:      sendNotificationHandler(hc),
+		Handler:      sendNotificationHandler(hc, t),
 	}
 }
 
-func sendNotificationHandler(hc HipchatNotificationSender) flyte.CommandHandler {
+func sendNotificationHandler(hc HipchatNotificationSender, t *templates.Templates) flyte.CommandHandler {
 
 	return func(rawInput json.RawMessage) flyte.Event {
 
@@ -72,6 +113,13 @@ func sendNotificationHandler(hc HipchatNotificationSender) flyte.CommandHandler
 			return newNotificationFailedEvent(output, err.Error())
 		}
 
+		message, err := renderMessage(t, input.Message, input.Template, input.MessageFormat == "html", input.Data)
+		if err != nil {
+			return newNotificationFailedEvent(output, err.Error())
+		}
+		input.Message = message
+		output.Message = message
+
 		if err := hc.SendNotification(input.RoomId, toClientNotification(input)); err != nil {
 			return newNotificationFailedEvent(output, fmt.Sprintf("error sending notification: %v", err))
 		}
@@ -87,28 +135,124 @@ func toClientNotification(input SendNotificationInput) hipchat.Notification {
 		Notify:        input.Notify,
 		Color:         input.Color,
 		From:          input.From,
+		Card:          toClientCard(input.Card),
 	}
 }
 
+func toClientCard(card *Card) *hipchat.Card {
+
+	if card == nil {
+		return nil
+	}
+
+	clientCard := &hipchat.Card{
+		Style:             card.Style,
+		Id:                card.Id,
+		Title:             card.Title,
+		Description:       card.Description,
+		DescriptionFormat: card.DescriptionFormat,
+		Format:            card.Format,
+		Url:               card.Url,
+		Icon:              card.Icon,
+		Thumbnail:         card.Thumbnail,
+	}
+	if card.Activity != nil {
+		clientCard.Activity = &hipchat.CardActivity{Html: card.Activity.Html, Icon: card.Activity.Icon}
+	}
+	for _, a := range card.Attributes {
+		clientCard.Attributes = append(clientCard.Attributes, hipchat.CardAttribute{
+			Label: a.Label,
+			Value: a.Value,
+			Url:   a.Url,
+			Style: a.Style,
+			Icon:  a.Icon,
+		})
+	}
+	return clientCard
+}
+
 func validateNotification(input SendNotificationInput) error {
 
 	fields := []string{}
 	if input.RoomId == "" {
 		fields = append(fields, "room id")
 	}
-	if input.Message == "" {
+	return validateNotificationContent(fields, input.Message, input.Template, input.From, input.Card)
+}
+
+// validateNotificationContent adds missing message and from fields to the already missing fields and validates card
+func validateNotificationContent(fields []string, message, template, from string, card *Card) error {
+
+	if message == "" && template == "" {
 		fields = append(fields, "message")
 	}
-	if input.From == "" {
+	if from == "" {
 		fields = append(fields, "from")
 	}
 
 	if len(fields) != 0 {
 		return fmt.Errorf("missing fields: [%s]", strings.Join(fields, ", "))
 	}
+	if card != nil {
+		return validateCard(*card)
+	}
 	return nil
 }
 
+func validateCard(card Card) error {
+
+	fields := []string{}
+	if card.Style == "" {
+		fields = append(fields, "style")
+	}
+	if card.Id == "" {
+		fields = append(fields, "id")
+	}
+	if card.Title == "" {
+		fields = append(fields, "title")
+	}
+	if card.Activity != nil && card.Activity.Html == "" {
+		fields = append(fields, "activity html")
+	}
+	for i, a := range card.Attributes {
+		if a.Value == "" {
+			fields = append(fields, fmt.Sprintf("attributes[%d] value", i))
+		}
+	}
+
+	if len(fields) != 0 {
+		return fmt.Errorf("missing card fields: [%s]", strings.Join(fields, ", "))
+	}
+	if !contains(cardStyles, card.Style) {
+		return fmt.Errorf("card style %q is not valid, must be one of %q", card.Style, cardStyles)
+	}
+	if card.Format != "" && !contains(cardFormats, card.Format) {
+		return fmt.Errorf("card format %q is not valid, must be one of %q", card.Format, cardFormats)
+	}
+	if card.DescriptionFormat != "" && card.DescriptionFormat != "text" && card.DescriptionFormat != "html" {
+		return fmt.Errorf("card description format %q is not valid, must be text or html", card.DescriptionFormat)
+	}
+	if utf8.RuneCountInString(card.Title) > maxCardTitleLength {
+		return fmt.Errorf("card title is longer than %d characters", maxCardTitleLength)
+	}
+	for i, a := range card.Attributes {
+		if a.Style != "" && !contains(cardAttributeStyles, a.Style) {
+			return fmt.Errorf("card attributes[%d] style %q is not valid, must be one of %q", i, a.Style, cardAttributeStyles)
+		}
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 func newNotificationEvent(output SendNotificationOutput) flyte.Event {
 
 	return flyte.Event{
@@ -21,14 +21,22 @@ import (
 	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/HotelsDotCom/flyte-client/flyte"
+	"github.com/HotelsDotCom/flyte-hipchat/bkp"
+	"github.com/HotelsDotCom/flyte-hipchat/client"
+	"github.com/HotelsDotCom/flyte-hipchat/templates"
 	"github.com/HotelsDotCom/flyte-hipchat/hipchat"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
 	"testing"
 )
 
 func TestSendNotification(t *testing.T) {
 
 	input := []byte(`{"roomId": "room id", "message": "test message", "from": "sender"}`)
-	command := SendNotificationCommand(NewSendNotificationMock())
+	command := SendNotificationCommand(NewSendNotificationMock(), templates.New())
 	event := command.Handler(input)
 
 	expected := newNotificationEvent(SendNotificationOutput{
@@ -40,7 +48,7 @@ func TestSendNotification(t *testing.T) {
 func TestSendNotificationCopiesInputToOutput(t *testing.T) {
 
 	input := []byte(`{"roomId": "123", "message": "message", "from": "sender"}`)
-	command := SendNotificationCommand(NewSendNotificationMock())
+	command := SendNotificationCommand(NewSendNotificationMock(), templates.New())
 	event := command.Handler(input)
 
 	expected := flyte.Event{
@@ -55,7 +63,7 @@ func TestSendNotificationCopiesInputToOutput(t *testing.T) {
 func TestSendNotificationOptionalFields(t *testing.T) {
 
 	input := []byte(`{"roomId": "456", "message": "message", "messageFormat": "html", "notify": true, "color": "blue", "from": "sender"}`)
-	command := SendNotificationCommand(NewSendNotificationMock())
+	command := SendNotificationCommand(NewSendNotificationMock(), templates.New())
 	event := command.Handler(input)
 
 	expected := newNotificationEvent(SendNotificationOutput{
@@ -87,7 +95,7 @@ func TestSendNotificationMissingRequiredFields(t *testing.T) {
 	hc := NewSendNotificationMock()
 	for _, c := range cases {
 
-		command := SendNotificationCommand(hc)
+		command := SendNotificationCommand(hc, templates.New())
 		event := command.Handler(c.input)
 
 		output := event.Payload.(SendNotificationErrorOutput)
@@ -98,7 +106,7 @@ func TestSendNotificationMissingRequiredFields(t *testing.T) {
 func TestSendNotificationInvalidInput(t *testing.T) {
 
 	input := []byte(`invalid message`)
-	command := SendNotificationCommand(NewSendNotificationMock())
+	command := SendNotificationCommand(NewSendNotificationMock(), templates.New())
 	event := command.Handler(input)
 
 	output := event.Payload.(string)
@@ -108,7 +116,7 @@ func TestSendNotificationInvalidInput(t *testing.T) {
 func TestSendNotificationMissingRoomId(t *testing.T) {
 
 	input := []byte(`{"message": "the message", "from": "sender"}`)
-	command := SendNotificationCommand(NewSendNotificationMock())
+	command := SendNotificationCommand(NewSendNotificationMock(), templates.New())
 	event := command.Handler(input)
 
 	output := SendNotificationOutput{
@@ -124,7 +132,7 @@ func TestSendNotificationMissingRoomId(t *testing.T) {
 func TestSendNotificationMissingRoomAndMessage(t *testing.T) {
 
 	input := []byte(`{"from": "sender"}`)
-	command := SendNotificationCommand(NewSendNotificationMock())
+	command := SendNotificationCommand(NewSendNotificationMock(), templates.New())
 	event := command.Handler(input)
 
 	output := SendNotificationOutput{
@@ -142,7 +150,7 @@ func TestSendNotificationToHipchat(t *testing.T) {
 	hc := NewSendNotificationMock()
 
 	input := []byte(`{"roomId": "room id", "message": "test message", "from": "sender", "color": "pink"}`)
-	command := SendNotificationCommand(hc)
+	command := SendNotificationCommand(hc, templates.New())
 	command.Handler(input)
 
 	expected := hipchat.Notification{Message: "test message", From: "sender", Color: "pink"}
@@ -156,7 +164,7 @@ func TestSendNotificationToHipchatFailed(t *testing.T) {
 	hc.sendNotification = func(string, hipchat.Notification) error { return errors.New("test error") }
 
 	input := []byte(`{"roomId": "room id", "message": "test message", "from": "sender"}`)
-	command := SendNotificationCommand(hc)
+	command := SendNotificationCommand(hc, templates.New())
 	event := command.Handler(input)
 
 	output := SendNotificationOutput{
@@ -171,11 +179,153 @@ func TestSendNotificationToHipchatFailed(t *testing.T) {
 	assert.Equal(t, expected, event)
 }
 
+func TestSendNotificationRejectedByHipchatServer(t *testing.T) {
+
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	dir, err := ioutil.TempDir("", "flyte-test-command")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	hipchatClient, err := client.NewHipChatClient([]string{"token"}, client.Options{ApiURL: server.URL + "/v2/"})
+	assert.Nil(t, err)
+	store := bkp.NewFileStore(bkp.CreateBkpFile(dir, "rooms.json"))
+	hc, err := hipchat.NewHipchat(store, hipchatClient, nil, hipchat.Options{})
+	assert.Nil(t, err)
+
+	input := []byte(`{"roomId": "123", "message": "test message", "from": "sender"}`)
+	event := SendNotificationCommand(hc, templates.New()).Handler(input)
+
+	assert.Equal(t, "/v2/room/123/notification", path)
+	assert.Equal(t, "SendNotificationFailed", event.EventDef.Name)
+	output := event.Payload.(SendNotificationErrorOutput)
+	assert.Equal(t, "123", output.RoomId)
+	assert.Contains(t, output.Error, "error sending notification: ")
+}
+
+func TestSendNotificationWithCard(t *testing.T) {
+
+	hc := NewSendNotificationMock()
+
+	input := []byte(`{"roomId": "42", "message": "v1.2.3 deployed", "from": "deployer", "card": {
+		"style": "application", "id": "deploy-42", "title": "Deployment finished", "description": "v1.2.3",
+		"url": "http://deploy/42", "icon": "http://deploy/icon.png", "activity": {"html": "<b>jane</b> deployed"},
+		"attributes": [{"label": "env", "value": "prod", "style": "lozenge-success"}]}}`)
+	event := SendNotificationCommand(hc, templates.New()).Handler(input)
+
+	assert.Equal(t, "NotificationSent", event.EventDef.Name)
+	expected := &hipchat.Card{
+		Style:       "application",
+		Id:          "deploy-42",
+		Title:       "Deployment finished",
+		Description: "v1.2.3",
+		Url:         "http://deploy/42",
+		Icon:        "http://deploy/icon.png",
+		Activity:    &hipchat.CardActivity{Html: "<b>jane</b> deployed"},
+		Attributes:  []hipchat.CardAttribute{{Label: "env", Value: "prod", Style: "lozenge-success"}},
+	}
+	assert.Equal(t, expected, hc.Notification.Card)
+}
+
+func TestSendNotificationInvalidCard(t *testing.T) {
+
+	cases := []struct {
+		card          string
+		expectedError string
+	}{
+		{`{}`, "missing card fields: [style, id, title]"},
+		{`{"style": "link", "id": "1", "title": "t", "activity": {}, "attributes": [{"label": "l"}]}`,
+			"missing card fields: [activity html, attributes[0] value]"},
+		{`{"style": "poster", "id": "1", "title": "t"}`,
+			`card style "poster" is not valid, must be one of ["file" "image" "application" "link" "media"]`},
+		{`{"style": "link", "id": "1", "title": "t", "format": "large"}`,
+			`card format "large" is not valid, must be one of ["compact" "medium"]`},
+		{`{"style": "link", "id": "1", "title": "t", "descriptionFormat": "markdown"}`,
+			`card description format "markdown" is not valid, must be text or html`},
+		{`{"style": "link", "id": "1", "title": "` + strings.Repeat("t", 501) + `"}`,
+			"card title is longer than 500 characters"},
+		{`{"style": "link", "id": "1", "title": "t", "attributes": [{"value": "v", "style": "shiny"}]}`,
+			`card attributes[0] style "shiny" is not valid, must be one of ["lozenge" "lozenge-success" "lozenge-error" "lozenge-current" "lozenge-complete" "lozenge-moved"]`},
+	}
+
+	for _, c := range cases {
+		hc := NewSendNotificationMock()
+		input := []byte(`{"roomId": "42", "message": "message", "from": "sender", "card": ` + c.card + `}`)
+		event := SendNotificationCommand(hc, templates.New()).Handler(input)
+
+		output := event.Payload.(SendNotificationErrorOutput)
+		assert.Equal(t, c.expectedError, output.Error)
+		assert.Equal(t, "", hc.RoomId)
+	}
+}
+
+func TestSendNotificationCardTitleLengthInCharacters(t *testing.T) {
+
+	hc := NewSendNotificationMock()
+	card := `{"style": "link", "id": "1", "title": "` + strings.Repeat("é", 500) + `"}`
+	input := []byte(`{"roomId": "42", "message": "message", "from": "sender", "card": ` + card + `}`)
+
+	event := SendNotificationCommand(hc, templates.New()).Handler(input)
+
+	assert.Equal(t, "NotificationSent", event.EventDef.Name)
+}
+
+func TestSendNotificationTemplate(t *testing.T) {
+
+	cases := []struct {
+		messageFormat   string
+		expectedMessage string
+	}{
+		{"text", "<b>api</b> is <down>"},
+		{"html", "<b>api</b> is &lt;down&gt;"},
+	}
+
+	for _, c := range cases {
+		hc := NewSendNotificationMock()
+		input := []byte(`{"roomId": "42", "from": "alerts", "messageFormat": "` + c.messageFormat + `",
+			"template": "<b>{{.service}}</b> is {{.status}}", "data": {"service": "api", "status": "<down>"}}`)
+
+		event := SendNotificationCommand(hc, templates.New()).Handler(input)
+
+		assert.Equal(t, "NotificationSent", event.EventDef.Name)
+		assert.Equal(t, c.expectedMessage, hc.Notification.Message)
+		assert.Equal(t, c.expectedMessage, event.Payload.(SendNotificationOutput).Message)
+	}
+}
+
+func TestSendNotificationTemplateFailed(t *testing.T) {
+
+	hc := NewSendNotificationMock()
+	input := []byte(`{"roomId": "42", "from": "alerts", "template": "{{template \"alert\" .}}"}`)
+
+	event := SendNotificationCommand(hc, templates.New()).Handler(input)
+
+	output := event.Payload.(SendNotificationErrorOutput)
+	assert.Contains(t, output.Error, "cannot render template: ")
+	assert.Equal(t, "", hc.RoomId)
+}
+
+func TestMarshalOutputEventWithCard(t *testing.T) {
+
+	input := []byte(`{"roomId": "42", "message": "message", "from": "Carl", "card": {"style": "link", "id": "1", "title": "t"}}`)
+
+	event := SendNotificationCommand(NewSendNotificationMock(), templates.New()).Handler(input)
+
+	payloadJson, _ := json.Marshal(event.Payload)
+	expectedPayload := `{"roomId":"42","message":"message","messageFormat":"","notify":false,"color":"","from":"Carl","card":{"style":"link","id":"1","title":"t"}}`
+	assert.Equal(t, expectedPayload, string(payloadJson))
+}
+
 func TestMarshalOutputEvent(t *testing.T) {
 
 	input := []byte(`{"roomId": "room id", "message": "message", "messageFormat": "message format", "notify": true, "color": "red", "from": "Carl"}`)
 
-	command := SendNotificationCommand(NewSendNotificationMock())
+	command := SendNotificationCommand(NewSendNotificationMock(), templates.New())
 	event := command.Handler(input)
 
 	payloadJson, _ := json.Marshal(event.Payload)
@@ -185,7 +335,7 @@ func TestMarshalOutputEvent(t *testing.T) {
 
 func TestNotificationCommand(t *testing.T) {
 
-	command := SendNotificationCommand(NewSendNotificationMock())
+	command := SendNotificationCommand(NewSendNotificationMock(), templates.New())
 
 	assert.Equal(t, "SendNotification", command.Name)
 	assert.Equal(t, 2, len(command.OutputEvents))
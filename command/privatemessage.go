@@ -0,0 +1,120 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HotelsDotCom/flyte-client/flyte"
+	"github.com/HotelsDotCom/flyte-hipchat/hipchat"
+	"strings"
+)
+
+type SendPrivateMessageInput struct {
+	// user id, email or @mention name
+	UserId        string `json:"userId"`
+	Message       string `json:"message"`
+	MessageFormat string `json:"messageFormat"`
+	Notify        bool   `json:"notify"`
+}
+
+type SendPrivateMessageOutput struct {
+	SendPrivateMessageInput
+}
+
+type SendPrivateMessageErrorOutput struct {
+	SendPrivateMessageOutput
+	Error string `json:"error"`
+}
+
+type HipchatPrivateMessageSender interface {
+	SendPrivateMessage(userId string, message hipchat.PrivateMessage) error
+}
+
+func SendPrivateMessageCommand(hc HipchatPrivateMessageSender) flyte.Command {
+
+	return flyte.Command{
+		Name:         "SendPrivateMessage",
+		OutputEvents: []flyte.EventDef{{Name: "PrivateMessageSent"}, {Name: "SendPrivateMessageFailed"}},
+		Handler:      sendPrivateMessageHandler(hc),
+	}
+}
+
+func sendPrivateMessageHandler(hc HipchatPrivateMessageSender) flyte.CommandHandler {
+
+	return func(rawInput json.RawMessage) flyte.Event {
+
+		input := SendPrivateMessageInput{}
+		if err := json.Unmarshal(rawInput, &input); err != nil {
+			return flyte.NewFatalEvent(fmt.Sprintf("input is not valid: %v", err))
+		}
+
+		output := SendPrivateMessageOutput{SendPrivateMessageInput: input}
+		if err := validatePrivateMessage(input); err != nil {
+			return newPrivateMessageFailedEvent(output, err.Error())
+		}
+
+		if err := hc.SendPrivateMessage(input.UserId, toPrivateMessage(input)); err != nil {
+			return newPrivateMessageFailedEvent(output, fmt.Sprintf("error sending private message: %v", err))
+		}
+		return newPrivateMessageSentEvent(output)
+	}
+}
+
+func toPrivateMessage(input SendPrivateMessageInput) hipchat.PrivateMessage {
+
+	return hipchat.PrivateMessage{
+		Message:       input.Message,
+		MessageFormat: input.MessageFormat,
+		Notify:        input.Notify,
+	}
+}
+
+func validatePrivateMessage(input SendPrivateMessageInput) error {
+
+	fields := []string{}
+	if input.UserId == "" {
+		fields = append(fields, "user id")
+	}
+	if input.Message == "" {
+		fields = append(fields, "message")
+	}
+	if len(fields) != 0 {
+		return fmt.Errorf("missing fields: [%s]", strings.Join(fields, ", "))
+	}
+
+	if input.MessageFormat != "" && input.MessageFormat != "text" && input.MessageFormat != "html" {
+		return fmt.Errorf("message format %q is not valid, must be text or html", input.MessageFormat)
+	}
+	return nil
+}
+
+func newPrivateMessageSentEvent(output SendPrivateMessageOutput) flyte.Event {
+
+	return flyte.Event{
+		EventDef: flyte.EventDef{Name: "PrivateMessageSent"},
+		Payload:  output,
+	}
+}
+
+func newPrivateMessageFailedEvent(output SendPrivateMessageOutput, err string) flyte.Event {
+
+	return flyte.Event{
+		EventDef: flyte.EventDef{Name: "SendPrivateMessageFailed"},
+		Payload:  SendPrivateMessageErrorOutput{SendPrivateMessageOutput: output, Error: err},
+	}
+}
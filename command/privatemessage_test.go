@@ -0,0 +1,125 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/HotelsDotCom/flyte-hipchat/hipchat"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSendPrivateMessage(t *testing.T) {
+
+	hc := NewSendPrivateMessageMock()
+	command := SendPrivateMessageCommand(hc)
+
+	event := command.Handler([]byte(`{"userId": "@john", "message": "<b>hi</b>", "messageFormat": "html", "notify": true}`))
+
+	input := SendPrivateMessageInput{UserId: "@john", Message: "<b>hi</b>", MessageFormat: "html", Notify: true}
+	expected := newPrivateMessageSentEvent(SendPrivateMessageOutput{SendPrivateMessageInput: input})
+	assert.Equal(t, expected, event)
+	assert.Equal(t, "@john", hc.CalledUserId)
+	assert.Equal(t, hipchat.PrivateMessage{Message: "<b>hi</b>", MessageFormat: "html", Notify: true}, hc.CalledMessage)
+}
+
+func TestSendPrivateMessageMissingFields(t *testing.T) {
+
+	command := SendPrivateMessageCommand(NewSendPrivateMessageMock())
+
+	event := command.Handler([]byte(`{}`))
+
+	expected := newPrivateMessageFailedEvent(SendPrivateMessageOutput{}, "missing fields: [user id, message]")
+	assert.Equal(t, expected, event)
+}
+
+func TestSendPrivateMessageInvalidFormat(t *testing.T) {
+
+	command := SendPrivateMessageCommand(NewSendPrivateMessageMock())
+
+	event := command.Handler([]byte(`{"userId": "123", "message": "hi", "messageFormat": "markdown"}`))
+
+	output := SendPrivateMessageOutput{
+		SendPrivateMessageInput: SendPrivateMessageInput{UserId: "123", Message: "hi", MessageFormat: "markdown"},
+	}
+	expected := newPrivateMessageFailedEvent(output, `message format "markdown" is not valid, must be text or html`)
+	assert.Equal(t, expected, event)
+}
+
+func TestSendPrivateMessageInvalidInput(t *testing.T) {
+
+	command := SendPrivateMessageCommand(NewSendPrivateMessageMock())
+
+	event := command.Handler([]byte(`invalid input`))
+
+	assert.Contains(t, event.Payload.(string), "input is not valid: ")
+}
+
+func TestSendPrivateMessageFailed(t *testing.T) {
+
+	hc := NewSendPrivateMessageMock()
+	hc.sendPrivateMessage = func(string, hipchat.PrivateMessage) error { return errors.New("user not found") }
+	command := SendPrivateMessageCommand(hc)
+
+	event := command.Handler([]byte(`{"userId": "john@example.com", "message": "hi"}`))
+
+	output := SendPrivateMessageOutput{SendPrivateMessageInput: SendPrivateMessageInput{UserId: "john@example.com", Message: "hi"}}
+	expected := newPrivateMessageFailedEvent(output, "error sending private message: user not found")
+	assert.Equal(t, expected, event)
+}
+
+func TestSendPrivateMessageOutputEventMarshal(t *testing.T) {
+
+	command := SendPrivateMessageCommand(NewSendPrivateMessageMock())
+
+	event := command.Handler([]byte(`{"userId": "123", "message": "hi"}`))
+	jsonPayload, _ := json.Marshal(event.Payload)
+
+	assert.Equal(t, `{"userId":"123","message":"hi","messageFormat":"","notify":false}`, string(jsonPayload))
+}
+
+func TestSendPrivateMessageCommand(t *testing.T) {
+
+	command := SendPrivateMessageCommand(NewSendPrivateMessageMock())
+
+	assert.Equal(t, "SendPrivateMessage", command.Name)
+	assert.Equal(t, 2, len(command.OutputEvents))
+	assert.Equal(t, "PrivateMessageSent", command.OutputEvents[0].Name)
+	assert.Equal(t, "SendPrivateMessageFailed", command.OutputEvents[1].Name)
+}
+
+type SendPrivateMessageMock struct {
+	CalledUserId       string
+	CalledMessage      hipchat.PrivateMessage
+	sendPrivateMessage func(string, hipchat.PrivateMessage) error
+}
+
+func NewSendPrivateMessageMock() *SendPrivateMessageMock {
+
+	hc := &SendPrivateMessageMock{}
+	hc.sendPrivateMessage = func(userId string, message hipchat.PrivateMessage) error {
+		hc.CalledUserId = userId
+		hc.CalledMessage = message
+		return nil
+	}
+	return hc
+}
+
+func (hc *SendPrivateMessageMock) SendPrivateMessage(userId string, message hipchat.PrivateMessage) error {
+	return hc.sendPrivateMessage(userId, message)
+}
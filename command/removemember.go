@@ -0,0 +1,88 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HotelsDotCom/flyte-client/flyte"
+)
+
+type RemoveMemberInput struct {
+	RoomId string `json:"roomId"`
+	// user id, email or @mention name
+	UserId string `json:"userId"`
+}
+
+type RemoveMemberOutput struct {
+	RemoveMemberInput
+}
+
+type RemoveMemberErrorOutput struct {
+	RemoveMemberOutput
+	Error string `json:"error"`
+}
+
+type HipchatMemberRemover interface {
+	RemoveMember(roomId, userId string) error
+}
+
+func RemoveMemberCommand(hc HipchatMemberRemover) flyte.Command {
+
+	return flyte.Command{
+		Name:         "RemoveMember",
+		OutputEvents: []flyte.EventDef{{Name: "MemberRemoved"}, {Name: "RemoveMemberFailed"}},
+		Handler:      removeMemberHandler(hc),
+	}
+}
+
+func removeMemberHandler(hc HipchatMemberRemover) flyte.CommandHandler {
+
+	return func(rawInput json.RawMessage) flyte.Event {
+
+		input := RemoveMemberInput{}
+		if err := json.Unmarshal(rawInput, &input); err != nil {
+			return flyte.NewFatalEvent(fmt.Sprintf("input is not valid: %v", err))
+		}
+
+		if err := validateMember(input.RoomId, input.UserId); err != nil {
+			return newRemoveMemberFailedEvent(input, err.Error())
+		}
+
+		if err := hc.RemoveMember(input.RoomId, input.UserId); err != nil {
+			return newRemoveMemberFailedEvent(input, fmt.Sprintf("cannot remove member: %v", err))
+		}
+		return newMemberRemovedEvent(input)
+	}
+}
+
+func newMemberRemovedEvent(input RemoveMemberInput) flyte.Event {
+
+	return flyte.Event{
+		EventDef: flyte.EventDef{Name: "MemberRemoved"},
+		Payload:  RemoveMemberOutput{RemoveMemberInput: input},
+	}
+}
+
+func newRemoveMemberFailedEvent(input RemoveMemberInput, err string) flyte.Event {
+
+	output := RemoveMemberOutput{RemoveMemberInput: input}
+	return flyte.Event{
+		EventDef: flyte.EventDef{Name: "RemoveMemberFailed"},
+		Payload:  RemoveMemberErrorOutput{RemoveMemberOutput: output, Error: err},
+	}
+}
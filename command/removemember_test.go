@@ -0,0 +1,97 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestRemoveMember(t *testing.T) {
+
+	hc := NewHipchatMemberRemoverMock()
+	command := RemoveMemberCommand(hc)
+
+	event := command.Handler([]byte(`{"roomId": "42", "userId": "@jane"}`))
+
+	assert.Equal(t, newMemberRemovedEvent(RemoveMemberInput{RoomId: "42", UserId: "@jane"}), event)
+	assert.Equal(t, "42", hc.CalledRoomId)
+	assert.Equal(t, "@jane", hc.CalledUserId)
+}
+
+func TestRemoveMemberMissingFields(t *testing.T) {
+
+	event := RemoveMemberCommand(NewHipchatMemberRemoverMock()).Handler([]byte(`{}`))
+	assert.Equal(t, newRemoveMemberFailedEvent(RemoveMemberInput{}, "missing fields: [room id, user id]"), event)
+}
+
+func TestRemoveMemberInvalidInput(t *testing.T) {
+
+	event := RemoveMemberCommand(NewHipchatMemberRemoverMock()).Handler([]byte(`invalid input`))
+	assert.Contains(t, event.Payload.(string), "input is not valid: ")
+}
+
+func TestRemoveMemberFailed(t *testing.T) {
+
+	hc := NewHipchatMemberRemoverMock()
+	hc.removeMember = func(string, string) error { return errors.New("test error") }
+
+	event := RemoveMemberCommand(hc).Handler([]byte(`{"roomId": "42", "userId": "jane@example.com"}`))
+
+	assert.Equal(t, newRemoveMemberFailedEvent(RemoveMemberInput{RoomId: "42", UserId: "jane@example.com"}, "cannot remove member: test error"), event)
+}
+
+func TestRemoveMemberOutputEventMarshal(t *testing.T) {
+
+	event := RemoveMemberCommand(NewHipchatMemberRemoverMock()).Handler([]byte(`{"roomId": "42", "userId": "@jane"}`))
+	jsonPayload, _ := json.Marshal(event.Payload)
+
+	assert.Equal(t, `{"roomId":"42","userId":"@jane"}`, string(jsonPayload))
+}
+
+func TestRemoveMemberCommand(t *testing.T) {
+
+	command := RemoveMemberCommand(NewHipchatMemberRemoverMock())
+
+	assert.Equal(t, "RemoveMember", command.Name)
+	assert.Equal(t, 2, len(command.OutputEvents))
+	assert.Equal(t, "MemberRemoved", command.OutputEvents[0].Name)
+	assert.Equal(t, "RemoveMemberFailed", command.OutputEvents[1].Name)
+}
+
+type HipchatMemberRemoverMock struct {
+	CalledRoomId string
+	CalledUserId string
+	removeMember func(string, string) error
+}
+
+func NewHipchatMemberRemoverMock() *HipchatMemberRemoverMock {
+
+	hc := &HipchatMemberRemoverMock{}
+	hc.removeMember = func(roomId, userId string) error {
+		hc.CalledRoomId = roomId
+		hc.CalledUserId = userId
+		return nil
+	}
+	return hc
+}
+
+func (hc *HipchatMemberRemoverMock) RemoveMember(roomId, userId string) error {
+	return hc.removeMember(roomId, userId)
+}
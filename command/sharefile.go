@@ -0,0 +1,155 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"github.com/HotelsDotCom/flyte-client/flyte"
+	"github.com/HotelsDotCom/flyte-hipchat/hipchat"
+	"net/url"
+	"strings"
+)
+
+type ShareFileInput struct {
+	RoomId   string `json:"roomId"`
+	Filename string `json:"filename"`
+	// base64 encoded file content
+	Content string `json:"content"`
+	// url the file is downloaded from when content is not set
+	Url     string `json:"url"`
+	Message string `json:"message"`
+}
+
+// ShareFileOutput leaves out the content, it can be large
+type ShareFileOutput struct {
+	RoomId   string `json:"roomId"`
+	Filename string `json:"filename"`
+	Url      string `json:"url,omitempty"`
+	Message  string `json:"message"`
+}
+
+type ShareFileErrorOutput struct {
+	ShareFileOutput
+	Error string `json:"error"`
+}
+
+type HipchatFileSharer interface {
+	ShareFile(roomId string, file hipchat.SharedFile) error
+}
+
+func ShareFileCommand(hc HipchatFileSharer) flyte.Command {
+
+	return flyte.Command{
+		Name:         "ShareFile",
+		OutputEvents: []flyte.EventDef{{Name: "FileShared"}, {Name: "ShareFileFailed"}},
+		Handler:      shareFileHandler(hc),
+	}
+}
+
+func shareFileHandler(hc HipchatFileSharer) flyte.CommandHandler {
+
+	return func(rawInput json.RawMessage) flyte.Event {
+
+		input := ShareFileInput{}
+		if err := json.Unmarshal(rawInput, &input); err != nil {
+			return flyte.NewFatalEvent(fmt.Sprintf("input is not valid: %v", err))
+		}
+
+		output := ShareFileOutput{
+			RoomId:   input.RoomId,
+			Filename: input.Filename,
+			Url:      input.Url,
+			Message:  input.Message,
+		}
+
+		file, err := toSharedFile(input)
+		if err != nil {
+			return newShareFileFailedEvent(output, err.Error())
+		}
+
+		if err := hc.ShareFile(input.RoomId, file); err != nil {
+			return newShareFileFailedEvent(output, fmt.Sprintf("cannot share file: %v", err))
+		}
+		return newFileSharedEvent(output)
+	}
+}
+
+func toSharedFile(input ShareFileInput) (hipchat.SharedFile, error) {
+
+	if err := validateShareFile(input); err != nil {
+		return hipchat.SharedFile{}, err
+	}
+
+	file := hipchat.SharedFile{Name: input.Filename, Url: input.Url, Message: input.Message}
+	if input.Content != "" {
+		content, err := base64.StdEncoding.DecodeString(input.Content)
+		if err != nil {
+			return hipchat.SharedFile{}, fmt.Errorf("content is not valid base64: %v", err)
+		}
+		file.Content = content
+	}
+	return file, nil
+}
+
+func validateShareFile(input ShareFileInput) error {
+
+	fields := []string{}
+	if input.RoomId == "" {
+		fields = append(fields, "room id")
+	}
+	if input.Filename == "" {
+		fields = append(fields, "filename")
+	}
+	if input.Content == "" && input.Url == "" {
+		fields = append(fields, "content or url")
+	}
+
+	if len(fields) != 0 {
+		return fmt.Errorf("missing fields: [%s]", strings.Join(fields, ", "))
+	}
+	if input.Content != "" && input.Url != "" {
+		return fmt.Errorf("content and url cannot be both set")
+	}
+	if input.Url != "" && !isHttpUrl(input.Url) {
+		return fmt.Errorf("url %q is not valid, must be http or https url", input.Url)
+	}
+	return nil
+}
+
+func isHttpUrl(rawUrl string) bool {
+
+	u, err := url.Parse(rawUrl)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+func newFileSharedEvent(output ShareFileOutput) flyte.Event {
+
+	return flyte.Event{
+		EventDef: flyte.EventDef{Name: "FileShared"},
+		Payload:  output,
+	}
+}
+
+func newShareFileFailedEvent(output ShareFileOutput, err string) flyte.Event {
+
+	return flyte.Event{
+		EventDef: flyte.EventDef{Name: "ShareFileFailed"},
+		Payload:  ShareFileErrorOutput{ShareFileOutput: output, Error: err},
+	}
+}
@@ -0,0 +1,130 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/HotelsDotCom/flyte-hipchat/hipchat"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestShareFileContent(t *testing.T) {
+
+	hc := NewHipchatFileSharerMock()
+	input := []byte(`{"roomId": "42", "filename": "report.csv", "content": "YSxiCjEsMgo=", "message": "daily report"}`)
+
+	event := ShareFileCommand(hc).Handler(input)
+
+	expected := newFileSharedEvent(ShareFileOutput{RoomId: "42", Filename: "report.csv", Message: "daily report"})
+	assert.Equal(t, expected, event)
+	assert.Equal(t, "42", hc.RoomId)
+	assert.Equal(t, hipchat.SharedFile{Name: "report.csv", Content: []byte("a,b\n1,2\n"), Message: "daily report"}, hc.File)
+}
+
+func TestShareFileUrl(t *testing.T) {
+
+	hc := NewHipchatFileSharerMock()
+	input := []byte(`{"roomId": "42", "filename": "graph.png", "url": "https://graphs/1.png"}`)
+
+	event := ShareFileCommand(hc).Handler(input)
+
+	expected := newFileSharedEvent(ShareFileOutput{RoomId: "42", Filename: "graph.png", Url: "https://graphs/1.png"})
+	assert.Equal(t, expected, event)
+	assert.Equal(t, hipchat.SharedFile{Name: "graph.png", Url: "https://graphs/1.png"}, hc.File)
+}
+
+func TestShareFileInvalidFields(t *testing.T) {
+
+	cases := []struct {
+		input         string
+		expectedError string
+	}{
+		{`{}`, "missing fields: [room id, filename, content or url]"},
+		{`{"roomId": "42", "filename": "f"}`, "missing fields: [content or url]"},
+		{`{"roomId": "42", "filename": "f", "content": "YQ==", "url": "http://f"}`, "content and url cannot be both set"},
+		{`{"roomId": "42", "filename": "f", "url": "file:///etc/passwd"}`, `url "file:///etc/passwd" is not valid, must be http or https url`},
+		{`{"roomId": "42", "filename": "f", "content": "not base64"}`, "content is not valid base64: illegal base64 data at input byte 3"},
+	}
+
+	for _, c := range cases {
+		hc := NewHipchatFileSharerMock()
+		event := ShareFileCommand(hc).Handler([]byte(c.input))
+
+		assert.Equal(t, "ShareFileFailed", event.EventDef.Name)
+		assert.Equal(t, c.expectedError, event.Payload.(ShareFileErrorOutput).Error)
+		assert.Equal(t, "", hc.RoomId)
+	}
+}
+
+func TestShareFileInvalidInput(t *testing.T) {
+
+	event := ShareFileCommand(NewHipchatFileSharerMock()).Handler([]byte(`invalid input`))
+	assert.Contains(t, event.Payload.(string), "input is not valid: ")
+}
+
+func TestShareFileFailed(t *testing.T) {
+
+	hc := NewHipchatFileSharerMock()
+	hc.shareFile = func(string, hipchat.SharedFile) error { return errors.New("test error") }
+
+	event := ShareFileCommand(hc).Handler([]byte(`{"roomId": "42", "filename": "f", "content": "YQ=="}`))
+
+	expected := newShareFileFailedEvent(ShareFileOutput{RoomId: "42", Filename: "f"}, "cannot share file: test error")
+	assert.Equal(t, expected, event)
+}
+
+func TestShareFileOutputEventMarshal(t *testing.T) {
+
+	input := []byte(`{"roomId": "42", "filename": "report.csv", "content": "YSxiCjEsMgo=", "message": "daily report"}`)
+	event := ShareFileCommand(NewHipchatFileSharerMock()).Handler(input)
+
+	jsonPayload, _ := json.Marshal(event.Payload)
+	assert.Equal(t, `{"roomId":"42","filename":"report.csv","message":"daily report"}`, string(jsonPayload))
+}
+
+func TestShareFileCommand(t *testing.T) {
+
+	command := ShareFileCommand(NewHipchatFileSharerMock())
+
+	assert.Equal(t, "ShareFile", command.Name)
+	assert.Equal(t, 2, len(command.OutputEvents))
+	assert.Equal(t, "FileShared", command.OutputEvents[0].Name)
+	assert.Equal(t, "ShareFileFailed", command.OutputEvents[1].Name)
+}
+
+type HipchatFileSharerMock struct {
+	RoomId    string
+	File      hipchat.SharedFile
+	shareFile func(roomId string, file hipchat.SharedFile) error
+}
+
+func NewHipchatFileSharerMock() *HipchatFileSharerMock {
+
+	hc := &HipchatFileSharerMock{}
+	hc.shareFile = func(roomId string, file hipchat.SharedFile) error {
+		hc.RoomId = roomId
+		hc.File = file
+		return nil
+	}
+	return hc
+}
+
+func (hc *HipchatFileSharerMock) ShareFile(roomId string, file hipchat.SharedFile) error {
+	return hc.shareFile(roomId, file)
+}
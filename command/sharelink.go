@@ -0,0 +1,108 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HotelsDotCom/flyte-client/flyte"
+	"strings"
+)
+
+type ShareLinkInput struct {
+	RoomId  string `json:"roomId"`
+	Link    string `json:"link"`
+	Message string `json:"message"`
+}
+
+type ShareLinkOutput struct {
+	ShareLinkInput
+}
+
+type ShareLinkErrorOutput struct {
+	ShareLinkOutput
+	Error string `json:"error"`
+}
+
+type HipchatLinkSharer interface {
+	ShareLink(roomId, link, message string) error
+}
+
+func ShareLinkCommand(hc HipchatLinkSharer) flyte.Command {
+
+	return flyte.Command{
+		Name:         "ShareLink",
+		OutputEvents: []flyte.EventDef{{Name: "LinkShared"}, {Name: "ShareLinkFailed"}},
+		Handler:      shareLinkHandler(hc),
+	}
+}
+
+func shareLinkHandler(hc HipchatLinkSharer) flyte.CommandHandler {
+
+	return func(rawInput json.RawMessage) flyte.Event {
+
+		input := ShareLinkInput{}
+		if err := json.Unmarshal(rawInput, &input); err != nil {
+			return flyte.NewFatalEvent(fmt.Sprintf("input is not valid: %v", err))
+		}
+
+		output := ShareLinkOutput{ShareLinkInput: input}
+		if err := validateShareLink(input); err != nil {
+			return newShareLinkFailedEvent(output, err.Error())
+		}
+
+		if err := hc.ShareLink(input.RoomId, input.Link, input.Message); err != nil {
+			return newShareLinkFailedEvent(output, fmt.Sprintf("cannot share link: %v", err))
+		}
+		return newLinkSharedEvent(output)
+	}
+}
+
+func validateShareLink(input ShareLinkInput) error {
+
+	fields := []string{}
+	if input.RoomId == "" {
+		fields = append(fields, "room id")
+	}
+	if input.Link == "" {
+		fields = append(fields, "link")
+	}
+
+	if len(fields) != 0 {
+		return fmt.Errorf("missing fields: [%s]", strings.Join(fields, ", "))
+	}
+	if !isHttpUrl(input.Link) {
+		return fmt.Errorf("link %q is not valid, must be http or https url", input.Link)
+	}
+	return nil
+}
+
+func newLinkSharedEvent(output ShareLinkOutput) flyte.Event {
+
+	return flyte.Event{
+		EventDef: flyte.EventDef{Name: "LinkShared"},
+		Payload:  output,
+	}
+}
+
+func newShareLinkFailedEvent(output ShareLinkOutput, err string) flyte.Event {
+
+	return flyte.Event{
+		EventDef: flyte.EventDef{Name: "ShareLinkFailed"},
+		Payload:  ShareLinkErrorOutput{ShareLinkOutput: output, Error: err},
+	}
+}
@@ -0,0 +1,111 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestShareLink(t *testing.T) {
+
+	hc := NewHipchatLinkSharerMock()
+	input := []byte(`{"roomId": "42", "link": "https://ci/builds/7", "message": "build 7 passed"}`)
+
+	event := ShareLinkCommand(hc).Handler(input)
+
+	expectedInput := ShareLinkInput{RoomId: "42", Link: "https://ci/builds/7", Message: "build 7 passed"}
+	assert.Equal(t, newLinkSharedEvent(ShareLinkOutput{ShareLinkInput: expectedInput}), event)
+	assert.Equal(t, []string{"42", "https://ci/builds/7", "build 7 passed"}, hc.Call)
+}
+
+func TestShareLinkInvalidFields(t *testing.T) {
+
+	cases := []struct {
+		input         string
+		expectedError string
+	}{
+		{`{}`, "missing fields: [room id, link]"},
+		{`{"link": "https://ci"}`, "missing fields: [room id]"},
+		{`{"roomId": "42", "link": "ci/builds/7"}`, `link "ci/builds/7" is not valid, must be http or https url`},
+	}
+
+	for _, c := range cases {
+		hc := NewHipchatLinkSharerMock()
+		event := ShareLinkCommand(hc).Handler([]byte(c.input))
+
+		assert.Equal(t, "ShareLinkFailed", event.EventDef.Name)
+		assert.Equal(t, c.expectedError, event.Payload.(ShareLinkErrorOutput).Error)
+		assert.Nil(t, hc.Call)
+	}
+}
+
+func TestShareLinkInvalidInput(t *testing.T) {
+
+	event := ShareLinkCommand(NewHipchatLinkSharerMock()).Handler([]byte(`invalid input`))
+	assert.Contains(t, event.Payload.(string), "input is not valid: ")
+}
+
+func TestShareLinkFailed(t *testing.T) {
+
+	hc := NewHipchatLinkSharerMock()
+	hc.shareLink = func(string, string, string) error { return errors.New("test error") }
+
+	event := ShareLinkCommand(hc).Handler([]byte(`{"roomId": "42", "link": "http://ci"}`))
+
+	output := ShareLinkOutput{ShareLinkInput: ShareLinkInput{RoomId: "42", Link: "http://ci"}}
+	assert.Equal(t, newShareLinkFailedEvent(output, "cannot share link: test error"), event)
+}
+
+func TestShareLinkOutputEventMarshal(t *testing.T) {
+
+	event := ShareLinkCommand(NewHipchatLinkSharerMock()).Handler([]byte(`{"roomId": "42", "link": "http://ci", "message": "m"}`))
+
+	jsonPayload, _ := json.Marshal(event.Payload)
+	assert.Equal(t, `{"roomId":"42","link":"http://ci","message":"m"}`, string(jsonPayload))
+}
+
+func TestShareLinkCommand(t *testing.T) {
+
+	command := ShareLinkCommand(NewHipchatLinkSharerMock())
+
+	assert.Equal(t, "ShareLink", command.Name)
+	assert.Equal(t, 2, len(command.OutputEvents))
+	assert.Equal(t, "LinkShared", command.OutputEvents[0].Name)
+	assert.Equal(t, "ShareLinkFailed", command.OutputEvents[1].Name)
+}
+
+type HipchatLinkSharerMock struct {
+	Call      []string
+	shareLink func(roomId, link, message string) error
+}
+
+func NewHipchatLinkSharerMock() *HipchatLinkSharerMock {
+
+	hc := &HipchatLinkSharerMock{}
+	hc.shareLink = func(roomId, link, message string) error {
+		hc.Call = []string{roomId, link, message}
+		return nil
+	}
+	return hc
+}
+
+func (hc *HipchatLinkSharerMock) ShareLink(roomId, link, message string) error {
+	return hc.shareLink(roomId, link, message)
+}
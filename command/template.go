@@ -0,0 +1,43 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"errors"
+	"fmt"
+	"github.com/HotelsDotCom/flyte-hipchat/templates"
+)
+
+// renderMessage returns message, or message rendered from the template if template is set
+func renderMessage(t *templates.Templates, message, template string, html bool, data interface{}) (string, error) {
+
+	if template == "" {
+		return message, nil
+	}
+	if message != "" {
+		return "", errors.New("message and template cannot be both set")
+	}
+
+	rendered, err := t.Render(template, html, data)
+	if err != nil {
+		return "", fmt.Errorf("cannot render template: %v", err)
+	}
+	if rendered == "" {
+		return "", errors.New("template rendered empty message")
+	}
+	return rendered, nil
+}
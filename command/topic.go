@@ -0,0 +1,111 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/HotelsDotCom/flyte-client/flyte"
+	"strings"
+	"unicode/utf8"
+)
+
+// HipChat room topic can have at most 250 characters
+const maxTopicLength = 250
+
+type SetRoomTopicInput struct {
+	RoomId string `json:"roomId"`
+	Topic  string `json:"topic"`
+}
+
+type SetRoomTopicOutput struct {
+	SetRoomTopicInput
+}
+
+type SetRoomTopicErrorOutput struct {
+	SetRoomTopicOutput
+	Error string `json:"error"`
+}
+
+type HipchatRoomTopicSetter interface {
+	SetRoomTopic(roomId, topic string) error
+}
+
+func SetRoomTopicCommand(hc HipchatRoomTopicSetter) flyte.Command {
+
+	return flyte.Command{
+		Name:         "SetRoomTopic",
+		OutputEvents: []flyte.EventDef{{Name: "RoomTopicSet"}, {Name: "SetRoomTopicFailed"}},
+		Handler:      setRoomTopicHandler(hc),
+	}
+}
+
+func setRoomTopicHandler(hc HipchatRoomTopicSetter) flyte.CommandHandler {
+
+	return func(rawInput json.RawMessage) flyte.Event {
+
+		input := SetRoomTopicInput{}
+		if err := json.Unmarshal(rawInput, &input); err != nil {
+			return flyte.NewFatalEvent(fmt.Sprintf("input is not valid: %v", err))
+		}
+
+		if err := validateTopic(input); err != nil {
+			return newRoomTopicFailedEvent(input, err.Error())
+		}
+
+		if err := hc.SetRoomTopic(input.RoomId, input.Topic); err != nil {
+			return newRoomTopicFailedEvent(input, fmt.Sprintf("cannot set room topic: %v", err))
+		}
+		return newRoomTopicSetEvent(input)
+	}
+}
+
+func validateTopic(input SetRoomTopicInput) error {
+
+	fields := []string{}
+	if input.RoomId == "" {
+		fields = append(fields, "room id")
+	}
+	if input.Topic == "" {
+		fields = append(fields, "topic")
+	}
+	if len(fields) != 0 {
+		return fmt.Errorf("missing fields: [%s]", strings.Join(fields, ", "))
+	}
+
+	if utf8.RuneCountInString(input.Topic) > maxTopicLength {
+		return fmt.Errorf("topic is longer than %d characters", maxTopicLength)
+	}
+	return nil
+}
+
+func newRoomTopicSetEvent(input SetRoomTopicInput) flyte.Event {
+
+	return flyte.Event{
+		EventDef: flyte.EventDef{Name: "RoomTopicSet"},
+		Payload:  SetRoomTopicOutput{SetRoomTopicInput: input},
+	}
+}
+
+func newRoomTopicFailedEvent(input SetRoomTopicInput, err string) flyte.Event {
+
+	output := SetRoomTopicOutput{SetRoomTopicInput: input}
+	return flyte.Event{
+		EventDef: flyte.EventDef{Name: "SetRoomTopicFailed"},
+		Payload:  SetRoomTopicErrorOutput{SetRoomTopicOutput: output, Error: err},
+	}
+}
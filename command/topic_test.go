@@ -0,0 +1,122 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package command
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestSetRoomTopic(t *testing.T) {
+
+	hc := NewHipchatRoomTopicSetterMock()
+	command := SetRoomTopicCommand(hc)
+
+	event := command.Handler([]byte(`{"roomId": "123", "topic": "INC-123 in progress, owner @jane"}`))
+
+	expected := newRoomTopicSetEvent(SetRoomTopicInput{RoomId: "123", Topic: "INC-123 in progress, owner @jane"})
+	assert.Equal(t, expected, event)
+	assert.Equal(t, "123", hc.CalledRoomId)
+	assert.Equal(t, "INC-123 in progress, owner @jane", hc.CalledTopic)
+}
+
+func TestSetRoomTopicMissingFields(t *testing.T) {
+
+	command := SetRoomTopicCommand(NewHipchatRoomTopicSetterMock())
+
+	event := command.Handler([]byte(`{}`))
+
+	assert.Equal(t, newRoomTopicFailedEvent(SetRoomTopicInput{}, "missing fields: [room id, topic]"), event)
+}
+
+func TestSetRoomTopicTooLong(t *testing.T) {
+
+	hc := NewHipchatRoomTopicSetterMock()
+	command := SetRoomTopicCommand(hc)
+	topic := strings.Repeat("ü", 251)
+
+	event := command.Handler([]byte(`{"roomId": "123", "topic": "` + topic + `"}`))
+
+	expected := newRoomTopicFailedEvent(SetRoomTopicInput{RoomId: "123", Topic: topic}, "topic is longer than 250 characters")
+	assert.Equal(t, expected, event)
+	assert.Equal(t, "", hc.CalledRoomId)
+}
+
+func TestSetRoomTopicInvalidInput(t *testing.T) {
+
+	command := SetRoomTopicCommand(NewHipchatRoomTopicSetterMock())
+
+	event := command.Handler([]byte(`invalid input`))
+
+	assert.Contains(t, event.Payload.(string), "input is not valid: ")
+}
+
+func TestSetRoomTopicFailed(t *testing.T) {
+
+	hc := NewHipchatRoomTopicSetterMock()
+	hc.setRoomTopic = func(string, string) error { return errors.New("room not found") }
+	command := SetRoomTopicCommand(hc)
+
+	event := command.Handler([]byte(`{"roomId": "123", "topic": "topic"}`))
+
+	expected := newRoomTopicFailedEvent(SetRoomTopicInput{RoomId: "123", Topic: "topic"}, "cannot set room topic: room not found")
+	assert.Equal(t, expected, event)
+}
+
+func TestSetRoomTopicOutputEventMarshal(t *testing.T) {
+
+	command := SetRoomTopicCommand(NewHipchatRoomTopicSetterMock())
+
+	event := command.Handler([]byte(`{"roomId": "123", "topic": "topic"}`))
+	jsonPayload, _ := json.Marshal(event.Payload)
+
+	assert.Equal(t, `{"roomId":"123","topic":"topic"}`, string(jsonPayload))
+}
+
+func TestSetRoomTopicCommand(t *testing.T) {
+
+	command := SetRoomTopicCommand(NewHipchatRoomTopicSetterMock())
+
+	assert.Equal(t, "SetRoomTopic", command.Name)
+	assert.Equal(t, 2, len(command.OutputEvents))
+	assert.Equal(t, "RoomTopicSet", command.OutputEvents[0].Name)
+	assert.Equal(t, "SetRoomTopicFailed", command.OutputEvents[1].Name)
+}
+
+type HipchatRoomTopicSetterMock struct {
+	CalledRoomId string
+	CalledTopic  string
+	setRoomTopic func(string, string) error
+}
+
+func NewHipchatRoomTopicSetterMock() *HipchatRoomTopicSetterMock {
+
+	hc := &HipchatRoomTopicSetterMock{}
+	hc.setRoomTopic = func(roomId, topic string) error {
+		hc.CalledRoomId = roomId
+		hc.CalledTopic = topic
+		return nil
+	}
+	return hc
+}
+
+func (hc *HipchatRoomTopicSetterMock) SetRoomTopic(roomId, topic string) error {
+	return hc.setRoomTopic(roomId, topic)
+}
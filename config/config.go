@@ -17,10 +17,13 @@ limitations under the License.
 package config
 
 import (
+	"errors"
 	"net/url"
 	"os"
+	"strconv"
 	"github.com/HotelsDotCom/go-logger"
 	"strings"
+	"time"
 )
 
 func ApiHost() *url.URL {
@@ -33,24 +36,210 @@ func ApiHost() *url.URL {
 	return host
 }
 
+// HipchatAuthTokens returns tokens without per token API url, see HipchatTokenApiUrls
 func HipchatAuthTokens() []string {
 
-	tokensEnv := getEnv("HIPCHAT_TOKENS", true)
 	tokens := []string{}
+	for _, t := range hipchatTokens() {
+		tokens = append(tokens, t[0])
+	}
+	return tokens
+}
+
+// HipchatTokenApiUrls returns API urls set for specific tokens, HIPCHAT_TOKENS=token_abc=https://hipchat.example.com/v2/
+func HipchatTokenApiUrls() map[string]string {
+
+	urls := map[string]string{}
+	for _, t := range hipchatTokens() {
+		if len(t) == 2 {
+			urls[t[0]] = validUrl("HIPCHAT_TOKENS", t[1])
+		}
+	}
+	return urls
+}
+
+func hipchatTokens() [][]string {
+
+	tokensEnv := getEnv("HIPCHAT_TOKENS", true)
+	tokens := [][]string{}
 	for _, t := range strings.Split(tokensEnv, ",") {
-		tokens = append(tokens, strings.TrimSpace(t))
+		parts := strings.SplitN(strings.TrimSpace(t), "=", 2)
+		for i := range parts {
+			parts[i] = strings.TrimSpace(parts[i])
+		}
+		tokens = append(tokens, parts)
 	}
 	return tokens
 }
 
+func HipchatApiUrl() string {
+
+	if v := getEnv("HIPCHAT_API_URL", false); v != "" {
+		return validUrl("HIPCHAT_API_URL", v)
+	}
+	return ""
+}
+
+func HipchatCaFile() string {
+	return getEnv("HIPCHAT_CA_FILE", false)
+}
+
+func HipchatTlsSkipVerify() bool {
+
+	v := getEnv("HIPCHAT_TLS_SKIP_VERIFY", false)
+	if v == "" {
+		return false
+	}
+
+	skip, err := strconv.ParseBool(v)
+	if err != nil {
+		logger.Fatalf("HIPCHAT_TLS_SKIP_VERIFY=%q is not valid boolean: %v", v, err)
+	}
+	return skip
+}
+
 func DefaultRoom() string {
 	return getEnv("DEFAULT_JOIN_ROOM", false)
 }
 
+// PrivateChatUsers are users (user id, email or @mention name) whose private messages to the pack's user are received
+func PrivateChatUsers() []string {
+
+	users := []string{}
+	for _, u := range strings.Split(getEnv("PRIVATE_CHAT_USERS", false), ",") {
+		if u = strings.TrimSpace(u); u != "" {
+			users = append(users, u)
+		}
+	}
+	return users
+}
+
+// ChatCommandPrefixes start messages that are sent as ChatCommandReceived events e.g. `!`, `/flyte` or `@bot`
+func ChatCommandPrefixes() []string {
+
+	prefixes := []string{}
+	for _, p := range strings.Split(getEnv("CHAT_COMMAND_PREFIXES", false), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			prefixes = append(prefixes, p)
+		}
+	}
+	return prefixes
+}
+
+// TemplatesDir is directory with named message templates (*.tmpl files)
+func TemplatesDir() string {
+	return getEnv("TEMPLATES_DIR", false)
+}
+
 func BkpDir() string {
 	return getEnv("BKP_DIR", false)
 }
 
+const (
+	FileBkpStore = "file"
+	BoltBkpStore = "bolt"
+)
+
+// BkpStore is where joined rooms are backed up, "file" (JSON file) or "bolt" (BoltDB database), both in BKP_DIR
+func BkpStore() string {
+
+	switch v := getEnv("BKP_STORE", false); v {
+	case "", FileBkpStore:
+		return FileBkpStore
+	case BoltBkpStore:
+		return BoltBkpStore
+	default:
+		logger.Fatalf("BKP_STORE=%q is not valid store, supported stores are %q and %q", v, FileBkpStore, BoltBkpStore)
+		return ""
+	}
+}
+
+// WebhookUrl is public url HipChat sends room messages to, room history is polled if not set
+func WebhookUrl() string {
+
+	if v := getEnv("WEBHOOK_URL", false); v != "" {
+		return validUrl("WEBHOOK_URL", v)
+	}
+	return ""
+}
+
+func WebhookListenAddr() string {
+
+	if v := getEnv("WEBHOOK_LISTEN_ADDR", false); v != "" {
+		return v
+	}
+	return ":8090"
+}
+
+// MaxReplayMessages is max number of messages per room posted while the pack was down that are sent on start up
+func MaxReplayMessages() int {
+
+	v := getEnv("MAX_REPLAY_MESSAGES", false)
+	if v == "" {
+		return 100
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 0 {
+		logger.Fatalf("MAX_REPLAY_MESSAGES=%q is not valid number: %v", v, err)
+	}
+	return n
+}
+
+// BroadcastWorkers is max number of rooms a broadcast is sent to concurrently
+func BroadcastWorkers() int {
+
+	v := getEnv("BROADCAST_WORKERS", false)
+	if v == "" {
+		return 5
+	}
+
+	n, err := strconv.Atoi(v)
+	if err != nil || n < 1 {
+		logger.Fatalf("BROADCAST_WORKERS=%q is not valid number: %v", v, err)
+	}
+	return n
+}
+
+// BroadcastTimeout is how long a broadcast waits for rooms to be sent to, 0 waits until all rooms are done
+func BroadcastTimeout() time.Duration {
+	return getDurationEnv("BROADCAST_TIMEOUT", time.Minute)
+}
+
+func RateLimitInterval() time.Duration {
+	return getDurationEnv("HIPCHAT_RATE_LIMIT_INTERVAL", 5*time.Second)
+}
+
+func RateLimitMinInterval() time.Duration {
+	return getDurationEnv("HIPCHAT_RATE_LIMIT_MIN_INTERVAL", 0)
+}
+
+func validUrl(key, v string) string {
+
+	u, err := url.Parse(v)
+	if err == nil && (u.Scheme == "" || u.Host == "") {
+		err = errors.New("url is not absolute")
+	}
+	if err != nil {
+		logger.Fatalf("%s=%q is not valid URL: %v", key, v, err)
+	}
+	return v
+}
+
+func getDurationEnv(key string, defaultValue time.Duration) time.Duration {
+
+	v := getEnv(key, false)
+	if v == "" {
+		return defaultValue
+	}
+
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		logger.Fatalf("%s=%q is not valid duration: %v", key, v, err)
+	}
+	return d
+}
+
 func getEnv(key string, required bool) string {
 
 	v := os.Getenv(key)
@@ -22,6 +22,7 @@ import (
 	"os"
 	"github.com/HotelsDotCom/go-logger"
 	"testing"
+	"time"
 )
 
 func TestApiHost(t *testing.T) {
@@ -87,6 +88,92 @@ func TestHipchatAuthTokensNotSet(t *testing.T) {
 	assert.Equal(t, "env=HIPCHAT_TOKENS not set", mockLogger.fatalFMsg)
 }
 
+func TestHipchatAuthTokensWithApiUrl(t *testing.T) {
+
+	os.Setenv("HIPCHAT_TOKENS", "abc, def=https://hipchat.example.com/v2/ ,xyz")
+	defer func() { os.Unsetenv("HIPCHAT_TOKENS") }()
+
+	tokens := HipchatAuthTokens()
+	assert.Equal(t, []string{"abc", "def", "xyz"}, tokens)
+}
+
+func TestHipchatTokenApiUrls(t *testing.T) {
+
+	os.Setenv("HIPCHAT_TOKENS", "abc, def=https://hipchat.example.com/v2/ ,xyz")
+	defer func() { os.Unsetenv("HIPCHAT_TOKENS") }()
+
+	urls := HipchatTokenApiUrls()
+	assert.Equal(t, map[string]string{"def": "https://hipchat.example.com/v2/"}, urls)
+}
+
+func TestHipchatTokenApiUrlsInvalidUrl(t *testing.T) {
+
+	os.Setenv("HIPCHAT_TOKENS", "abc=hipchat")
+	defer func() { os.Unsetenv("HIPCHAT_TOKENS") }()
+
+	mockLogger := NewMockLogger()
+	defer func() { mockLogger.rollback() }()
+
+	HipchatTokenApiUrls()
+	assert.Contains(t, mockLogger.fatalFMsg, "HIPCHAT_TOKENS=\"hipchat\" is not valid URL: ")
+}
+
+func TestHipchatApiUrlDefault(t *testing.T) {
+	assert.Equal(t, "", HipchatApiUrl())
+}
+
+func TestHipchatApiUrl(t *testing.T) {
+
+	os.Setenv("HIPCHAT_API_URL", "https://hipchat.example.com/v2/")
+	defer func() { os.Unsetenv("HIPCHAT_API_URL") }()
+
+	assert.Equal(t, "https://hipchat.example.com/v2/", HipchatApiUrl())
+}
+
+func TestHipchatApiUrlInvalid(t *testing.T) {
+
+	os.Setenv("HIPCHAT_API_URL", ":/invalid url")
+	defer func() { os.Unsetenv("HIPCHAT_API_URL") }()
+
+	mockLogger := NewMockLogger()
+	defer func() { mockLogger.rollback() }()
+
+	HipchatApiUrl()
+	assert.Contains(t, mockLogger.fatalFMsg, "HIPCHAT_API_URL=\":/invalid url\" is not valid URL: ")
+}
+
+func TestHipchatCaFile(t *testing.T) {
+
+	assert.Equal(t, "", HipchatCaFile())
+
+	os.Setenv("HIPCHAT_CA_FILE", "/etc/ssl/hipchat.pem")
+	defer func() { os.Unsetenv("HIPCHAT_CA_FILE") }()
+
+	assert.Equal(t, "/etc/ssl/hipchat.pem", HipchatCaFile())
+}
+
+func TestHipchatTlsSkipVerify(t *testing.T) {
+
+	assert.False(t, HipchatTlsSkipVerify())
+
+	os.Setenv("HIPCHAT_TLS_SKIP_VERIFY", "true")
+	defer func() { os.Unsetenv("HIPCHAT_TLS_SKIP_VERIFY") }()
+
+	assert.True(t, HipchatTlsSkipVerify())
+}
+
+func TestHipchatTlsSkipVerifyInvalid(t *testing.T) {
+
+	os.Setenv("HIPCHAT_TLS_SKIP_VERIFY", "maybe")
+	defer func() { os.Unsetenv("HIPCHAT_TLS_SKIP_VERIFY") }()
+
+	mockLogger := NewMockLogger()
+	defer func() { mockLogger.rollback() }()
+
+	HipchatTlsSkipVerify()
+	assert.Contains(t, mockLogger.fatalFMsg, "HIPCHAT_TLS_SKIP_VERIFY=\"maybe\" is not valid boolean: ")
+}
+
 func TestDefaultRoom(t *testing.T) {
 	assert.Equal(t, "", DefaultRoom())
 }
@@ -99,6 +186,18 @@ func TestDefaultRoomSet(t *testing.T) {
 	assert.Equal(t, "abc", DefaultRoom())
 }
 
+func TestTemplatesDirDefault(t *testing.T) {
+	assert.Equal(t, "", TemplatesDir())
+}
+
+func TestTemplatesDir(t *testing.T) {
+
+	os.Setenv("TEMPLATES_DIR", "/etc/hipchat-pack/templates")
+	defer func() { os.Unsetenv("TEMPLATES_DIR") }()
+
+	assert.Equal(t, "/etc/hipchat-pack/templates", TemplatesDir())
+}
+
 func TestBkpDirDefault(t *testing.T) {
 	assert.Equal(t, "", BkpDir())
 }
@@ -111,6 +210,176 @@ func TestBkpDir(t *testing.T) {
 	assert.Equal(t, "/tmp/hipchat-pack", BkpDir())
 }
 
+func TestWebhookUrlDefault(t *testing.T) {
+	assert.Equal(t, "", WebhookUrl())
+}
+
+func TestWebhookUrl(t *testing.T) {
+
+	os.Setenv("WEBHOOK_URL", "https://flyte-hipchat.example.com/webhook")
+	defer func() { os.Unsetenv("WEBHOOK_URL") }()
+
+	assert.Equal(t, "https://flyte-hipchat.example.com/webhook", WebhookUrl())
+}
+
+func TestWebhookUrlInvalid(t *testing.T) {
+
+	os.Setenv("WEBHOOK_URL", "/webhook")
+	defer func() { os.Unsetenv("WEBHOOK_URL") }()
+
+	mockLogger := NewMockLogger()
+	defer func() { mockLogger.rollback() }()
+
+	WebhookUrl()
+	assert.Equal(t, "WEBHOOK_URL=\"/webhook\" is not valid URL: url is not absolute", mockLogger.fatalFMsg)
+}
+
+func TestWebhookListenAddr(t *testing.T) {
+
+	assert.Equal(t, ":8090", WebhookListenAddr())
+
+	os.Setenv("WEBHOOK_LISTEN_ADDR", "127.0.0.1:9000")
+	defer func() { os.Unsetenv("WEBHOOK_LISTEN_ADDR") }()
+
+	assert.Equal(t, "127.0.0.1:9000", WebhookListenAddr())
+}
+
+func TestPrivateChatUsersNotSet(t *testing.T) {
+	assert.Equal(t, []string{}, PrivateChatUsers())
+}
+
+func TestPrivateChatUsers(t *testing.T) {
+
+	os.Setenv("PRIVATE_CHAT_USERS", "123, john@example.com,,@jane ")
+	defer func() { os.Unsetenv("PRIVATE_CHAT_USERS") }()
+
+	assert.Equal(t, []string{"123", "john@example.com", "@jane"}, PrivateChatUsers())
+}
+
+func TestChatCommandPrefixesNotSet(t *testing.T) {
+	assert.Equal(t, []string{}, ChatCommandPrefixes())
+}
+
+func TestChatCommandPrefixes(t *testing.T) {
+
+	os.Setenv("CHAT_COMMAND_PREFIXES", "!, /flyte,,@flyte ")
+	defer func() { os.Unsetenv("CHAT_COMMAND_PREFIXES") }()
+
+	assert.Equal(t, []string{"!", "/flyte", "@flyte"}, ChatCommandPrefixes())
+}
+
+func TestBkpStore(t *testing.T) {
+
+	assert.Equal(t, FileBkpStore, BkpStore())
+
+	os.Setenv("BKP_STORE", "bolt")
+	defer func() { os.Unsetenv("BKP_STORE") }()
+
+	assert.Equal(t, BoltBkpStore, BkpStore())
+}
+
+func TestBkpStoreInvalid(t *testing.T) {
+
+	os.Setenv("BKP_STORE", "redis")
+	defer func() { os.Unsetenv("BKP_STORE") }()
+
+	mockLogger := NewMockLogger()
+	defer func() { mockLogger.rollback() }()
+
+	BkpStore()
+	assert.Equal(t, `BKP_STORE="redis" is not valid store, supported stores are "file" and "bolt"`, mockLogger.fatalFMsg)
+}
+
+func TestMaxReplayMessages(t *testing.T) {
+
+	assert.Equal(t, 100, MaxReplayMessages())
+
+	os.Setenv("MAX_REPLAY_MESSAGES", "0")
+	defer func() { os.Unsetenv("MAX_REPLAY_MESSAGES") }()
+
+	assert.Equal(t, 0, MaxReplayMessages())
+}
+
+func TestMaxReplayMessagesInvalid(t *testing.T) {
+
+	os.Setenv("MAX_REPLAY_MESSAGES", "lots")
+	defer func() { os.Unsetenv("MAX_REPLAY_MESSAGES") }()
+
+	mockLogger := NewMockLogger()
+	defer func() { mockLogger.rollback() }()
+
+	MaxReplayMessages()
+	assert.Contains(t, mockLogger.fatalFMsg, "MAX_REPLAY_MESSAGES=\"lots\" is not valid number: ")
+}
+
+func TestBroadcastWorkers(t *testing.T) {
+
+	assert.Equal(t, 5, BroadcastWorkers())
+
+	os.Setenv("BROADCAST_WORKERS", "10")
+	defer func() { os.Unsetenv("BROADCAST_WORKERS") }()
+
+	assert.Equal(t, 10, BroadcastWorkers())
+}
+
+func TestBroadcastWorkersInvalid(t *testing.T) {
+
+	os.Setenv("BROADCAST_WORKERS", "0")
+	defer func() { os.Unsetenv("BROADCAST_WORKERS") }()
+
+	mockLogger := NewMockLogger()
+	defer func() { mockLogger.rollback() }()
+
+	BroadcastWorkers()
+	assert.Contains(t, mockLogger.fatalFMsg, "BROADCAST_WORKERS=\"0\" is not valid number: ")
+}
+
+func TestBroadcastTimeout(t *testing.T) {
+
+	assert.Equal(t, time.Minute, BroadcastTimeout())
+
+	os.Setenv("BROADCAST_TIMEOUT", "0s")
+	defer func() { os.Unsetenv("BROADCAST_TIMEOUT") }()
+
+	assert.Equal(t, time.Duration(0), BroadcastTimeout())
+}
+
+func TestRateLimitIntervalDefault(t *testing.T) {
+	assert.Equal(t, 5*time.Second, RateLimitInterval())
+}
+
+func TestRateLimitInterval(t *testing.T) {
+
+	os.Setenv("HIPCHAT_RATE_LIMIT_INTERVAL", "1500ms")
+	defer func() { os.Unsetenv("HIPCHAT_RATE_LIMIT_INTERVAL") }()
+
+	assert.Equal(t, 1500*time.Millisecond, RateLimitInterval())
+}
+
+func TestRateLimitIntervalInvalid(t *testing.T) {
+
+	os.Setenv("HIPCHAT_RATE_LIMIT_INTERVAL", "5 seconds")
+	defer func() { os.Unsetenv("HIPCHAT_RATE_LIMIT_INTERVAL") }()
+
+	mockLogger := NewMockLogger()
+	defer func() { mockLogger.rollback() }()
+
+	RateLimitInterval()
+	assert.Contains(t, mockLogger.fatalFMsg, "HIPCHAT_RATE_LIMIT_INTERVAL=\"5 seconds\" is not valid duration: ")
+}
+
+func TestRateLimitMinIntervalDefault(t *testing.T) {
+	assert.Equal(t, time.Duration(0), RateLimitMinInterval())
+}
+
+func TestRateLimitMinInterval(t *testing.T) {
+
+	os.Setenv("HIPCHAT_RATE_LIMIT_MIN_INTERVAL", "200ms")
+	defer func() { os.Unsetenv("HIPCHAT_RATE_LIMIT_MIN_INTERVAL") }()
+
+	assert.Equal(t, 200*time.Millisecond, RateLimitMinInterval())
+}
+
 type MockLogger struct {
 	prevLogger func(string, ...interface{})
 	fatalFMsg  string
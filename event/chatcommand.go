@@ -0,0 +1,158 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package event
+
+import (
+	"bytes"
+	"errors"
+	"github.com/HotelsDotCom/flyte-hipchat/hipchat"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// ChatCommand is a received message starting with one of the command prefixes e.g. `!deploy app env=prod`
+type ChatCommand struct {
+	hipchat.Message
+	Prefix  string            `json:"prefix"`
+	Command string            `json:"command"`
+	Args    []string          `json:"args"`
+	Flags   map[string]string `json:"flags"`
+}
+
+// token is a single argument, equals is the index of the first unquoted '=' in the value, -1 if there is none
+type token struct {
+	value  string
+	equals int
+}
+
+// parseChatCommand splits message text into command name, positional args and key=value flags. Arguments are
+// separated by whitespace and can be quoted with single or double quotes, backslash escapes quotes, backslash and
+// whitespace. Returns false if the text does not start with any of the prefixes followed by a command name.
+func parseChatCommand(message hipchat.Message, prefixes []string) (ChatCommand, bool, error) {
+
+	prefix, rest, ok := cutCommandPrefix(strings.TrimSpace(message.Message), prefixes)
+	if !ok {
+		return ChatCommand{}, false, nil
+	}
+
+	tokens, err := splitArgs(rest)
+	if err != nil {
+		return ChatCommand{}, false, err
+	}
+	if len(tokens) == 0 || !isWordStart(tokens[0].value) {
+		return ChatCommand{}, false, nil
+	}
+
+	command := ChatCommand{Message: message, Prefix: prefix, Command: tokens[0].value, Args: []string{}, Flags: map[string]string{}}
+	for _, t := range tokens[1:] {
+		if t.equals > 0 {
+			command.Flags[t.value[:t.equals]] = t.value[t.equals+1:]
+			continue
+		}
+		command.Args = append(command.Args, t.value)
+	}
+	return command, true, nil
+}
+
+// cutCommandPrefix returns the longest matching prefix (case insensitive) and the rest of the text. Prefix ending with
+// a letter or digit, e.g. `/flyte` or `@bot`, has to be followed by whitespace, colon or comma.
+func cutCommandPrefix(text string, prefixes []string) (string, string, bool) {
+
+	sorted := append([]string{}, prefixes...)
+	sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+
+	for _, p := range sorted {
+		if p == "" || len(text) < len(p) || !strings.EqualFold(text[:len(p)], p) {
+			continue
+		}
+		rest := text[len(p):]
+		if last, _ := utf8.DecodeLastRuneInString(p); isWordRune(last) && rest != "" {
+			next, _ := utf8.DecodeRuneInString(rest)
+			if !unicode.IsSpace(next) && next != ':' && next != ',' {
+				continue
+			}
+			rest = strings.TrimLeft(rest, ":,")
+		}
+		return p, strings.TrimSpace(rest), true
+	}
+	return "", "", false
+}
+
+func splitArgs(s string) ([]token, error) {
+
+	tokens := []token{}
+	var value bytes.Buffer
+	inToken, escaped, equals := false, false, -1
+	var quote rune
+
+	for _, r := range s {
+		switch {
+		case escaped:
+			if r != '"' && r != '\'' && r != '\\' && !unicode.IsSpace(r) {
+				value.WriteRune('\\')
+			}
+			value.WriteRune(r)
+			escaped = false
+		case r == '\\' && quote != '\'':
+			escaped, inToken = true, true
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				value.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote, inToken = r, true
+		case unicode.IsSpace(r):
+			if inToken {
+				tokens = append(tokens, token{value: value.String(), equals: equals})
+				value.Reset()
+				inToken, equals = false, -1
+			}
+		default:
+			if r == '=' && equals < 0 {
+				equals = value.Len()
+			}
+			value.WriteRune(r)
+			inToken = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, errors.New("unterminated quote")
+	}
+	if escaped {
+		value.WriteRune('\\')
+	}
+	if inToken {
+		tokens = append(tokens, token{value: value.String(), equals: equals})
+	}
+	return tokens, nil
+}
+
+// isWordStart is true for command names, it filters out messages like `!!!` or `/// comment`
+func isWordStart(s string) bool {
+
+	r, _ := utf8.DecodeRuneInString(s)
+	return s != "" && isWordRune(r)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r)
+}
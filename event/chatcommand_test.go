@@ -0,0 +1,125 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package event
+
+import (
+	"github.com/HotelsDotCom/flyte-hipchat/hipchat"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var prefixes = []string{"!", "/flyte", "@flyte"}
+
+func TestParseChatCommand(t *testing.T) {
+
+	message := hipchat.Message{RoomId: "1", Message: "!deploy app env=prod"}
+
+	command, ok, err := parseChatCommand(message, prefixes)
+
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	expected := ChatCommand{Message: message, Prefix: "!", Command: "deploy", Args: []string{"app"}, Flags: map[string]string{"env": "prod"}}
+	assert.Equal(t, expected, command)
+}
+
+func TestParseChatCommandPrefixes(t *testing.T) {
+
+	tests := map[string]string{
+		"!deploy":        "!",
+		"! deploy":       "!",
+		"/flyte deploy":  "/flyte",
+		"/FLYTE deploy":  "/flyte",
+		"@flyte deploy":  "@flyte",
+		"@flyte: deploy": "@flyte",
+		"@flyte, deploy": "@flyte",
+		"  !deploy":      "!",
+	}
+
+	for text, prefix := range tests {
+		command, ok, err := parseChatCommand(hipchat.Message{Message: text}, prefixes)
+		assert.Nil(t, err, text)
+		assert.True(t, ok, text)
+		assert.Equal(t, prefix, command.Prefix, text)
+		assert.Equal(t, "deploy", command.Command, text)
+	}
+}
+
+func TestParseChatCommandNotCommand(t *testing.T) {
+
+	tests := []string{"deploy app", "/flytes deploy", "@flyteadmin deploy", "!", "!!!", "/flyte", "hi @flyte deploy", ""}
+
+	for _, text := range tests {
+		_, ok, err := parseChatCommand(hipchat.Message{Message: text}, prefixes)
+		assert.Nil(t, err, text)
+		assert.False(t, ok, text)
+	}
+}
+
+func TestParseChatCommandNoPrefixes(t *testing.T) {
+
+	_, ok, _ := parseChatCommand(hipchat.Message{Message: "!deploy"}, []string{})
+
+	assert.False(t, ok)
+}
+
+func TestParseChatCommandQuotes(t *testing.T) {
+
+	text := `!announce "release 1.2" 'it''s out' msg="hello world" "a=b" =c tag='x y'=z`
+
+	command, ok, err := parseChatCommand(hipchat.Message{Message: text}, prefixes)
+
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []string{"release 1.2", "its out", "a=b", "=c"}, command.Args)
+	assert.Equal(t, map[string]string{"msg": "hello world", "tag": "x y=z"}, command.Flags)
+}
+
+func TestParseChatCommandEscapes(t *testing.T) {
+
+	text := `!run say\ hi "quote \" inside" 'single \ kept' path=C:\temp trailing\`
+
+	command, _, err := parseChatCommand(hipchat.Message{Message: text}, prefixes)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"say hi", `quote " inside`, `single \ kept`, `trailing\`}, command.Args)
+	assert.Equal(t, map[string]string{"path": `C:\temp`}, command.Flags)
+}
+
+func TestParseChatCommandEmptyArgs(t *testing.T) {
+
+	command, ok, err := parseChatCommand(hipchat.Message{Message: "!status"}, prefixes)
+
+	assert.Nil(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []string{}, command.Args)
+	assert.Equal(t, map[string]string{}, command.Flags)
+}
+
+func TestParseChatCommandDuplicateFlag(t *testing.T) {
+
+	command, _, _ := parseChatCommand(hipchat.Message{Message: "!deploy env=qa env=prod"}, prefixes)
+
+	assert.Equal(t, map[string]string{"env": "prod"}, command.Flags)
+}
+
+func TestParseChatCommandUnterminatedQuote(t *testing.T) {
+
+	_, ok, err := parseChatCommand(hipchat.Message{Message: `!deploy "app`}, prefixes)
+
+	assert.False(t, ok)
+	assert.EqualError(t, err, "unterminated quote")
+}
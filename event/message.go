@@ -22,7 +22,26 @@ import (
 	"github.com/HotelsDotCom/go-logger"
 )
 
-func HandleReceivedMessages(pack flyte.Pack, messages chan hipchat.Message) {
+func HandleReceivedPrivateMessages(pack flyte.Pack, messages chan hipchat.ReceivedPrivateMessage) {
+
+	go func() {
+		for message := range messages {
+			e := flyte.Event{
+				EventDef: flyte.EventDef{Name: "ReceivedPrivateMessage"},
+				Payload:  message,
+			}
+			logger.Infof("received private message=%q from=%q", message.Message.Message, message.Sender.Name)
+			if err := pack.SendEvent(e); err != nil {
+				logger.Errorf("error sending received private message event: %v", err)
+			}
+		}
+	}()
+}
+
+// HandleReceivedMessages sends ReceivedMessage events, plus BotMentioned event if the pack user is mentioned in the
+// message and ChatCommandReceived event if the message starts with one of the command prefixes. BotMentioned events
+// are not sent if the pack user is not known (zero id), ChatCommandReceived events if there are no command prefixes.
+func HandleReceivedMessages(pack flyte.Pack, messages chan hipchat.Message, packUser hipchat.User, commandPrefixes []string) {
 
 	go func() {
 		for message := range messages {
@@ -34,6 +53,47 @@ func HandleReceivedMessages(pack flyte.Pack, messages chan hipchat.Message) {
 			if err := pack.SendEvent(e); err != nil {
 				logger.Errorf("error sending received message event: %v", err)
 			}
+
+			if message.IsMentioned(packUser) {
+				sendBotMentioned(pack, message, packUser)
+			}
+			if len(commandPrefixes) != 0 {
+				sendChatCommand(pack, message, commandPrefixes)
+			}
 		}
 	}()
 }
+
+func sendBotMentioned(pack flyte.Pack, message hipchat.Message, packUser hipchat.User) {
+
+	message.Message = message.StripMention(packUser)
+	e := flyte.Event{
+		EventDef: flyte.EventDef{Name: "BotMentioned"},
+		Payload:  message,
+	}
+	logger.Infof("pack user mentioned in room=%s by=%q", message.RoomId, message.From.Name)
+	if err := pack.SendEvent(e); err != nil {
+		logger.Errorf("error sending bot mentioned event: %v", err)
+	}
+}
+
+func sendChatCommand(pack flyte.Pack, message hipchat.Message, prefixes []string) {
+
+	command, ok, err := parseChatCommand(message, prefixes)
+	if err != nil {
+		logger.Errorf("cannot parse chat command=%q in room=%s: %v", message.Message, message.RoomId, err)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	e := flyte.Event{
+		EventDef: flyte.EventDef{Name: "ChatCommandReceived"},
+		Payload:  command,
+	}
+	logger.Infof("received chat command=%q in room=%s from=%q", command.Command, message.RoomId, message.From.Name)
+	if err := pack.SendEvent(e); err != nil {
+		logger.Errorf("error sending chat command received event: %v", err)
+	}
+}
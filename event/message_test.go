@@ -27,7 +27,7 @@ func TestMessageReceived(t *testing.T) {
 
 	p := NewPackMock()
 	messages := make(chan hipchat.Message)
-	HandleReceivedMessages(p, messages)
+	HandleReceivedMessages(p, messages, hipchat.User{}, nil)
 
 	messages <- hipchat.Message{Message: "the message"}
 	receivedEvent := <-p.receivedEvents
@@ -38,6 +38,94 @@ func TestMessageReceived(t *testing.T) {
 	assert.Equal(t, "the message", receivedPayload.Message)
 }
 
+func TestBotMentioned(t *testing.T) {
+
+	p := NewPackMock()
+	messages := make(chan hipchat.Message)
+	packUser := hipchat.User{Id: 9, Name: "Flyte", MentionName: "flyte"}
+	HandleReceivedMessages(p, messages, packUser, nil)
+
+	mentions := []hipchat.User{packUser}
+	messages <- hipchat.Message{RoomId: "1", Message: "@flyte deploy app", Mentions: mentions}
+
+	receivedEvent := <-p.receivedEvents
+	assert.Equal(t, "ReceivedMessage", receivedEvent.EventDef.Name)
+	assert.Equal(t, "@flyte deploy app", receivedEvent.Payload.(hipchat.Message).Message)
+
+	receivedEvent = <-p.receivedEvents
+	expected := hipchat.Message{RoomId: "1", Message: "deploy app", Mentions: mentions}
+	assert.Equal(t, "BotMentioned", receivedEvent.EventDef.Name)
+	assert.Equal(t, expected, receivedEvent.Payload)
+}
+
+func TestBotNotMentioned(t *testing.T) {
+
+	p := NewPackMock()
+	messages := make(chan hipchat.Message)
+	HandleReceivedMessages(p, messages, hipchat.User{Id: 9, MentionName: "flyte"}, nil)
+
+	messages <- hipchat.Message{Message: "@john hi", Mentions: []hipchat.User{{Id: 1, MentionName: "john"}}}
+	assert.Equal(t, "ReceivedMessage", (<-p.receivedEvents).EventDef.Name)
+
+	// next event is for the next message, not BotMentioned
+	messages <- hipchat.Message{Message: "next message"}
+	receivedEvent := <-p.receivedEvents
+	assert.Equal(t, "ReceivedMessage", receivedEvent.EventDef.Name)
+	assert.Equal(t, "next message", receivedEvent.Payload.(hipchat.Message).Message)
+}
+
+func TestChatCommandReceived(t *testing.T) {
+
+	p := NewPackMock()
+	messages := make(chan hipchat.Message)
+	HandleReceivedMessages(p, messages, hipchat.User{}, []string{"!"})
+
+	message := hipchat.Message{RoomId: "1", Message: "!deploy app env=prod"}
+	messages <- message
+
+	assert.Equal(t, "ReceivedMessage", (<-p.receivedEvents).EventDef.Name)
+	receivedEvent := <-p.receivedEvents
+	expected := ChatCommand{Message: message, Prefix: "!", Command: "deploy", Args: []string{"app"}, Flags: map[string]string{"env": "prod"}}
+	assert.Equal(t, "ChatCommandReceived", receivedEvent.EventDef.Name)
+	assert.Equal(t, expected, receivedEvent.Payload)
+}
+
+func TestChatCommandNotReceived(t *testing.T) {
+
+	p := NewPackMock()
+	messages := make(chan hipchat.Message)
+	HandleReceivedMessages(p, messages, hipchat.User{}, []string{"!"})
+
+	messages <- hipchat.Message{Message: "deploy app"}
+	assert.Equal(t, "ReceivedMessage", (<-p.receivedEvents).EventDef.Name)
+
+	// unterminated quote
+	messages <- hipchat.Message{Message: `!deploy "app`}
+	assert.Equal(t, "ReceivedMessage", (<-p.receivedEvents).EventDef.Name)
+
+	// next event is for the next message, not ChatCommandReceived
+	messages <- hipchat.Message{Message: "next message"}
+	receivedEvent := <-p.receivedEvents
+	assert.Equal(t, "ReceivedMessage", receivedEvent.EventDef.Name)
+	assert.Equal(t, "next message", receivedEvent.Payload.(hipchat.Message).Message)
+}
+
+func TestPrivateMessageReceived(t *testing.T) {
+
+	p := NewPackMock()
+	messages := make(chan hipchat.ReceivedPrivateMessage)
+	HandleReceivedPrivateMessages(p, messages)
+
+	sender := hipchat.User{Id: 1, Name: "John", MentionName: "john"}
+	messages <- hipchat.ReceivedPrivateMessage{Message: hipchat.Message{Message: "the message"}, Sender: sender}
+	receivedEvent := <-p.receivedEvents
+	receivedPayload := receivedEvent.Payload.(hipchat.ReceivedPrivateMessage)
+
+	assert.Equal(t, "ReceivedPrivateMessage", receivedEvent.EventDef.Name)
+	assert.Equal(t, "the message", receivedPayload.Message.Message)
+	assert.Equal(t, sender, receivedPayload.Sender)
+}
+
 type PackMock struct {
 	receivedEvents chan flyte.Event
 	sendEvent      func(flyte.Event) error
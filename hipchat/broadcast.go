@@ -0,0 +1,157 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hipchat
+
+import (
+	"errors"
+	"github.com/HotelsDotCom/go-logger"
+	"sort"
+	"time"
+)
+
+// RoomFilter selects joined rooms for broadcast, all joined rooms are selected if neither tags nor include rooms are set
+type RoomFilter struct {
+	// rooms tagged with any of the tags
+	Tags []string
+	// rooms selected in addition to the tagged rooms
+	IncludeRooms []string
+	// rooms never selected
+	ExcludeRooms []string
+}
+
+// BroadcastResult lists rooms the broadcast was sent to, rooms it failed for and rooms it timed out for
+type BroadcastResult struct {
+	Sent   []string
+	Failed []RoomError
+	// rooms still being sent to when the broadcast timed out, the broadcast may or may not be delivered there, so
+	// they must not be retried blindly
+	TimedOut []string
+}
+
+type RoomError struct {
+	RoomId string
+	Error  string
+}
+
+// BroadcastMessage sends message to joined rooms selected by the filter, included rooms that are not joined fail.
+// Error is returned only if the message could not be sent to any room, failures of single rooms are in the result.
+func (hc Hipchat) BroadcastMessage(message string, filter RoomFilter) (BroadcastResult, error) {
+
+	logger.Info("broadcasting message")
+	return hc.broadcast(filter, func(roomId string) error { return hc.SendMessage(roomId, message) })
+}
+
+// BroadcastNotification sends notification to joined rooms selected by the filter, same as BroadcastMessage
+func (hc Hipchat) BroadcastNotification(notification Notification, filter RoomFilter) (BroadcastResult, error) {
+
+	logger.Info("broadcasting notification")
+	return hc.broadcast(filter, func(roomId string) error { return hc.SendNotification(roomId, notification) })
+}
+
+func (hc Hipchat) broadcast(filter RoomFilter, send func(roomId string) error) (BroadcastResult, error) {
+
+	result := BroadcastResult{Sent: []string{}, Failed: []RoomError{}, TimedOut: []string{}}
+	roomIds, notJoined := hc.rooms.Filter(filter)
+	if len(roomIds) == 0 && len(notJoined) == 0 {
+		return result, errors.New("no joined rooms match the filter")
+	}
+
+	for _, id := range notJoined {
+		result.Failed = append(result.Failed, RoomError{RoomId: id, Error: "room is not joined"})
+	}
+
+	sent, failed, timedOut := hc.fanOut(roomIds, send)
+	result.Sent = append(result.Sent, sent...)
+	result.Failed = append(result.Failed, failed...)
+	result.TimedOut = append(result.TimedOut, timedOut...)
+	sort.Strings(result.Sent)
+	sort.Slice(result.Failed, func(i, j int) bool { return result.Failed[i].RoomId < result.Failed[j].RoomId })
+	sort.Strings(result.TimedOut)
+
+	if len(result.Sent) == 0 && len(result.TimedOut) == 0 {
+		return result, errors.New("broadcast was not sent to any room")
+	}
+	return result, nil
+}
+
+type roomResult struct {
+	roomId string
+	err    error
+}
+
+// fanOut calls send for every room, at most Broadcast.Workers calls run concurrently. Rooms that are not done within
+// Broadcast.Timeout are returned as timed out, calls in progress are not cancelled, so timed out rooms may still be
+// sent to.
+func (hc Hipchat) fanOut(roomIds []string, send func(roomId string) error) (sent []string, failed []RoomError, timedOut []string) {
+
+	workers := hc.options.Broadcast.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	var timeout <-chan time.Time
+	if hc.options.Broadcast.Timeout > 0 {
+		timer := time.NewTimer(hc.options.Broadcast.Timeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	ids := make(chan string)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		defer close(ids)
+		for _, id := range roomIds {
+			select {
+			case ids <- id:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	// buffered, so workers finish even when nobody waits for the results after the timeout
+	results := make(chan roomResult, len(roomIds))
+	for i := 0; i < workers && i < len(roomIds); i++ {
+		go func() {
+			for id := range ids {
+				results <- roomResult{roomId: id, err: send(id)}
+			}
+		}()
+	}
+
+	pending := toSet(roomIds)
+	for len(pending) != 0 {
+		select {
+		case r := <-results:
+			delete(pending, r.roomId)
+			if r.err != nil {
+				logger.Errorf("room=%s cannot send broadcast: %v", r.roomId, r.err)
+				failed = append(failed, RoomError{RoomId: r.roomId, Error: r.err.Error()})
+				continue
+			}
+			sent = append(sent, r.roomId)
+		case <-timeout:
+			for id := range pending {
+				logger.Errorf("room=%s broadcast timed out, it may still be delivered", id)
+				timedOut = append(timedOut, id)
+			}
+			return sent, failed, timedOut
+		}
+	}
+	return sent, failed, timedOut
+}
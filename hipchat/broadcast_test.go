@@ -0,0 +1,233 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hipchat
+
+import (
+	"errors"
+	"github.com/HotelsDotCom/flyte-hipchat/bkp"
+	"github.com/stretchr/testify/assert"
+	"github.com/tbruyelle/hipchat-go/hipchat"
+	"os"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBroadcastMessageToTaggedRooms(t *testing.T) {
+
+	path := roomsPath()
+	defer os.Remove(path)
+
+	var mu sync.Mutex
+	sentTo := []string{}
+	client := NewClientMock()
+	client.sendMessage = func(roomId string, message string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		sentTo = append(sentTo, roomId)
+		return nil
+	}
+	hc, _ := NewHipchat(bkp.NewFileStore(path), client, nil, Options{})
+	hc.JoinRoom("1", []string{"ops"})
+	hc.JoinRoom("2", []string{"dev"})
+	hc.JoinRoom("3", nil)
+
+	result, err := hc.BroadcastMessage("deploy started", RoomFilter{Tags: []string{"ops"}, IncludeRooms: []string{"3"}})
+
+	assert.Nil(t, err)
+	assert.Equal(t, BroadcastResult{Sent: []string{"1", "3"}, Failed: []RoomError{}, TimedOut: []string{}}, result)
+	assert.Equal(t, []string{"1", "3"}, sentTo)
+}
+
+func TestBroadcastMessagePartiallyFailed(t *testing.T) {
+
+	path := roomsPath()
+	defer os.Remove(path)
+
+	client := NewClientMock()
+	client.sendMessage = func(roomId string, message string) error {
+		if roomId == "2" {
+			return errors.New("Server returns status 403")
+		}
+		return nil
+	}
+	hc, _ := NewHipchat(bkp.NewFileStore(path), client, nil, Options{})
+	hc.JoinRoom("1", nil)
+	hc.JoinRoom("2", nil)
+
+	result, err := hc.BroadcastMessage("deploy started", RoomFilter{IncludeRooms: []string{"1", "2", "9"}})
+
+	assert.Nil(t, err)
+	expected := BroadcastResult{
+		Sent: []string{"1"},
+		Failed: []RoomError{
+			{RoomId: "2", Error: "Server returns status 403"},
+			{RoomId: "9", Error: "room is not joined"},
+		},
+		TimedOut: []string{},
+	}
+	assert.Equal(t, expected, result)
+}
+
+func TestBroadcastMessageFailed(t *testing.T) {
+
+	path := roomsPath()
+	defer os.Remove(path)
+
+	client := NewClientMock()
+	client.sendMessage = func(string, string) error { return errors.New("Server returns status 500") }
+	hc, _ := NewHipchat(bkp.NewFileStore(path), client, nil, Options{})
+	hc.JoinRoom("1", nil)
+
+	result, err := hc.BroadcastMessage("deploy started", RoomFilter{})
+
+	assert.Equal(t, "broadcast was not sent to any room", err.Error())
+	expected := BroadcastResult{Sent: []string{}, Failed: []RoomError{{RoomId: "1", Error: "Server returns status 500"}}, TimedOut: []string{}}
+	assert.Equal(t, expected, result)
+}
+
+func TestBroadcastMessageNoRoomsSelected(t *testing.T) {
+
+	path := roomsPath()
+	defer os.Remove(path)
+
+	hc, _ := NewHipchat(bkp.NewFileStore(path), NewClientMock(), nil, Options{})
+	hc.JoinRoom("1", []string{"ops"})
+
+	_, err := hc.BroadcastMessage("deploy started", RoomFilter{Tags: []string{"dev"}})
+
+	assert.Equal(t, "no joined rooms match the filter", err.Error())
+}
+
+func TestBroadcastMessageConcurrently(t *testing.T) {
+
+	path := roomsPath()
+	defer os.Remove(path)
+
+	var mu sync.Mutex
+	running, maxRunning := 0, 0
+	client := NewClientMock()
+	client.sendMessage = func(string, string) error {
+		mu.Lock()
+		running++
+		if running > maxRunning {
+			maxRunning = running
+		}
+		mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+
+		mu.Lock()
+		running--
+		mu.Unlock()
+		return nil
+	}
+	hc, _ := NewHipchat(bkp.NewFileStore(path), client, nil, Options{Broadcast: BroadcastOptions{Workers: 3}})
+	for i := 0; i < 10; i++ {
+		hc.JoinRoom(strconv.Itoa(i), nil)
+	}
+
+	result, err := hc.BroadcastMessage("deploy started", RoomFilter{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"0", "1", "2", "3", "4", "5", "6", "7", "8", "9"}, result.Sent)
+	assert.Equal(t, 3, maxRunning)
+}
+
+func TestBroadcastMessageTimeout(t *testing.T) {
+
+	path := roomsPath()
+	defer os.Remove(path)
+
+	release := make(chan bool)
+	defer close(release)
+	client := NewClientMock()
+	client.sendMessage = func(roomId string, message string) error {
+		if roomId != "1" {
+			<-release
+		}
+		return nil
+	}
+	opts := Options{Broadcast: BroadcastOptions{Workers: 2, Timeout: 50 * time.Millisecond}}
+	hc, _ := NewHipchat(bkp.NewFileStore(path), client, nil, opts)
+	hc.JoinRoom("1", nil)
+	hc.JoinRoom("2", nil)
+	hc.JoinRoom("3", nil)
+
+	result, err := hc.BroadcastMessage("deploy started", RoomFilter{})
+
+	assert.Nil(t, err)
+	expected := BroadcastResult{Sent: []string{"1"}, Failed: []RoomError{}, TimedOut: []string{"2", "3"}}
+	assert.Equal(t, expected, result)
+}
+
+func TestBroadcastMessageTimedOutInAllRooms(t *testing.T) {
+
+	path := roomsPath()
+	defer os.Remove(path)
+
+	release := make(chan bool)
+	defer close(release)
+	client := NewClientMock()
+	client.sendMessage = func(string, string) error {
+		<-release
+		return nil
+	}
+	opts := Options{Broadcast: BroadcastOptions{Workers: 1, Timeout: 50 * time.Millisecond}}
+	hc, _ := NewHipchat(bkp.NewFileStore(path), client, nil, opts)
+	hc.JoinRoom("1", nil)
+
+	result, err := hc.BroadcastMessage("deploy started", RoomFilter{})
+
+	assert.Nil(t, err, "delivery is unknown, broadcast has not failed")
+	assert.Equal(t, []string{"1"}, result.TimedOut)
+}
+
+func TestBroadcastNotification(t *testing.T) {
+
+	path := roomsPath()
+	defer os.Remove(path)
+
+	var mu sync.Mutex
+	notified := map[string]*hipchat.NotificationRequest{}
+	client := NewClientMock()
+	hc, _ := NewHipchat(bkp.NewFileStore(path), client, nil, Options{Broadcast: BroadcastOptions{Workers: 2}})
+	hc.JoinRoom("1", []string{"ops"})
+	hc.JoinRoom("2", []string{"ops"})
+	hc.JoinRoom("3", nil)
+
+	client.sendNotification = func(roomId string, notification *hipchat.NotificationRequest) error {
+		mu.Lock()
+		defer mu.Unlock()
+		notified[roomId] = notification
+		if roomId == "2" {
+			return errors.New("Server returns status 403")
+		}
+		return nil
+	}
+	notification := Notification{Message: "<b>deploy</b> started", MessageFormat: "html", Color: "green", From: "ci"}
+
+	result, err := hc.BroadcastNotification(notification, RoomFilter{Tags: []string{"ops"}})
+
+	assert.Nil(t, err)
+	expected := BroadcastResult{Sent: []string{"1"}, Failed: []RoomError{{RoomId: "2", Error: "Server returns status 403"}}, TimedOut: []string{}}
+	assert.Equal(t, expected, result)
+	assert.Equal(t, 2, len(notified))
+	assert.Equal(t, "<b>deploy</b> started", notified["1"].Message)
+	assert.Equal(t, hipchat.ColorGreen, notified["1"].Color)
+}
@@ -0,0 +1,73 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hipchat
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+// MaxFileSize is the largest file HipChat accepts
+const MaxFileSize = 50 * 1024 * 1024
+
+// httpClient downloads shared files
+var httpClient = &http.Client{Timeout: 30 * time.Second}
+
+type SharedFile struct {
+	Name    string
+	Content []byte
+	// content is downloaded from url when it is not set
+	Url     string
+	Message string
+}
+
+// ShareFile uploads file to the room
+func (hc Hipchat) ShareFile(roomId string, file SharedFile) error {
+
+	content := file.Content
+	if content == nil {
+		c, err := download(file.Url)
+		if err != nil {
+			return fmt.Errorf("cannot download %s: %v", file.Url, err)
+		}
+		content = c
+	}
+
+	if len(content) > MaxFileSize {
+		return fmt.Errorf("file is larger than %d bytes", MaxFileSize)
+	}
+	return hc.client.ShareFile(roomId, file.Name, content, file.Message)
+}
+
+func download(url string) ([]byte, error) {
+
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("server returns status %d", resp.StatusCode)
+	}
+
+	// one byte over the limit is enough to reject the file
+	return ioutil.ReadAll(io.LimitReader(resp.Body, MaxFileSize+1))
+}
@@ -0,0 +1,96 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hipchat
+
+import (
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestShareFile(t *testing.T) {
+
+	var roomId, name, message string
+	var content []byte
+	client := NewClientMock()
+	client.shareFile = func(r, n string, c []byte, m string) error {
+		roomId, name, content, message = r, n, c, m
+		return nil
+	}
+	hc := Hipchat{client: client}
+
+	err := hc.ShareFile("42", SharedFile{Name: "report.csv", Content: []byte("a,b"), Message: "daily report"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "42", roomId)
+	assert.Equal(t, "report.csv", name)
+	assert.Equal(t, []byte("a,b"), content)
+	assert.Equal(t, "daily report", message)
+}
+
+func TestShareFileFromUrl(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("graph"))
+	}))
+	defer server.Close()
+
+	var content []byte
+	client := NewClientMock()
+	client.shareFile = func(_, _ string, c []byte, _ string) error {
+		content = c
+		return nil
+	}
+	hc := Hipchat{client: client}
+
+	err := hc.ShareFile("42", SharedFile{Name: "graph.png", Url: server.URL + "/graph.png"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("graph"), content)
+}
+
+func TestShareFileDownloadFailed(t *testing.T) {
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	hc := Hipchat{client: NewClientMock()}
+
+	err := hc.ShareFile("42", SharedFile{Name: "graph.png", Url: server.URL + "/graph.png"})
+
+	assert.Equal(t, "cannot download "+server.URL+"/graph.png: server returns status 404", err.Error())
+}
+
+func TestShareFileTooLarge(t *testing.T) {
+
+	shared := false
+	client := NewClientMock()
+	client.shareFile = func(string, string, []byte, string) error {
+		shared = true
+		return nil
+	}
+	hc := Hipchat{client: client}
+
+	err := hc.ShareFile("42", SharedFile{Name: "log.txt", Content: []byte(strings.Repeat("x", MaxFileSize+1))})
+
+	assert.Equal(t, "file is larger than 52428800 bytes", err.Error())
+	assert.False(t, shared)
+}
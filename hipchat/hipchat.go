@@ -18,19 +18,76 @@ package hipchat
 
 import (
 	"fmt"
+	"github.com/HotelsDotCom/flyte-hipchat/bkp"
 	"github.com/HotelsDotCom/flyte-hipchat/client"
 	"github.com/HotelsDotCom/go-logger"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// who joined the room, recorded in the rooms backup
+const (
+	JoinedByCommand = "command"
+	JoinedByConfig  = "config"
 )
 
 type Hipchat struct {
-	client client.HipchatClient
-	rooms  *Rooms
+	client       client.HipchatClient
+	rooms        *Rooms
+	privateChats *privateChats
+	options      Options
+}
+
+// RoomSettings of a new room
+type RoomSettings struct {
+	Name  string
+	Topic string
+	// public or private, defaults to public
+	Privacy string
+	// user id, email or @mention name, defaults to the pack's user
+	OwnerUserId string
+	GuestAccess bool
+}
+
+type privateChats struct {
+	sync.Mutex
+	chats []*PrivateChat
+	// set on shutdown, private chats that are retried are not monitored anymore
+	closed bool
+}
+
+// how long to wait before retrying private chat with user that could not be found
+var privateChatRetryInterval = time.Minute
+
+type Options struct {
+	// receive room messages through HipChat webhooks, room history is polled if not set
+	Webhook *WebhookOptions
+	// max number of messages per room, posted while the pack was down, to replay on start up. 0 disables replay
+	MaxReplay int
+	Broadcast BroadcastOptions
 }
 
-func NewHipchat(roomsBackupPath string, client client.HipchatClient, messages chan Message) (Hipchat, error) {
+type BroadcastOptions struct {
+	// max number of rooms the broadcast is sent to concurrently, defaults to 1
+	Workers int
+	// rooms the broadcast was not sent to within the timeout are reported as timed out, no timeout if 0
+	Timeout time.Duration
+}
+
+type WebhookOptions struct {
+	// public url of the webhook handler, HipChat sends room messages there
+	Url string
+	// called with the webhook handler before any webhook is registered, so no callback is lost. Rooms are not loaded
+	// if it fails
+	Listen func(http.Handler) error
+}
 
-	hc := Hipchat{client: client}
-	rooms, err := NewRooms(roomsBackupPath, client, messages)
+func NewHipchat(store bkp.RoomStore, client client.HipchatClient, messages chan Message, opts Options) (Hipchat, error) {
+
+	hc := Hipchat{client: client, privateChats: &privateChats{}, options: opts}
+	rooms, err := NewRooms(store, client, messages, opts)
 	if err != nil {
 		return hc, err
 	}
@@ -45,38 +102,141 @@ func NewHipchat(roomsBackupPath string, client client.HipchatClient, messages ch
 	return hc, nil
 }
 
+// WebhookHandler handles room messages sent by HipChat webhooks
+func (hc Hipchat) WebhookHandler() http.Handler {
+
+	return webhookHandler{rooms: hc.rooms}
+}
+
 func (hc Hipchat) JoinedRoomIds() []string {
 	return hc.rooms.ListIds()
 }
 
-func (hc Hipchat) BroadcastMessage(message string) error {
+func (hc Hipchat) SendMessage(roomId string, message string) error {
+	return hc.client.SendMessage(roomId, message)
+}
+
+func (hc Hipchat) SendNotification(roomId string, notification Notification) error {
+	return hc.client.SendNotification(roomId, ToHipChatNotification(notification))
+}
 
-	errors := []string{}
-	logger.Info("broadcasting message")
-	for _, id := range hc.rooms.ListIds() {
-		if err := hc.SendMessage(id, message); err != nil {
-			errors = append(errors, fmt.Sprintf("room=%s: %v", id, err))
+func (hc Hipchat) SetRoomTopic(roomId, topic string) error {
+	return hc.client.SetTopic(roomId, topic)
+}
+
+// CreateRoom creates room and returns its id, room is not joined
+func (hc Hipchat) CreateRoom(settings RoomSettings) (string, error) {
+
+	room, err := hc.client.CreateRoom(ToHipChatCreateRoomRequest(settings))
+	if err != nil {
+		return "", err
+	}
+	logger.Infof("created room=%d name=%q", room.ID, settings.Name)
+	return strconv.Itoa(room.ID), nil
+}
+
+// ArchiveRoom archives room, the room is left first if it is joined
+func (hc Hipchat) ArchiveRoom(roomId string) error {
+
+	if hc.rooms.Get(roomId) != nil {
+		if err := hc.LeaveRoom(roomId); err != nil {
+			logger.Errorf("room=%s archiving: %v", roomId, err)
+		}
+	}
+	return hc.client.ArchiveRoom(roomId)
+}
+
+// InviteUser adds user (user id, email or @mention name) to private room members
+func (hc Hipchat) InviteUser(roomId, userId string) error {
+	return hc.client.AddMember(roomId, userId)
+}
+
+// RemoveMember removes user (user id, email or @mention name) from private room members
+func (hc Hipchat) RemoveMember(roomId, userId string) error {
+	return hc.client.RemoveMember(roomId, userId)
+}
+
+// ShareLink posts link with message to the room, HipChat shows the link preview
+func (hc Hipchat) ShareLink(roomId, link, message string) error {
+	return hc.client.ShareLink(roomId, link, message)
+}
+
+// SendPrivateMessage sends one-to-one message to user, userId is user id, email or @mention name
+func (hc Hipchat) SendPrivateMessage(userId string, message PrivateMessage) error {
+	return hc.client.SendPrivateMessage(userId, ToHipChatMessageRequest(message))
+}
+
+// MonitorPrivateChats hands over private messages sent to the pack's user by users (user id, email or @mention name).
+// Users that cannot be found are retried in the background.
+func (hc Hipchat) MonitorPrivateChats(userIds []string, messages chan ReceivedPrivateMessage) {
+
+	for _, id := range userIds {
+		if !hc.monitorPrivateChat(id, messages) {
+			go hc.retryPrivateChat(id, messages, privateChatRetryInterval)
 		}
 	}
+}
+
+// monitorPrivateChat returns false if the user cannot be found
+func (hc Hipchat) monitorPrivateChat(userId string, messages chan ReceivedPrivateMessage) bool {
 
-	if len(errors) != 0 {
-		return fmt.Errorf("failed messages: %v", errors)
+	user, err := hc.client.GetUser(userId)
+	if err != nil {
+		logger.Errorf("cannot monitor private chat with user=%q, retrying: %v", userId, err)
+		return false
 	}
-	return nil
+
+	hc.privateChats.Lock()
+	defer hc.privateChats.Unlock()
+	if hc.privateChats.closed {
+		return true
+	}
+	logger.Infof("monitoring private chat with user=%q", userId)
+	hc.privateChats.chats = append(hc.privateChats.chats, NewPrivateChat(ToUser(*user), hc.client, messages))
+	return true
 }
 
-func (hc Hipchat) SendMessage(roomId string, message string) error {
-	return hc.client.SendMessage(roomId, message)
+func (hc Hipchat) retryPrivateChat(userId string, messages chan ReceivedPrivateMessage, interval time.Duration) {
+
+	for {
+		time.Sleep(interval)
+		if hc.privateChatsClosed() || hc.monitorPrivateChat(userId, messages) {
+			return
+		}
+	}
 }
 
-func (hc Hipchat) SendNotification(roomId string, notification Notification) error {
-	return hc.client.SendNotification(roomId, ToHipChatNotification(notification))
+func (hc Hipchat) privateChatsClosed() bool {
+
+	hc.privateChats.Lock()
+	defer hc.privateChats.Unlock()
+	return hc.privateChats.closed
+}
+
+// PackUser returns the user the pack is running as, i.e. the owner of the HipChat tokens
+func (hc Hipchat) PackUser() (User, error) {
+
+	user, err := hc.client.GetTokenOwner()
+	if err != nil {
+		return User{}, err
+	}
+	return ToUser(*user), nil
+}
+
+// JoinRoom joins room, tags select the room for broadcast. Tags of already joined room are replaced if tags are set.
+func (hc Hipchat) JoinRoom(roomId string, tags []string) error {
+	return hc.joinRoom(roomId, JoinedByCommand, tags)
+}
+
+// JoinDefaultRoom joins room configured by DEFAULT_JOIN_ROOM env. var.
+func (hc Hipchat) JoinDefaultRoom(roomId string) error {
+	return hc.joinRoom(roomId, JoinedByConfig, nil)
 }
 
-func (hc Hipchat) JoinRoom(roomId string) error {
+func (hc Hipchat) joinRoom(roomId, joinedBy string, tags []string) error {
 
 	logger.Infof("joining room=%s", roomId)
-	if !hc.rooms.Add(roomId) {
+	if !hc.rooms.Add(roomId, joinedBy, tags) {
 		logger.Infof("room=%s already joined", roomId)
 		return nil
 	}
@@ -113,4 +273,16 @@ func (hc Hipchat) Shutdown() {
 		}
 	}
 	close(hc.rooms.messages)
+	hc.rooms.Close()
+
+	hc.privateChats.Lock()
+	hc.privateChats.closed = true
+	for _, chat := range hc.privateChats.chats {
+		chat.Leave()
+	}
+	hc.privateChats.Unlock()
+
+	if err := hc.rooms.store.Close(); err != nil {
+		logger.Errorf("cannot close rooms store: %v", err)
+	}
 }
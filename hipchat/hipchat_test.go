@@ -17,6 +17,7 @@ limitations under the License.
 package hipchat
 
 import (
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/tbruyelle/hipchat-go/hipchat"
 	"io/ioutil"
@@ -39,7 +40,7 @@ func TestNewHipchatNoBackedUpRooms(t *testing.T) {
 		return nil
 	}
 
-	hc, _ := NewHipchat(bkpPath, client, nil)
+	hc, _ := NewHipchat(bkp.NewFileStore(bkpPath), client, nil, Options{})
 
 	assert.Equal(t, 0, len(hc.JoinedRoomIds()))
 	assert.Equal(t, 0, len(notifiedRooms))
@@ -58,7 +59,7 @@ func TestNewHipchatBackedUpRooms(t *testing.T) {
 		return nil
 	}
 
-	hc, _ := NewHipchat(bkpPath, client, nil)
+	hc, _ := NewHipchat(bkp.NewFileStore(bkpPath), client, nil, Options{})
 
 	joinedRooms := hc.JoinedRoomIds()
 	assert.Equal(t, 2, len(joinedRooms))
@@ -82,18 +83,42 @@ func TestBroadcastMessage(t *testing.T) {
 		return nil
 	}
 
-	hc, _ := NewHipchat(bkpPath, client, nil)
+	hc, _ := NewHipchat(bkp.NewFileStore(bkpPath), client, nil, Options{})
 
 	assert.Equal(t, 0, len(notifiedRooms))
-	hc.JoinRoom("1")
-	hc.JoinRoom("2")
+	hc.JoinRoom("1", nil)
+	hc.JoinRoom("2", nil)
 
-	hc.BroadcastMessage("test broadcast message")
+	result, err := hc.BroadcastMessage("test broadcast message", RoomFilter{})
+	assert.Nil(t, err)
+	assert.Equal(t, BroadcastResult{Sent: []string{"1", "2"}, Failed: []RoomError{}, TimedOut: []string{}}, result)
 	assert.Equal(t, 2, len(notifiedRooms))
 	assert.Contains(t, notifiedRooms, "1")
 	assert.Contains(t, notifiedRooms, "2")
 }
 
+func TestPackUser(t *testing.T) {
+
+	client := NewClientMock()
+	hc := Hipchat{client: client}
+
+	user, err := hc.PackUser()
+
+	assert.Nil(t, err)
+	assert.Equal(t, User{Id: 9, MentionName: "flyte"}, user)
+}
+
+func TestPackUserFailed(t *testing.T) {
+
+	client := NewClientMock()
+	client.getTokenOwner = func() (*hipchat.User, error) { return nil, errors.New("Server returns status 401") }
+	hc := Hipchat{client: client}
+
+	_, err := hc.PackUser()
+
+	assert.EqualError(t, err, "Server returns status 401")
+}
+
 func TestSendMessage(t *testing.T) {
 
 	bkpPath := bkp.CreateBkpFile(createTestBkpDir(), "rooms.json")
@@ -106,7 +131,7 @@ func TestSendMessage(t *testing.T) {
 		return nil
 	}
 
-	hc, _ := NewHipchat(bkpPath, client, nil)
+	hc, _ := NewHipchat(bkp.NewFileStore(bkpPath), client, nil, Options{})
 
 	hc.SendMessage("the room id", "the message")
 	assert.Equal(t, 1, len(notifiedRooms))
@@ -125,13 +150,148 @@ func TestSendNotification(t *testing.T) {
 		return nil
 	}
 
-	hc, _ := NewHipchat(bkpPath, client, nil)
+	hc, _ := NewHipchat(bkp.NewFileStore(bkpPath), client, nil, Options{})
 
 	hc.SendNotification("the room id", joinNotification)
 	assert.Equal(t, 1, len(notifiedRooms))
 	assert.Equal(t, "the room id", notifiedRooms[0])
 }
 
+func TestSendNotificationWithCard(t *testing.T) {
+
+	var request *hipchat.NotificationRequest
+	client := NewClientMock()
+	client.sendNotification = func(roomId string, notification *hipchat.NotificationRequest) error {
+		request = notification
+		return nil
+	}
+	hc := Hipchat{client: client}
+
+	card := &Card{
+		Style:             "application",
+		Id:                "deploy-42",
+		Title:             "Deployment finished",
+		Description:       "<b>v1.2.3</b> deployed",
+		DescriptionFormat: "html",
+		Format:            "medium",
+		Url:               "http://deploy/42",
+		Icon:              "http://deploy/icon.png",
+		Thumbnail:         "http://deploy/thumb.png",
+		Activity:          &CardActivity{Html: "<b>jane</b> deployed", Icon: "http://deploy/jane.png"},
+		Attributes:        []CardAttribute{{Label: "env", Value: "prod", Style: "lozenge-success"}},
+	}
+	err := hc.SendNotification("the room id", Notification{Message: "v1.2.3 deployed", From: "deployer", Card: card})
+
+	assert.Nil(t, err)
+	expected := &hipchat.Card{
+		Style:       "application",
+		ID:          "deploy-42",
+		Title:       "Deployment finished",
+		Description: hipchat.CardDescription{Format: "html", Value: "<b>v1.2.3</b> deployed"},
+		Format:      "medium",
+		URL:         "http://deploy/42",
+		Icon:        &hipchat.Icon{URL: "http://deploy/icon.png"},
+		Thumbnail:   &hipchat.Thumbnail{URL: "http://deploy/thumb.png"},
+		Activity:    &hipchat.Activity{HTML: "<b>jane</b> deployed", Icon: &hipchat.Icon{URL: "http://deploy/jane.png"}},
+		Attributes:  []hipchat.Attribute{{Label: "env", Value: hipchat.AttributeValue{Label: "prod", Style: "lozenge-success"}}},
+	}
+	assert.Equal(t, expected, request.Card)
+}
+
+func TestSendNotificationWithoutCard(t *testing.T) {
+
+	var request *hipchat.NotificationRequest
+	client := NewClientMock()
+	client.sendNotification = func(roomId string, notification *hipchat.NotificationRequest) error {
+		request = notification
+		return nil
+	}
+	hc := Hipchat{client: client}
+
+	hc.SendNotification("the room id", joinNotification)
+	assert.Nil(t, request.Card)
+}
+
+func TestSendNotificationFailed(t *testing.T) {
+
+	bkpPath := bkp.CreateBkpFile(createTestBkpDir(), "rooms.json")
+	defer func() { os.Remove(bkpPath) }()
+
+	client := NewClientMock()
+	client.sendNotification = func(string, *hipchat.NotificationRequest) error {
+		return errors.New("Server returns status 500")
+	}
+
+	hc, _ := NewHipchat(bkp.NewFileStore(bkpPath), client, nil, Options{})
+
+	err := hc.SendNotification("the room id", joinNotification)
+	assert.Equal(t, "Server returns status 500", err.Error())
+}
+
+func TestSendPrivateMessage(t *testing.T) {
+
+	client := NewClientMock()
+	hc := Hipchat{client: client}
+
+	err := hc.SendPrivateMessage("@john", PrivateMessage{Message: "<b>hi</b>", MessageFormat: "html", Notify: true})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "@john", client.SendPrivateMessageCall.userId)
+	assert.Equal(t, &hipchat.MessageRequest{Message: "<b>hi</b>", MessageFormat: "html", Notify: true},
+		client.SendPrivateMessageCall.message)
+}
+
+func TestCreateRoom(t *testing.T) {
+
+	client := NewClientMock()
+	var request *hipchat.CreateRoomRequest
+	client.createRoom = func(r *hipchat.CreateRoomRequest) (*hipchat.Room, error) {
+		request = r
+		return &hipchat.Room{ID: 42}, nil
+	}
+	hc := Hipchat{client: client}
+
+	roomId, err := hc.CreateRoom(RoomSettings{Name: "INC-123", Topic: "investigating", Privacy: "private", OwnerUserId: "@jane"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "42", roomId)
+	assert.Equal(t, &hipchat.CreateRoomRequest{Name: "INC-123", Topic: "investigating", Privacy: "private", OwnerUserID: "@jane"}, request)
+}
+
+func TestCreateRoomFailed(t *testing.T) {
+
+	client := NewClientMock()
+	client.createRoom = func(*hipchat.CreateRoomRequest) (*hipchat.Room, error) {
+		return nil, errors.New("Server returns status 409")
+	}
+	hc := Hipchat{client: client}
+
+	_, err := hc.CreateRoom(RoomSettings{Name: "INC-123"})
+	assert.EqualError(t, err, "Server returns status 409")
+}
+
+func TestArchiveJoinedRoom(t *testing.T) {
+
+	bkpPath := bkp.CreateBkpFile(createTestBkpDir(), "archive-rooms.json")
+	defer func() { os.Remove(bkpPath) }()
+	ioutil.WriteFile(bkpPath, []byte(`["123"]`), 0644)
+
+	client := NewClientMock()
+	archived := ""
+	client.archiveRoom = func(roomId string) error {
+		archived = roomId
+		return nil
+	}
+	hc, _ := NewHipchat(bkp.NewFileStore(bkpPath), client, nil, Options{})
+
+	err := hc.ArchiveRoom("123")
+
+	assert.Nil(t, err)
+	assert.Equal(t, "123", archived)
+	assert.Equal(t, 0, len(hc.JoinedRoomIds()))
+	assert.Equal(t, leaveNotification.Message, client.SendNotificationCall.notification.Message)
+}
+
 func TestLeaveRoom(t *testing.T) {
 
 	bkpPath := bkp.CreateBkpFile(createTestBkpDir(), "rooms.json")
@@ -139,12 +299,12 @@ func TestLeaveRoom(t *testing.T) {
 
 	client := NewClientMock()
 
-	hc, _ := NewHipchat(bkpPath, client, nil)
+	hc, _ := NewHipchat(bkp.NewFileStore(bkpPath), client, nil, Options{})
 
 	assert.Equal(t, 0, len(hc.JoinedRoomIds()))
 
-	hc.JoinRoom("1")
-	hc.JoinRoom("2")
+	hc.JoinRoom("1", nil)
+	hc.JoinRoom("2", nil)
 	assert.Equal(t, 2, len(hc.JoinedRoomIds()))
 
 	hc.LeaveRoom("1")
@@ -0,0 +1,171 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hipchat
+
+import (
+	"errors"
+	"fmt"
+	"github.com/HotelsDotCom/flyte-hipchat/client"
+	hc "github.com/tbruyelle/hipchat-go/hipchat"
+	"time"
+)
+
+// max number of messages returned by GetHistory
+const MaxHistoryResults = 10000
+
+const defaultHistoryResults = 100
+
+// HipChat returns at most 75 messages for 'recent' history and does not page it
+const maxRecentHistoryResults = 75
+
+// latest history cannot be paged, the largest response includes the message itself
+const maxResultsAfterMessage = maxHistoryResults - 1
+
+// HistoryQuery selects room messages either posted between Since and Until or posted after AfterMessageId
+type HistoryQuery struct {
+	// zero time does not limit the range
+	Since time.Time
+	Until time.Time
+	// cannot be combined with Since and Until
+	AfterMessageId string
+	// defaults to 100
+	MaxResults int
+}
+
+func (q HistoryQuery) validate() error {
+
+	if q.AfterMessageId != "" && (!q.Since.IsZero() || !q.Until.IsZero()) {
+		return errors.New("after message id cannot be combined with since and until")
+	}
+	if !q.Since.IsZero() && !q.Until.IsZero() && q.Since.After(q.Until) {
+		return errors.New("since is after until")
+	}
+	if q.MaxResults < 0 || q.MaxResults > MaxHistoryResults {
+		return fmt.Errorf("max results must be between 1 and %d, or 0 for default %d", MaxHistoryResults, defaultHistoryResults)
+	}
+	if q.AfterMessageId != "" && q.MaxResults > maxResultsAfterMessage {
+		return fmt.Errorf("max results must be between 1 and %d with after message id, or 0 for default %d",
+			maxResultsAfterMessage, defaultHistoryResults)
+	}
+	return nil
+}
+
+// GetHistory returns room messages selected by query, oldest message first
+func (hc Hipchat) GetHistory(roomId string, query HistoryQuery) ([]Message, error) {
+
+	if err := query.validate(); err != nil {
+		return []Message{}, err
+	}
+
+	maxResults := query.MaxResults
+	if maxResults == 0 {
+		maxResults = defaultHistoryResults
+	}
+
+	if query.AfterMessageId != "" {
+		return getMessagesAfter(hc.client, roomId, query.AfterMessageId, maxResults)
+	}
+	return getMessagesBetween(hc.client, roomId, query.Since, query.Until, maxResults)
+}
+
+// getMessagesAfter returns the first maxResults messages posted after the message. Latest history returns the newest
+// messages (oldest first), it includes the message itself only if all the messages after it fit in the response, so
+// the largest response is requested if they do not. Error is returned if even that does not include the message.
+func getMessagesAfter(c client.HipchatClient, roomId, messageId string, maxResults int) ([]Message, error) {
+
+	limits := []int{maxResults + 1}
+	if maxResults+1 < maxHistoryResults {
+		limits = append(limits, maxHistoryResults)
+	}
+
+	for _, limit := range limits {
+		items, err := c.GetMessages(roomId, &hc.LatestHistoryOptions{MaxResults: limit, NotBefore: messageId})
+		if err != nil {
+			return []Message{}, err
+		}
+
+		if len(items) > 0 && items[0].ID == messageId {
+			items = items[1:]
+		} else if len(items) == limit {
+			// messages right after the message are not in the response
+			continue
+		}
+		if len(items) > maxResults {
+			items = items[:maxResults]
+		}
+		return ToMessages(roomId, items), nil
+	}
+	return []Message{}, fmt.Errorf("more than %d messages were posted after message=%s, use since instead",
+		maxResultsAfterMessage, messageId)
+}
+
+// getMessagesBetween pages through history from the newest message back, HipChat returns at most 1000 messages per
+// request
+func getMessagesBetween(c client.HipchatClient, roomId string, since, until time.Time, maxResults int) ([]Message, error) {
+
+	options := &hc.HistoryOptions{Date: "recent", Timezone: "UTC", Reverse: false}
+	if !since.IsZero() || !until.IsZero() || maxResults > maxRecentHistoryResults {
+		// end date and paging are applied only to history up to a date
+		if until.IsZero() {
+			until = time.Now()
+		}
+		options.Date = until.UTC().Format(time.RFC3339)
+	}
+	if !since.IsZero() {
+		options.EndDate = since.UTC().Format(time.RFC3339)
+	}
+
+	items := []hc.Message{}
+	for len(items) < maxResults {
+		options.StartIndex = len(items)
+		options.MaxResults = minInt(maxResults-len(items), maxHistoryResults)
+
+		page, err := c.GetHistory(roomId, options)
+		if err != nil {
+			return []Message{}, err
+		}
+		// messages are newest first, page with messages posted before since is the last one
+		page = postedSince(page, since)
+		items = append(items, page...)
+		if len(page) < options.MaxResults {
+			break
+		}
+	}
+
+	messages := ToMessages(roomId, items)
+	for i, j := 0, len(messages)-1; i < j; i, j = i+1, j-1 {
+		messages[i], messages[j] = messages[j], messages[i]
+	}
+	return messages, nil
+}
+
+// postedSince drops messages posted before since, messages with unknown date are kept
+func postedSince(messages []hc.Message, since time.Time) []hc.Message {
+
+	if since.IsZero() {
+		return messages
+	}
+
+	posted := []hc.Message{}
+	for _, m := range messages {
+		if date, err := time.Parse(time.RFC3339Nano, m.Date); err == nil && date.Before(since) {
+			continue
+		}
+		posted = append(posted, m)
+	}
+	return posted
+}
@@ -0,0 +1,266 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hipchat
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/tbruyelle/hipchat-go/hipchat"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestGetHistoryBetween(t *testing.T) {
+
+	cm := NewClientMock()
+	cm.getHistory = func(string, *hipchat.HistoryOptions) ([]hipchat.Message, error) {
+		// newest first
+		return []hipchat.Message{{ID: "2"}, {ID: "1"}}, nil
+	}
+	hc := Hipchat{client: cm}
+
+	since := time.Date(2018, 1, 1, 10, 0, 0, 0, time.UTC)
+	until := time.Date(2018, 1, 2, 12, 0, 0, 0, time.FixedZone("CET", 3600))
+	messages, err := hc.GetHistory("123", HistoryQuery{Since: since, Until: until})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(messages))
+	assert.Equal(t, "1", messages[0].Id)
+	assert.Equal(t, "123", messages[0].RoomId)
+	assert.Equal(t, "2", messages[1].Id)
+
+	assert.Equal(t, 1, len(cm.GetHistoryCalls))
+	options := cm.GetHistoryCalls[0].options
+	assert.Equal(t, "123", cm.GetHistoryCalls[0].roomID)
+	assert.Equal(t, "2018-01-02T11:00:00Z", options.Date)
+	assert.Equal(t, "2018-01-01T10:00:00Z", options.EndDate)
+	assert.Equal(t, 100, options.MaxResults)
+	assert.False(t, options.Reverse)
+}
+
+func TestGetHistoryRecent(t *testing.T) {
+
+	cm := NewClientMock()
+	hc := Hipchat{client: cm}
+
+	messages, err := hc.GetHistory("123", HistoryQuery{MaxResults: 10})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(messages))
+	assert.Equal(t, "recent", cm.GetHistoryCalls[0].options.Date)
+	assert.Equal(t, "", cm.GetHistoryCalls[0].options.EndDate)
+	assert.Equal(t, 10, cm.GetHistoryCalls[0].options.MaxResults)
+}
+
+func TestGetHistorySince(t *testing.T) {
+
+	cm := NewClientMock()
+	cm.getHistory = func(string, *hipchat.HistoryOptions) ([]hipchat.Message, error) {
+		return []hipchat.Message{
+			{ID: "3", Date: "2018-01-01T12:00:00.000000+00:00"},
+			{ID: "2", Date: "2018-01-01T10:00:00.000000+00:00"},
+			{ID: "1", Date: "2018-01-01T09:59:59.999999+00:00"},
+		}, nil
+	}
+	hc := Hipchat{client: cm}
+
+	since := time.Date(2018, 1, 1, 10, 0, 0, 0, time.UTC)
+	messages, err := hc.GetHistory("123", HistoryQuery{Since: since, MaxResults: 3})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(messages))
+	assert.Equal(t, "2", messages[0].Id)
+	assert.Equal(t, "3", messages[1].Id)
+
+	// end date is applied only with date, it defaults to now
+	options := cm.GetHistoryCalls[0].options
+	date, err := time.Parse(time.RFC3339, options.Date)
+	assert.Nil(t, err)
+	assert.WithinDuration(t, time.Now(), date, time.Minute)
+	assert.Equal(t, "2018-01-01T10:00:00Z", options.EndDate)
+}
+
+func TestGetHistorySinceStopsPaging(t *testing.T) {
+
+	cm := NewClientMock()
+	cm.getHistory = func(string, *hipchat.HistoryOptions) ([]hipchat.Message, error) {
+		page := historyPage(0, 1000)
+		page[999].Date = "2017-12-31T10:00:00.000000+00:00"
+		return page, nil
+	}
+	hc := Hipchat{client: cm}
+
+	messages, err := hc.GetHistory("123", HistoryQuery{Since: time.Date(2018, 1, 1, 10, 0, 0, 0, time.UTC), MaxResults: 5000})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 999, len(messages))
+	assert.Equal(t, 1, len(cm.GetHistoryCalls))
+}
+
+func TestGetHistoryPages(t *testing.T) {
+
+	cm := NewClientMock()
+	pages := []struct{ startIndex, maxResults int }{}
+	cm.getHistory = func(roomID string, options *hipchat.HistoryOptions) ([]hipchat.Message, error) {
+		pages = append(pages, struct{ startIndex, maxResults int }{options.StartIndex, options.MaxResults})
+		return historyPage(options.StartIndex, options.MaxResults), nil
+	}
+	hc := Hipchat{client: cm}
+
+	messages, err := hc.GetHistory("123", HistoryQuery{MaxResults: 2500})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2500, len(messages))
+	assert.Equal(t, []struct{ startIndex, maxResults int }{{0, 1000}, {1000, 1000}, {2000, 500}}, pages)
+	assert.NotEqual(t, "recent", cm.GetHistoryCalls[0].options.Date, "recent history cannot be paged")
+	assert.Equal(t, "0", messages[0].Id)
+	assert.Equal(t, "2499", messages[2499].Id)
+}
+
+func TestGetHistoryStopsAtLastPage(t *testing.T) {
+
+	cm := NewClientMock()
+	cm.getHistory = func(roomID string, options *hipchat.HistoryOptions) ([]hipchat.Message, error) {
+		if options.StartIndex > 0 {
+			return historyPage(options.StartIndex, 3), nil
+		}
+		return historyPage(options.StartIndex, options.MaxResults), nil
+	}
+	hc := Hipchat{client: cm}
+
+	messages, err := hc.GetHistory("123", HistoryQuery{MaxResults: 5000})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 1003, len(messages))
+	assert.Equal(t, 2, len(cm.GetHistoryCalls))
+}
+
+func TestGetHistoryFailed(t *testing.T) {
+
+	cm := NewClientMock()
+	cm.getHistory = func(string, *hipchat.HistoryOptions) ([]hipchat.Message, error) {
+		return []hipchat.Message{}, errors.New("room not found")
+	}
+	hc := Hipchat{client: cm}
+
+	messages, err := hc.GetHistory("123", HistoryQuery{})
+
+	assert.EqualError(t, err, "room not found")
+	assert.Equal(t, []Message{}, messages)
+}
+
+func TestGetHistoryAfterMessage(t *testing.T) {
+
+	cm := NewClientMock()
+	cm.getMessages = latestHistory(3)
+	hc := Hipchat{client: cm}
+
+	messages, err := hc.GetHistory("123", HistoryQuery{AfterMessageId: "1", MaxResults: 3})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(messages))
+	assert.Equal(t, "2", messages[0].Id)
+	assert.Equal(t, "3", messages[1].Id)
+	assert.Equal(t, "1", cm.GetMessagesCall.options.NotBefore)
+	assert.Equal(t, 4, cm.GetMessagesCall.options.MaxResults)
+}
+
+func TestGetHistoryAfterMessageReturnsFirstMessagesAfterIt(t *testing.T) {
+
+	var limits []int
+	history := latestHistory(10)
+	cm := NewClientMock()
+	cm.getMessages = func(roomID string, options *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
+		limits = append(limits, options.MaxResults)
+		return history(roomID, options)
+	}
+	hc := Hipchat{client: cm}
+
+	messages, err := hc.GetHistory("123", HistoryQuery{AfterMessageId: "1", MaxResults: 3})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"2", "3", "4"}, messageIds(messages))
+	assert.Equal(t, []int{4, 1000}, limits)
+}
+
+func TestGetHistoryAfterMessageTooManyMessages(t *testing.T) {
+
+	cm := NewClientMock()
+	cm.getMessages = latestHistory(2000)
+	hc := Hipchat{client: cm}
+
+	messages, err := hc.GetHistory("123", HistoryQuery{AfterMessageId: "1", MaxResults: 3})
+
+	assert.EqualError(t, err, "more than 999 messages were posted after message=1, use since instead")
+	assert.Equal(t, []Message{}, messages)
+}
+
+func TestGetHistoryInvalidQuery(t *testing.T) {
+
+	since := time.Date(2018, 1, 2, 10, 0, 0, 0, time.UTC)
+	until := time.Date(2018, 1, 1, 10, 0, 0, 0, time.UTC)
+	queries := map[string]HistoryQuery{
+		"after message id cannot be combined with since and until": {AfterMessageId: "1", Since: since},
+		"since is after until": {Since: since, Until: until},
+		"max results must be between 1 and 10000, or 0 for default 100":                     {MaxResults: 10001},
+		"max results must be between 1 and 999 with after message id, or 0 for default 100": {AfterMessageId: "1", MaxResults: 1000},
+	}
+
+	for expected, query := range queries {
+		cm := NewClientMock()
+		_, err := Hipchat{client: cm}.GetHistory("123", query)
+		assert.EqualError(t, err, expected)
+		assert.Equal(t, 0, len(cm.GetHistoryCalls))
+	}
+}
+
+// latestHistory returns latest history of room with messages 1 to count (oldest first), HipChat returns the newest
+// max results messages not before the message
+func latestHistory(count int) func(string, *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
+
+	return func(_ string, options *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
+		first, _ := strconv.Atoi(options.NotBefore)
+		if count-first+1 > options.MaxResults {
+			first = count - options.MaxResults + 1
+		}
+		messages := []hipchat.Message{}
+		for i := first; i <= count; i++ {
+			messages = append(messages, hipchat.Message{ID: strconv.Itoa(i)})
+		}
+		return messages, nil
+	}
+}
+
+func messageIds(messages []Message) []string {
+
+	ids := []string{}
+	for _, m := range messages {
+		ids = append(ids, m.Id)
+	}
+	return ids
+}
+
+// historyPage returns newest first messages, message ids are their position from the oldest message
+func historyPage(startIndex, maxResults int) []hipchat.Message {
+
+	messages := []hipchat.Message{}
+	for i := startIndex; i < startIndex+maxResults; i++ {
+		messages = append(messages, hipchat.Message{ID: strconv.Itoa(2499 - i)})
+	}
+	return messages
+}
@@ -18,6 +18,7 @@ package hipchat
 
 import (
 	hc "github.com/tbruyelle/hipchat-go/hipchat"
+	"regexp"
 	"strings"
 )
 
@@ -27,6 +28,42 @@ type Notification struct {
 	Notify        bool
 	Color         string
 	From          string
+	Card          *Card
+}
+
+// Card is rendered by hipchat clients instead of the notification message,
+// the message is still shown by clients that cannot display cards
+type Card struct {
+	Style             string
+	Id                string
+	Title             string
+	Description       string
+	DescriptionFormat string
+	Format            string
+	Url               string
+	Icon              string
+	Thumbnail         string
+	Activity          *CardActivity
+	Attributes        []CardAttribute
+}
+
+type CardActivity struct {
+	Html string
+	Icon string
+}
+
+type CardAttribute struct {
+	Label string
+	Value string
+	Url   string
+	Style string
+	Icon  string
+}
+
+type PrivateMessage struct {
+	Message       string
+	MessageFormat string
+	Notify        bool
 }
 
 type Message struct {
@@ -68,6 +105,30 @@ func toMessage(roomId string, hipchatMessage hc.Message) Message {
 	}
 }
 
+// IsMentioned returns true if user is one of the message mentions, user without id is never mentioned
+func (m Message) IsMentioned(user User) bool {
+
+	if user.Id == 0 {
+		return false
+	}
+	for _, u := range m.Mentions {
+		if u.Id == user.Id {
+			return true
+		}
+	}
+	return false
+}
+
+// StripMention returns message text without user's @mention (and the following colon or comma)
+func (m Message) StripMention(user User) string {
+
+	if user.MentionName == "" {
+		return m.Message
+	}
+	mention := regexp.MustCompile(`(?i)@` + regexp.QuoteMeta(user.MentionName) + `\b[:,]?\s*`)
+	return strings.TrimSpace(mention.ReplaceAllString(m.Message, ""))
+}
+
 func ToHipChatNotification(notification Notification) *hc.NotificationRequest {
 
 	return &hc.NotificationRequest{
@@ -76,6 +137,66 @@ func ToHipChatNotification(notification Notification) *hc.NotificationRequest {
 		Notify:        notification.Notify,
 		Color:         strToColor(notification.Color),
 		From:          notification.From,
+		Card:          toHipChatCard(notification.Card),
+	}
+}
+
+func toHipChatCard(card *Card) *hc.Card {
+
+	if card == nil {
+		return nil
+	}
+
+	hcCard := &hc.Card{
+		Style:       card.Style,
+		ID:          card.Id,
+		Title:       card.Title,
+		Description: hc.CardDescription{Format: card.DescriptionFormat, Value: card.Description},
+		Format:      card.Format,
+		URL:         card.Url,
+		Icon:        toHipChatIcon(card.Icon),
+	}
+
+	if card.Thumbnail != "" {
+		hcCard.Thumbnail = &hc.Thumbnail{URL: card.Thumbnail}
+	}
+	if card.Activity != nil {
+		hcCard.Activity = &hc.Activity{HTML: card.Activity.Html, Icon: toHipChatIcon(card.Activity.Icon)}
+	}
+	for _, a := range card.Attributes {
+		hcCard.Attributes = append(hcCard.Attributes, hc.Attribute{
+			Label: a.Label,
+			Value: hc.AttributeValue{Label: a.Value, URL: a.Url, Style: a.Style, Icon: toHipChatIcon(a.Icon)},
+		})
+	}
+	return hcCard
+}
+
+func toHipChatIcon(url string) *hc.Icon {
+
+	if url == "" {
+		return nil
+	}
+	return &hc.Icon{URL: url}
+}
+
+func ToHipChatMessageRequest(message PrivateMessage) *hc.MessageRequest {
+
+	return &hc.MessageRequest{
+		Message:       message.Message,
+		MessageFormat: message.MessageFormat,
+		Notify:        message.Notify,
+	}
+}
+
+func ToHipChatCreateRoomRequest(settings RoomSettings) *hc.CreateRoomRequest {
+
+	return &hc.CreateRoomRequest{
+		Name:        settings.Name,
+		Topic:       settings.Topic,
+		Privacy:     settings.Privacy,
+		OwnerUserID: settings.OwnerUserId,
+		GuestAccess: settings.GuestAccess,
 	}
 }
 
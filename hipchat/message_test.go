@@ -0,0 +1,56 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hipchat
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+var packUser = User{Id: 9, Name: "Flyte", MentionName: "flyte"}
+
+func TestIsMentioned(t *testing.T) {
+
+	message := Message{Message: "@flyte deploy", Mentions: []User{{Id: 1, MentionName: "john"}, packUser}}
+
+	assert.True(t, message.IsMentioned(packUser))
+	assert.False(t, message.IsMentioned(User{Id: 2, MentionName: "jane"}))
+}
+
+func TestIsMentionedUserWithoutId(t *testing.T) {
+
+	message := Message{Message: "@john hi", Mentions: []User{{MentionName: "john"}}}
+
+	assert.False(t, message.IsMentioned(User{}))
+}
+
+func TestStripMention(t *testing.T) {
+
+	tests := map[string]string{
+		"@flyte deploy app":       "deploy app",
+		"@Flyte: deploy app":      "deploy app",
+		"hey @flyte, deploy app":  "hey deploy app",
+		"deploy app @flyte":       "deploy app",
+		"@flyteadmin deploy app":  "@flyteadmin deploy app",
+		"@john @flyte deploy app": "@john deploy app",
+		"email flyte@example.com": "email flyte@example.com",
+	}
+
+	for text, expected := range tests {
+		assert.Equal(t, expected, Message{Message: text}.StripMention(packUser), text)
+	}
+}
@@ -0,0 +1,125 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hipchat
+
+import (
+	"github.com/HotelsDotCom/flyte-hipchat/client"
+	"github.com/HotelsDotCom/go-logger"
+	"strconv"
+	"time"
+)
+
+// ReceivedPrivateMessage is a message sent to the pack's user (token owner) in a private chat
+type ReceivedPrivateMessage struct {
+	Message
+	// user the pack is chatting with
+	Sender User `json:"sender"`
+}
+
+// PrivateChat polls history of the private chat between the pack's user and another user. HipChat does not send
+// private messages to webhooks.
+type PrivateChat struct {
+	user          User
+	client        client.HipchatClient
+	messages      chan ReceivedPrivateMessage
+	leave         chan bool
+	lastMessageId string
+	// set once the last message id at start up is known, the chat history may be empty
+	started bool
+}
+
+func NewPrivateChat(user User, client client.HipchatClient, messages chan ReceivedPrivateMessage) *PrivateChat {
+
+	chat := newPrivateChat(user, client, messages)
+	chat.monitor()
+	return chat
+}
+
+func newPrivateChat(user User, client client.HipchatClient, messages chan ReceivedPrivateMessage) *PrivateChat {
+	return &PrivateChat{user: user, client: client, messages: messages, leave: make(chan bool)}
+}
+
+func (c *PrivateChat) Leave() {
+	c.leave <- true
+}
+
+func (c *PrivateChat) monitor() {
+
+	go func() {
+		for {
+			select {
+			case <-c.leave:
+				return
+			default:
+				c.handleIncomingMessages()
+			}
+		}
+	}()
+}
+
+func (c *PrivateChat) handleIncomingMessages() {
+
+	messages, err := c.getLatestMessages()
+	if err != nil {
+		logger.Errorf("cannot get private chat history with user=%q: %v", c.user.MentionName, err)
+	}
+
+	if len(messages) == 0 {
+		time.Sleep(2 * time.Second)
+		return
+	}
+
+	for _, message := range messages {
+		c.lastMessageId = message.Id
+		// private chat history contains messages sent by the pack as well
+		if message.From.Id != c.user.Id {
+			continue
+		}
+		c.messages <- ReceivedPrivateMessage{Message: message, Sender: c.user}
+	}
+}
+
+func (c *PrivateChat) getLatestMessages() ([]Message, error) {
+
+	if !c.started {
+		// messages sent before the pack started are not handed over, only the last message id is set
+		messages, err := c.getHistory("", 1)
+		if err != nil {
+			return []Message{}, err
+		}
+		if len(messages) > 0 {
+			c.lastMessageId = messages[len(messages)-1].Id
+		}
+		c.started = true
+		return []Message{}, nil
+	}
+	// all messages are new if the history was empty at start up
+	return c.getHistory(c.lastMessageId, 100)
+}
+
+func (c *PrivateChat) getHistory(lastMessageId string, limit int) ([]Message, error) {
+
+	messages, err := c.client.GetPrivateMessages(strconv.Itoa(c.user.Id), hipChatHistoryOptions(lastMessageId, limit))
+	if err != nil {
+		return []Message{}, err
+	}
+
+	if len(messages) > 0 && messages[0].ID == lastMessageId {
+		messages = messages[1:]
+	}
+	return ToMessages("", messages), nil
+}
@@ -0,0 +1,221 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hipchat
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/tbruyelle/hipchat-go/hipchat"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestPrivateChatSkipsMessagesSentBeforeStart(t *testing.T) {
+
+	cm := NewClientMock()
+	var options *hipchat.LatestHistoryOptions
+	cm.getPrivateMessages = func(userID string, o *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
+		options = o
+		return []hipchat.Message{{ID: "1", From: map[string]interface{}{"id": float64(7)}}}, nil
+	}
+	chat := newPrivateChat(User{Id: 7}, cm, nil)
+
+	messages, err := chat.getLatestMessages()
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(messages))
+	assert.Equal(t, "1", chat.lastMessageId)
+	assert.Equal(t, 1, options.MaxResults)
+}
+
+func TestPrivateChatWithEmptyHistoryHandsOverFirstMessage(t *testing.T) {
+
+	cm := NewClientMock()
+	history := []hipchat.Message{}
+	var options *hipchat.LatestHistoryOptions
+	cm.getPrivateMessages = func(userID string, o *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
+		options = o
+		return history, nil
+	}
+	messages := make(chan ReceivedPrivateMessage, 10)
+	chat := newPrivateChat(User{Id: 7}, cm, messages)
+
+	// user has never sent a private message to the pack
+	received, err := chat.getLatestMessages()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(received))
+
+	history = []hipchat.Message{{ID: "1", Message: "deploy", From: map[string]interface{}{"id": float64(7)}}}
+	chat.handleIncomingMessages()
+
+	assert.Equal(t, "", options.NotBefore)
+	assert.Equal(t, "1", chat.lastMessageId)
+	assert.Equal(t, 1, len(messages))
+	assert.Equal(t, "deploy", (<-messages).Message.Message)
+}
+
+func TestPrivateChatStartFailed(t *testing.T) {
+
+	cm := NewClientMock()
+	cm.getPrivateMessages = func(string, *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
+		return []hipchat.Message{}, errors.New("Server returns status 500")
+	}
+	chat := newPrivateChat(User{Id: 7}, cm, nil)
+
+	_, err := chat.getLatestMessages()
+
+	assert.NotNil(t, err)
+	assert.False(t, chat.started, "last message id at start up should be fetched again")
+}
+
+func TestPrivateChatHandsOverMessagesFromUser(t *testing.T) {
+
+	cm := NewClientMock()
+	var userId string
+	var options *hipchat.LatestHistoryOptions
+	cm.getPrivateMessages = func(id string, o *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
+		userId = id
+		options = o
+		return []hipchat.Message{
+			{ID: "1"},
+			{ID: "2", Message: "hi", From: map[string]interface{}{"id": float64(7), "mention_name": "john"}},
+			{ID: "3", Message: "hello john", From: map[string]interface{}{"id": float64(99), "mention_name": "flyte"}},
+		}, nil
+	}
+
+	messages := make(chan ReceivedPrivateMessage, 10)
+	user := User{Id: 7, Name: "John", MentionName: "john"}
+	chat := newPrivateChat(user, cm, messages)
+	chat.lastMessageId = "1"
+	chat.started = true
+
+	chat.handleIncomingMessages()
+
+	assert.Equal(t, "7", userId)
+	assert.Equal(t, "1", options.NotBefore)
+	assert.Equal(t, "3", chat.lastMessageId)
+	assert.Equal(t, 1, len(messages))
+	message := <-messages
+	assert.Equal(t, "2", message.Id)
+	assert.Equal(t, "hi", message.Message.Message)
+	assert.Equal(t, user, message.Sender)
+}
+
+func TestPrivateChatHistoryFailed(t *testing.T) {
+
+	cm := NewClientMock()
+	cm.getPrivateMessages = func(string, *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
+		return []hipchat.Message{}, errors.New("user not found")
+	}
+	chat := newPrivateChat(User{Id: 7}, cm, nil)
+	chat.lastMessageId = "1"
+	chat.started = true
+
+	messages, err := chat.getLatestMessages()
+
+	assert.EqualError(t, err, "user not found")
+	assert.Equal(t, 0, len(messages))
+	assert.Equal(t, "1", chat.lastMessageId)
+}
+
+func TestMonitorPrivateChats(t *testing.T) {
+
+	cm := NewClientMock()
+	cm.getUser = func(userID string) (*hipchat.User, error) {
+		if userID == "unknown@example.com" {
+			return nil, errors.New("user not found")
+		}
+		return &hipchat.User{ID: 7, MentionName: "john"}, nil
+	}
+	polled := make(chan string, 10)
+	cm.getPrivateMessages = func(userID string, o *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
+		polled <- userID
+		return []hipchat.Message{}, nil
+	}
+	hc := Hipchat{client: cm, privateChats: &privateChats{}}
+
+	hc.MonitorPrivateChats([]string{"@john", "unknown@example.com"}, nil)
+
+	hc.privateChats.Lock()
+	assert.Equal(t, 1, len(hc.privateChats.chats))
+	assert.Equal(t, User{Id: 7, MentionName: "john"}, hc.privateChats.chats[0].user)
+	hc.privateChats.Unlock()
+	select {
+	case id := <-polled:
+		assert.Equal(t, "7", id)
+	case <-time.After(time.Second):
+		t.Error("private chat was not polled")
+	}
+	hc.privateChats.Lock()
+	hc.privateChats.closed = true
+	hc.privateChats.Unlock()
+}
+
+func TestMonitorPrivateChatsRetriesUserNotFound(t *testing.T) {
+
+	defer func(interval time.Duration) { privateChatRetryInterval = interval }(privateChatRetryInterval)
+	privateChatRetryInterval = time.Millisecond
+
+	var calls int32
+	cm := NewClientMock()
+	cm.getUser = func(userID string) (*hipchat.User, error) {
+		if atomic.AddInt32(&calls, 1) < 3 {
+			return nil, errors.New("Server returns status 503")
+		}
+		return &hipchat.User{ID: 7, MentionName: "john"}, nil
+	}
+	hc := Hipchat{client: cm, privateChats: &privateChats{}}
+
+	hc.MonitorPrivateChats([]string{"@john"}, nil)
+
+	assert.Equal(t, 0, len(hc.privateChats.chats))
+	for i := 0; i < 100 && !hasPrivateChats(hc); i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.True(t, hasPrivateChats(hc), "private chat was not retried")
+	assert.Equal(t, int32(3), atomic.LoadInt32(&calls))
+}
+
+func TestPrivateChatNotRetriedAfterShutdown(t *testing.T) {
+
+	defer func(interval time.Duration) { privateChatRetryInterval = interval }(privateChatRetryInterval)
+	privateChatRetryInterval = time.Millisecond
+
+	var calls int32
+	cm := NewClientMock()
+	cm.getUser = func(string) (*hipchat.User, error) {
+		atomic.AddInt32(&calls, 1)
+		return nil, errors.New("user not found")
+	}
+	hc := Hipchat{client: cm, privateChats: &privateChats{}}
+
+	hc.MonitorPrivateChats([]string{"@john"}, nil)
+	hc.privateChats.Lock()
+	hc.privateChats.closed = true
+	hc.privateChats.Unlock()
+	time.Sleep(20 * time.Millisecond)
+
+	assert.True(t, atomic.LoadInt32(&calls) <= 2, "private chat should not be retried after shutdown")
+}
+
+func hasPrivateChats(hc Hipchat) bool {
+
+	hc.privateChats.Lock()
+	defer hc.privateChats.Unlock()
+	return len(hc.privateChats.chats) != 0
+}
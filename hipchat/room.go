@@ -20,28 +20,234 @@ import (
 	hc "github.com/tbruyelle/hipchat-go/hipchat"
 	"log"
 	"github.com/HotelsDotCom/flyte-hipchat/client"
+	"github.com/HotelsDotCom/go-logger"
+	"sync"
 	"time"
 )
 
+const webhookName = "flyte-hipchat"
+
+// room option with id of the registered webhook, kept in the backup so the webhook can be deleted after restart
+const webhookIdOption = "webhookId"
+
+// HipChat returns at most 1000 messages from room history
+const maxHistoryResults = 1000
+
 type Room struct {
+	sync.Mutex
 	roomId        string
 	client        client.HipchatClient
 	messages      chan Message
 	leave         chan bool
 	lastMessageId string
+	webhookId     string
+	// held while the webhook is being registered, so the room is not left half way
+	joining sync.Mutex
+	// webhook messages are handed over after messages posted while the pack was down are replayed
+	delivery sync.Mutex
+	// ids of replayed messages, webhook can send them again
+	replayed map[string]bool
+	// number of messages to replay when resuming from lastMessageId, 0 skips messages posted in the meantime
+	maxReplay int
+	resumed   bool
+	// called after backed up state (last message id, webhook id) changed, used to persist it
+	changed  func()
+	joinedAt time.Time
+	joinedBy string
+	// room options kept in the backup
+	options map[string]string
+	// tags select rooms for broadcast
+	tags []string
 }
 
 func NewRoom(roomId string, client client.HipchatClient, messages chan Message) *Room {
 
-	room := &Room{roomId: roomId, client: client, messages: messages, leave: make(chan bool)}
+	room := newRoom(roomId, client, messages)
 	room.monitor()
 	return room
 }
 
+// NewWebhookRoom registers room_message webhook, HipChat will send room messages to webhookUrl. Room history is
+// polled instead if the webhook cannot be registered.
+func NewWebhookRoom(roomId string, client client.HipchatClient, messages chan Message, webhookUrl string) *Room {
+
+	room := newRoom(roomId, client, messages)
+	room.registerWebhook(webhookUrl)
+	return room
+}
+
+func newRoom(roomId string, client client.HipchatClient, messages chan Message) *Room {
+	return &Room{roomId: roomId, client: client, messages: messages, leave: make(chan bool), changed: func() {}}
+}
+
+// resumeFrom sets message id that was processed last (before restart), must be called before the room is monitored
+func (r *Room) resumeFrom(lastMessageId string, maxReplay int) {
+
+	if lastMessageId == "" {
+		return
+	}
+	r.lastMessageId = lastMessageId
+	r.maxReplay = maxReplay
+	r.resumed = true
+}
+
+func (r *Room) registerWebhook(webhookUrl string) {
+
+	r.joining.Lock()
+	defer r.joining.Unlock()
+
+	// webhook registered before restart would send every message twice
+	r.deleteStaleWebhook()
+
+	webhook := &hc.CreateWebhookRequest{Name: webhookName, Event: "room_message", URL: webhookUrl}
+	id, err := r.client.CreateWebhook(r.roomId, webhook)
+	if err != nil {
+		logger.Errorf("room=%s cannot register webhook, polling room history instead: %v", r.roomId, err)
+		r.monitor()
+		return
+	}
+
+	r.setWebhookId(id)
+	r.changed()
+	if r.resumed {
+		// messages posted while we were down are not sent by webhook, replay unlocks delivery when it is done
+		r.delivery.Lock()
+		go r.replay()
+	}
+}
+
+// replay hands over messages posted while the pack was down, webhook is already registered so no message is missed
+// in between. Delivery must be locked by the caller.
+func (r *Room) replay() {
+
+	defer r.delivery.Unlock()
+
+	messages, err := r.getLatestMessages()
+	if err != nil {
+		logger.Errorf("room=%s cannot replay messages posted while the pack was down: %v", r.roomId, err)
+		return
+	}
+
+	r.replayed = map[string]bool{}
+	for _, message := range messages {
+		r.replayed[message.Id] = true
+		r.setLastMessageId(message.Id)
+		r.messages <- message
+	}
+	if len(messages) != 0 {
+		r.changed()
+	}
+}
+
 func (r *Room) Leave() {
+
+	r.joining.Lock()
+	defer r.joining.Unlock()
+
+	if webhookId := r.getWebhookId(); webhookId != "" {
+		if err := r.client.DeleteWebhook(r.roomId, webhookId); err != nil {
+			logger.Errorf("room=%s cannot delete webhook=%s: %v", r.roomId, webhookId, err)
+		}
+		r.setWebhookId("")
+		r.changed()
+
+		// wait for the replay, messages must not be handed over after the room is left
+		r.delivery.Lock()
+		r.delivery.Unlock()
+		return
+	}
 	r.leave <- true
 }
 
+// deleteStaleWebhook deletes webhook registered before restart, must be called before the room receives messages
+func (r *Room) deleteStaleWebhook() {
+
+	webhookId := r.getWebhookId()
+	if webhookId == "" {
+		return
+	}
+	logger.Infof("room=%s deleting webhook=%s registered before restart", r.roomId, webhookId)
+	if err := r.client.DeleteWebhook(r.roomId, webhookId); err != nil {
+		logger.Errorf("room=%s cannot delete webhook=%s: %v", r.roomId, webhookId, err)
+	}
+	r.setWebhookId("")
+}
+
+func (r *Room) getWebhookId() string {
+
+	r.Lock()
+	defer r.Unlock()
+	return r.webhookId
+}
+
+func (r *Room) setWebhookId(id string) {
+
+	r.Lock()
+	defer r.Unlock()
+	r.webhookId = id
+}
+
+// backupOptions returns room options with id of the registered webhook
+func (r *Room) backupOptions() map[string]string {
+
+	r.Lock()
+	defer r.Unlock()
+
+	options := map[string]string{}
+	for k, v := range r.options {
+		if k != webhookIdOption {
+			options[k] = v
+		}
+	}
+	if r.webhookId != "" {
+		options[webhookIdOption] = r.webhookId
+	}
+
+	if len(options) == 0 {
+		return nil
+	}
+	return options
+}
+
+func (r *Room) hasAnyTag(tags map[string]bool) bool {
+
+	for _, t := range r.tags {
+		if tags[t] {
+			return true
+		}
+	}
+	return false
+}
+
+// LastMessageId returns id of the last message handed over to the messages channel
+func (r *Room) LastMessageId() string {
+
+	r.Lock()
+	defer r.Unlock()
+	return r.lastMessageId
+}
+
+func (r *Room) setLastMessageId(id string) {
+
+	r.Lock()
+	defer r.Unlock()
+	r.lastMessageId = id
+}
+
+// receive handles message sent by HipChat webhook
+func (r *Room) receive(message Message) {
+
+	r.delivery.Lock()
+	defer r.delivery.Unlock()
+
+	if r.replayed[message.Id] {
+		return
+	}
+	r.setLastMessageId(message.Id)
+	r.messages <- message
+	r.changed()
+}
+
 func (r *Room) monitor() {
 
 	go func() {
@@ -69,17 +275,48 @@ func (r *Room) handleIncomingMessages() {
 	}
 
 	for _, message := range messages {
-		r.lastMessageId = message.Id
+		r.setLastMessageId(message.Id)
 		r.messages <- message
 	}
+	r.changed()
 }
 
 func (r *Room) getLatestMessages() ([]Message, error) {
 
-	if r.lastMessageId == "" {
+	lastMessageId := r.LastMessageId()
+	if lastMessageId == "" {
 		return r.getLatestMessage()
 	}
-	return r.getHistory()
+
+	if r.resumed && r.maxReplay <= 0 {
+		// first call after restart with replay disabled, skip messages posted while the pack was down
+		return r.skipToLatestMessage()
+	}
+	if r.resumed {
+		// first call after restart, replay at most maxReplay messages
+		messages, err := r.getHistory(lastMessageId, minInt(r.maxReplay+1, maxHistoryResults))
+		if err == nil {
+			r.resumed = false
+		}
+		return messages, err
+	}
+	return r.getHistory(lastMessageId, 100)
+}
+
+// skipToLatestMessage moves last message id to the latest message in the room, no message is handed over
+func (r *Room) skipToLatestMessage() ([]Message, error) {
+
+	messages, err := r.getLatestMessage()
+	if err != nil {
+		return []Message{}, err
+	}
+	r.resumed = false
+	if len(messages) != 0 && messages[0].Id != r.LastMessageId() {
+		logger.Infof("room=%s replay is disabled, skipping messages posted since message=%s", r.roomId, r.LastMessageId())
+		r.setLastMessageId(messages[0].Id)
+		r.changed()
+	}
+	return []Message{}, nil
 }
 
 func (r *Room) getLatestMessage() ([]Message, error) {
@@ -91,18 +328,22 @@ func (r *Room) getLatestMessage() ([]Message, error) {
 	return ToMessages(r.roomId, messages), nil
 }
 
-func (r *Room) getHistory() ([]Message, error) {
+func (r *Room) getHistory(lastMessageId string, limit int) ([]Message, error) {
 
-	messages, err := r.client.GetMessages(r.roomId, hipChatHistoryOptions(r.lastMessageId, 100))
+	messages, err := r.client.GetMessages(r.roomId, hipChatHistoryOptions(lastMessageId, limit))
 	if err != nil {
 		return []Message{}, err
 	}
 
-	if len(messages) > 1 {
-		// response includes 'last message' at index 0
-		return ToMessages(r.roomId, messages[1:]), nil
+	// response includes 'last message' at index 0, unless there were more new messages than the limit
+	if len(messages) > 0 && messages[0].ID == lastMessageId {
+		messages = messages[1:]
+	} else if len(messages) == limit {
+		// limit counts 'last message', drop the oldest message so at most limit-1 new messages are handed over
+		logger.Errorf("room=%s more than %d messages since message=%s, older messages were skipped", r.roomId, limit-1, lastMessageId)
+		messages = messages[1:]
 	}
-	return []Message{}, nil
+	return ToMessages(r.roomId, messages), nil
 }
 
 func hipChatHistoryOptions(fromId string, limit int) *hc.LatestHistoryOptions {
@@ -113,3 +354,10 @@ func hipChatHistoryOptions(fromId string, limit int) *hc.LatestHistoryOptions {
 		NotBefore:  fromId,
 	}
 }
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -17,8 +17,10 @@ limitations under the License.
 package hipchat
 
 import (
+	"errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/tbruyelle/hipchat-go/hipchat"
+	"sync"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -55,6 +57,223 @@ func TestLeave(t *testing.T) {
 	assert.NotEqual(t, int32(0), counter, "no messages processed")
 }
 
+func TestGetHistoryDropsLastMessage(t *testing.T) {
+
+	cm := NewClientMock()
+	cm.getMessages = func(string, *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
+		return []hipchat.Message{{ID: "1"}, {ID: "2"}, {ID: "3"}}, nil
+	}
+
+	room := newRoom("abc", cm, nil)
+	messages, _ := room.getHistory("1", 100)
+
+	assert.Equal(t, 2, len(messages))
+	assert.Equal(t, "2", messages[0].Id)
+	assert.Equal(t, "3", messages[1].Id)
+}
+
+func TestGetHistoryMoreMessagesThanLimit(t *testing.T) {
+
+	cm := NewClientMock()
+	cm.getMessages = func(string, *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
+		return []hipchat.Message{{ID: "5"}, {ID: "6"}, {ID: "7"}}, nil
+	}
+
+	room := newRoom("abc", cm, nil)
+	messages, _ := room.getHistory("1", 3)
+
+	// limit counts the last message, oldest new message is dropped
+	assert.Equal(t, 2, len(messages))
+	assert.Equal(t, "6", messages[0].Id)
+	assert.Equal(t, "7", messages[1].Id)
+}
+
+func TestGetHistoryLessMessagesThanLimitWithoutLastMessage(t *testing.T) {
+
+	cm := NewClientMock()
+	cm.getMessages = func(string, *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
+		return []hipchat.Message{{ID: "5"}, {ID: "6"}}, nil
+	}
+
+	room := newRoom("abc", cm, nil)
+	messages, _ := room.getHistory("1", 3)
+
+	assert.Equal(t, 2, len(messages))
+	assert.Equal(t, "5", messages[0].Id)
+}
+
+func TestResumedRoomReplaysMaxMessages(t *testing.T) {
+
+	cm := NewClientMock()
+	room := newRoom("abc", cm, nil)
+	room.resumeFrom("1", 20)
+
+	room.getLatestMessages()
+	assert.Equal(t, "1", cm.GetMessagesCall.options.NotBefore)
+	assert.Equal(t, 21, cm.GetMessagesCall.options.MaxResults)
+
+	room.getLatestMessages()
+	assert.Equal(t, 100, cm.GetMessagesCall.options.MaxResults)
+}
+
+func TestResumedRoomReplayCappedByHipchatLimit(t *testing.T) {
+
+	cm := NewClientMock()
+	room := newRoom("abc", cm, nil)
+	room.resumeFrom("1", 5000)
+
+	room.getLatestMessages()
+	assert.Equal(t, 1000, cm.GetMessagesCall.options.MaxResults)
+}
+
+func TestResumeDisabled(t *testing.T) {
+
+	cm := NewClientMock()
+	room := newRoom("abc", cm, nil)
+	room.resumeFrom("1", 0)
+	cm.getMessages = func(string, *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
+		return []hipchat.Message{{ID: "5"}}, nil
+	}
+
+	messages, err := room.getLatestMessages()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(messages), "messages posted while the pack was down are skipped")
+	assert.Equal(t, "", cm.GetMessagesCall.options.NotBefore)
+	assert.Equal(t, 1, cm.GetMessagesCall.options.MaxResults)
+	assert.Equal(t, "5", room.LastMessageId())
+
+	cm.getMessages = func(string, *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
+		return []hipchat.Message{{ID: "5"}, {ID: "6"}}, nil
+	}
+	messages, _ = room.getLatestMessages()
+	assert.Equal(t, "5", cm.GetMessagesCall.options.NotBefore)
+	assert.Equal(t, 1, len(messages))
+	assert.Equal(t, "6", messages[0].Id)
+}
+
+func TestResumeDisabledLatestMessageNotHandedOverAgain(t *testing.T) {
+
+	cm := NewClientMock()
+	cm.getMessages = func(string, *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
+		return []hipchat.Message{{ID: "1"}}, nil
+	}
+	room := newRoom("abc", cm, nil)
+	room.resumeFrom("1", 0)
+
+	messages, err := room.getLatestMessages()
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(messages))
+	assert.Equal(t, "1", room.LastMessageId())
+}
+
+func TestNewWebhookRoom(t *testing.T) {
+
+	cm := NewClientMock()
+	cm.createWebhook = func(string, *hipchat.CreateWebhookRequest) (string, error) { return "42", nil }
+	var polled int32 = 0
+	cm.getMessages = func(string, *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
+		atomic.AddInt32(&polled, 1)
+		return []hipchat.Message{}, nil
+	}
+
+	room := NewWebhookRoom("abc", cm, nil, "https://flyte-hipchat/webhook?room=abc")
+
+	assert.Equal(t, "42", room.webhookId)
+	assert.Equal(t, "abc", cm.CreateWebhookCall.roomID)
+	assert.Equal(t, "room_message", cm.CreateWebhookCall.webhook.Event)
+	assert.Equal(t, "https://flyte-hipchat/webhook?room=abc", cm.CreateWebhookCall.webhook.URL)
+
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, int32(0), atomic.LoadInt32(&polled), "webhook room should not poll history")
+}
+
+func TestNewWebhookRoomFallsBackToPolling(t *testing.T) {
+
+	cm := NewClientMock()
+	cm.createWebhook = func(string, *hipchat.CreateWebhookRequest) (string, error) { return "", errors.New("forbidden") }
+	polled := make(chan bool, 1)
+	cm.getMessages = func(string, *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
+		select {
+		case polled <- true:
+		default:
+		}
+		return []hipchat.Message{}, nil
+	}
+
+	room := NewWebhookRoom("abc", cm, nil, "https://flyte-hipchat/webhook?room=abc")
+
+	assert.Equal(t, "", room.webhookId)
+	select {
+	case <-polled:
+	case <-time.After(time.Second):
+		t.Error("room history was not polled")
+	}
+}
+
+func TestNewWebhookRoomDeletesStaleWebhook(t *testing.T) {
+
+	cm := NewClientMock()
+	cm.createWebhook = func(string, *hipchat.CreateWebhookRequest) (string, error) { return "42", nil }
+	changed := 0
+
+	room := newRoom("abc", cm, nil)
+	room.webhookId = "41"
+	room.changed = func() { changed++ }
+	room.registerWebhook("https://flyte-hipchat/webhook?room=abc")
+
+	assert.Equal(t, DeleteWebhookCall{roomID: "abc", webhookID: "41"}, cm.DeleteWebhookCall)
+	assert.Equal(t, "42", room.webhookId)
+	assert.Equal(t, 1, changed, "webhook id should be backed up")
+}
+
+func TestWebhookMessagesWaitForReplay(t *testing.T) {
+
+	cm := NewClientMock()
+	cm.getMessages = func(string, *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
+		return []hipchat.Message{{ID: "1"}, {ID: "2"}, {ID: "3"}}, nil
+	}
+	messages := make(chan Message)
+
+	room := newRoom("abc", cm, messages)
+	room.resumeFrom("1", 10)
+	room.registerWebhook("https://flyte-hipchat/webhook?room=abc")
+
+	// posted after the webhook was registered, message 3 is replayed as well
+	go func() {
+		room.receive(Message{Id: "3"})
+		room.receive(Message{Id: "4"})
+	}()
+
+	received := []string{}
+	for len(received) < 3 {
+		select {
+		case m := <-messages:
+			received = append(received, m.Id)
+		case <-time.After(time.Second):
+			t.Fatalf("messages were not received, received=%v", received)
+		}
+	}
+	assert.Equal(t, []string{"2", "3", "4"}, received)
+	assert.Equal(t, "4", room.LastMessageId())
+
+	select {
+	case m := <-messages:
+		t.Errorf("unexpected message=%s", m.Id)
+	case <-time.After(10 * time.Millisecond):
+	}
+}
+
+func TestLeaveWebhookRoom(t *testing.T) {
+
+	cm := NewClientMock()
+	cm.createWebhook = func(string, *hipchat.CreateWebhookRequest) (string, error) { return "42", nil }
+
+	room := NewWebhookRoom("abc", cm, nil, "https://flyte-hipchat/webhook?room=abc")
+	room.Leave()
+
+	assert.Equal(t, DeleteWebhookCall{roomID: "abc", webhookID: "42"}, cm.DeleteWebhookCall)
+}
+
 // --- mocks ---
 
 type SendMessageCall struct {
@@ -67,19 +286,59 @@ type SendNotificationCall struct {
 	notification *hipchat.NotificationRequest
 }
 
+type SendPrivateMessageCall struct {
+	userId  string
+	message *hipchat.MessageRequest
+}
+
 type GetMessagesCall struct {
 	roomID  string
 	options *hipchat.LatestHistoryOptions
 }
 
+type GetHistoryCall struct {
+	roomID  string
+	options *hipchat.HistoryOptions
+}
+
+type CreateWebhookCall struct {
+	roomID  string
+	webhook *hipchat.CreateWebhookRequest
+}
+
+type DeleteWebhookCall struct {
+	roomID    string
+	webhookID string
+}
+
 type ClientMock struct {
-	SendMessageCall      SendMessageCall
-	SendNotificationCall SendNotificationCall
-	GetMessagesCall      GetMessagesCall
+	// guards call records, rooms and broadcasts call the client concurrently
+	mu                     sync.Mutex
+	SendMessageCall        SendMessageCall
+	SendNotificationCall   SendNotificationCall
+	SendPrivateMessageCall SendPrivateMessageCall
+	GetMessagesCall        GetMessagesCall
+	GetHistoryCalls        []GetHistoryCall
+	CreateWebhookCall      CreateWebhookCall
+	DeleteWebhookCall      DeleteWebhookCall
 
-	sendMessage      func(roomID, message string) error
-	sendNotification func(roomID string, notification *hipchat.NotificationRequest) error
-	getMessages      func(roomID string, options *hipchat.LatestHistoryOptions) ([]hipchat.Message, error)
+	sendMessage        func(roomID, message string) error
+	sendNotification   func(roomID string, notification *hipchat.NotificationRequest) error
+	sendPrivateMessage func(userID string, message *hipchat.MessageRequest) error
+	getMessages        func(roomID string, options *hipchat.LatestHistoryOptions) ([]hipchat.Message, error)
+	getHistory         func(roomID string, options *hipchat.HistoryOptions) ([]hipchat.Message, error)
+	getPrivateMessages func(userID string, options *hipchat.LatestHistoryOptions) ([]hipchat.Message, error)
+	getUser            func(userID string) (*hipchat.User, error)
+	getTokenOwner      func() (*hipchat.User, error)
+	setTopic           func(roomID, topic string) error
+	createRoom         func(room *hipchat.CreateRoomRequest) (*hipchat.Room, error)
+	archiveRoom        func(roomID string) error
+	addMember          func(roomID, userID string) error
+	removeMember       func(roomID, userID string) error
+	shareFile          func(roomID, filename string, content []byte, message string) error
+	shareLink          func(roomID, link, message string) error
+	createWebhook      func(roomID string, webhook *hipchat.CreateWebhookRequest) (string, error)
+	deleteWebhook      func(roomID, webhookID string) error
 }
 
 func NewClientMock() *ClientMock {
@@ -87,26 +346,114 @@ func NewClientMock() *ClientMock {
 	cm := &ClientMock{}
 	cm.sendMessage = func(string, string) error { return nil }
 	cm.sendNotification = func(string, *hipchat.NotificationRequest) error { return nil }
+	cm.sendPrivateMessage = func(string, *hipchat.MessageRequest) error { return nil }
 	cm.getMessages = func(string, *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
 		return []hipchat.Message{}, nil
 	}
+	cm.getHistory = func(string, *hipchat.HistoryOptions) ([]hipchat.Message, error) {
+		return []hipchat.Message{}, nil
+	}
+	cm.getPrivateMessages = func(string, *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
+		return []hipchat.Message{}, nil
+	}
+	cm.getUser = func(userID string) (*hipchat.User, error) { return &hipchat.User{MentionName: userID}, nil }
+	cm.getTokenOwner = func() (*hipchat.User, error) { return &hipchat.User{ID: 9, MentionName: "flyte"}, nil }
+	cm.setTopic = func(string, string) error { return nil }
+	cm.createRoom = func(*hipchat.CreateRoomRequest) (*hipchat.Room, error) { return &hipchat.Room{ID: 1}, nil }
+	cm.archiveRoom = func(string) error { return nil }
+	cm.addMember = func(string, string) error { return nil }
+	cm.removeMember = func(string, string) error { return nil }
+	cm.shareFile = func(string, string, []byte, string) error { return nil }
+	cm.shareLink = func(string, string, string) error { return nil }
+	cm.createWebhook = func(string, *hipchat.CreateWebhookRequest) (string, error) { return "1", nil }
+	cm.deleteWebhook = func(string, string) error { return nil }
 	return cm
 }
 
 func (cm *ClientMock) SendMessage(roomID, message string) error {
 
+	cm.mu.Lock()
 	cm.SendMessageCall = SendMessageCall{roomId: roomID, message: message}
+	cm.mu.Unlock()
 	return cm.sendMessage(roomID, message)
 }
 
 func (cm *ClientMock) SendNotification(roomID string, notification *hipchat.NotificationRequest) error {
 
+	cm.mu.Lock()
 	cm.SendNotificationCall = SendNotificationCall{roomId: roomID, notification: notification}
+	cm.mu.Unlock()
 	return cm.sendNotification(roomID, notification)
 }
 
+func (cm *ClientMock) SendPrivateMessage(userID string, message *hipchat.MessageRequest) error {
+
+	cm.SendPrivateMessageCall = SendPrivateMessageCall{userId: userID, message: message}
+	return cm.sendPrivateMessage(userID, message)
+}
+
 func (cm *ClientMock) GetMessages(roomID string, options *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
 
+	cm.mu.Lock()
 	cm.GetMessagesCall = GetMessagesCall{roomID: roomID, options: options}
+	cm.mu.Unlock()
 	return cm.getMessages(roomID, options)
 }
+
+func (cm *ClientMock) GetHistory(roomID string, options *hipchat.HistoryOptions) ([]hipchat.Message, error) {
+
+	cm.GetHistoryCalls = append(cm.GetHistoryCalls, GetHistoryCall{roomID: roomID, options: options})
+	return cm.getHistory(roomID, options)
+}
+
+func (cm *ClientMock) GetPrivateMessages(userID string, options *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
+	return cm.getPrivateMessages(userID, options)
+}
+
+func (cm *ClientMock) GetUser(userID string) (*hipchat.User, error) {
+	return cm.getUser(userID)
+}
+
+func (cm *ClientMock) GetTokenOwner() (*hipchat.User, error) {
+	return cm.getTokenOwner()
+}
+
+func (cm *ClientMock) SetTopic(roomID, topic string) error {
+	return cm.setTopic(roomID, topic)
+}
+
+func (cm *ClientMock) CreateRoom(room *hipchat.CreateRoomRequest) (*hipchat.Room, error) {
+	return cm.createRoom(room)
+}
+
+func (cm *ClientMock) ArchiveRoom(roomID string) error {
+	return cm.archiveRoom(roomID)
+}
+
+func (cm *ClientMock) AddMember(roomID, userID string) error {
+	return cm.addMember(roomID, userID)
+}
+
+func (cm *ClientMock) RemoveMember(roomID, userID string) error {
+	return cm.removeMember(roomID, userID)
+}
+
+func (cm *ClientMock) ShareFile(roomID, filename string, content []byte, message string) error {
+	return cm.shareFile(roomID, filename, content, message)
+}
+
+func (cm *ClientMock) ShareLink(roomID, link, message string) error {
+	return cm.shareLink(roomID, link, message)
+}
+
+func (cm *ClientMock) CreateWebhook(roomID string, webhook *hipchat.CreateWebhookRequest) (string, error) {
+
+	cm.CreateWebhookCall = CreateWebhookCall{roomID: roomID, webhook: webhook}
+	return cm.createWebhook(roomID, webhook)
+}
+
+func (cm *ClientMock) DeleteWebhook(roomID, webhookID string) error {
+
+	cm.DeleteWebhookCall = DeleteWebhookCall{roomID: roomID, webhookID: webhookID}
+	return cm.deleteWebhook(roomID, webhookID)
+}
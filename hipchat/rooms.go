@@ -17,61 +17,126 @@ limitations under the License.
 package hipchat
 
 import (
-	"encoding/json"
-	"io/ioutil"
+	"fmt"
+	"github.com/HotelsDotCom/flyte-hipchat/bkp"
 	"github.com/HotelsDotCom/flyte-hipchat/client"
 	"github.com/HotelsDotCom/go-logger"
+	"net/url"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// how often changed room state (last message id, webhook id) is backed up
+var backupInterval = 5 * time.Second
+
 type Rooms struct {
 	sync.RWMutex
-	backupPath string
+	store      bkp.RoomStore
 	client     client.HipchatClient
 	messages   chan Message
 	rooms      map[string]*Room
+	webhookUrl string
+	// random token added to webhook urls, webhook callbacks without it are rejected
+	webhookToken string
+	maxReplay    int
+	// held while the backup is saved, so backups are saved in order. Saving does not hold the rooms lock, it can take
+	// a while (fsync)
+	saving sync.Mutex
+	// set (1) when room state changed since the last backup
+	dirty int32
+	// no backup is saved after the rooms are closed
+	closed bool
+	done   chan struct{}
 }
 
-func NewRooms(backupPath string, client client.HipchatClient, messages chan Message) (*Rooms, error) {
+func NewRooms(store bkp.RoomStore, client client.HipchatClient, messages chan Message, opts Options) (*Rooms, error) {
 
 	r := &Rooms{
-		backupPath: backupPath,
-		client:     client,
-		messages:   messages,
-		rooms:      make(map[string]*Room),
+		store:     store,
+		client:    client,
+		messages:  messages,
+		rooms:     make(map[string]*Room),
+		maxReplay: opts.MaxReplay,
+		done:      make(chan struct{}),
+	}
+	if opts.Webhook != nil {
+		token, err := newWebhookToken()
+		if err != nil {
+			return r, err
+		}
+		r.webhookUrl = opts.Webhook.Url
+		r.webhookToken = token
+		if opts.Webhook.Listen != nil {
+			if err := opts.Webhook.Listen(webhookHandler{rooms: r}); err != nil {
+				return r, fmt.Errorf("cannot listen for webhooks: %v", err)
+			}
+		}
 	}
 
 	if err := r.loadRooms(); err != nil {
 		return r, err
 	}
+	go r.flushChanges(backupInterval)
 	return r, nil
 }
 
-func (r *Rooms) Add(roomId string) bool {
+// Add joins room, joinedBy records who asked for the room to be joined. Tags of already joined room are replaced if
+// tags are set.
+func (r *Rooms) Add(roomId, joinedBy string, tags []string) bool {
 
 	r.Lock()
-	defer r.Unlock()
-
-	if _, ok := r.rooms[roomId]; !ok {
-		r.rooms[roomId] = NewRoom(roomId, r.client, r.messages)
-		r.save()
-		return true
+	tags = normalizeTags(tags)
+	if room, ok := r.rooms[roomId]; ok {
+		if len(tags) != 0 {
+			room.tags = tags
+		}
+		r.Unlock()
+		if len(tags) != 0 {
+			r.save()
+		}
+		return false
 	}
-	return false
+
+	room := r.newRoom(bkp.Room{Id: roomId, JoinedAt: time.Now().UTC(), JoinedBy: joinedBy, Tags: tags})
+	r.rooms[roomId] = room
+	r.Unlock()
+	r.save()
+
+	r.start(room)
+	return true
 }
 
 func (r *Rooms) Remove(roomId string) {
 
 	r.Lock()
-	defer r.Unlock()
-
-	if room, ok := r.rooms[roomId]; ok {
+	room, ok := r.rooms[roomId]
+	if ok {
 		go room.Leave()
 		delete(r.rooms, roomId)
+	}
+	r.Unlock()
+
+	if ok {
 		r.save()
 	}
 }
 
+// Close stops backing up changed room state, the last changes are saved
+func (r *Rooms) Close() {
+
+	close(r.done)
+	r.saving.Lock()
+	defer r.saving.Unlock()
+
+	if atomic.SwapInt32(&r.dirty, 0) == 1 {
+		r.saveLocked()
+	}
+	r.closed = true
+}
+
 func (r *Rooms) Get(roomId string) *Room {
 
 	r.RLock()
@@ -91,38 +156,196 @@ func (r *Rooms) ListIds() []string {
 	return rooms
 }
 
+// Filter returns sorted ids of joined rooms selected by the filter and included rooms that are not joined
+func (r *Rooms) Filter(filter RoomFilter) (selected []string, notJoined []string) {
+
+	r.RLock()
+	defer r.RUnlock()
+
+	selected, notJoined = []string{}, []string{}
+	excluded := toSet(filter.ExcludeRooms)
+	included := toSet(filter.IncludeRooms)
+	tags := toSet(filter.Tags)
+	all := len(filter.Tags) == 0 && len(filter.IncludeRooms) == 0
+
+	for id, room := range r.rooms {
+		if excluded[id] {
+			continue
+		}
+		if all || included[id] || room.hasAnyTag(tags) {
+			selected = append(selected, id)
+		}
+	}
+
+	for id := range included {
+		if _, ok := r.rooms[id]; !ok && !excluded[id] {
+			notJoined = append(notJoined, id)
+		}
+	}
+
+	sort.Strings(selected)
+	sort.Strings(notJoined)
+	return selected, notJoined
+}
+
 func (r *Rooms) loadRooms() error {
 
-	b, err := ioutil.ReadFile(r.backupPath)
+	backup, err := r.store.Load()
 	if err != nil {
 		return err
 	}
 
-	roomIds := []string{}
-	if err = json.Unmarshal(b, &roomIds); err != nil {
-		return err
+	rooms := []*Room{}
+	r.Lock()
+	for _, b := range backup.Rooms {
+		room := r.newRoom(b)
+		r.rooms[b.Id] = room
+		rooms = append(rooms, room)
 	}
+	r.Unlock()
 
-	for _, id := range roomIds {
-		r.rooms[id] = NewRoom(id, r.client, r.messages)
+	for _, room := range rooms {
+		r.start(room)
 	}
 	return nil
 }
 
-func (r *Rooms) save() {
+func (r *Rooms) newRoom(backup bkp.Room) *Room {
 
-	rooms := []string{}
-	for k := range r.rooms {
-		rooms = append(rooms, k)
+	room := newRoom(backup.Id, r.client, r.messages)
+	room.joinedAt = backup.JoinedAt
+	room.joinedBy = backup.JoinedBy
+	room.options = backup.Options
+	room.webhookId = backup.Options[webhookIdOption]
+	room.tags = backup.Tags
+	room.resumeFrom(backup.LastMessageId, r.maxReplay)
+	room.changed = r.markChanged
+	return room
+}
+
+// start polls room history or registers webhook. Must not be called with the lock held, registering webhook can take
+// a while (HipChat calls are retried) and would block all the rooms.
+func (r *Rooms) start(room *Room) {
+
+	if r.webhookUrl == "" {
+		if room.getWebhookId() != "" {
+			room.deleteStaleWebhook()
+			room.changed()
+		}
+		room.monitor()
+		return
 	}
+	room.registerWebhook(webhookRoomUrl(r.webhookUrl, room.roomId, r.webhookToken))
+}
+
+// markChanged marks room state as changed, it is backed up by flushChanges. Rooms are not saved on every message,
+// saving the whole backup is slow.
+func (r *Rooms) markChanged() {
+	atomic.StoreInt32(&r.dirty, 1)
+}
+
+// flushChanges saves the backup every interval if room state changed, until the rooms are closed
+func (r *Rooms) flushChanges(interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-r.done:
+			return
+		case <-ticker.C:
+			r.flush()
+		}
+	}
+}
+
+// flush saves the backup if room state changed since the last backup
+func (r *Rooms) flush() {
 
-	b, err := json.Marshal(rooms)
+	r.saving.Lock()
+	defer r.saving.Unlock()
+
+	if !r.closed && atomic.SwapInt32(&r.dirty, 0) == 1 {
+		r.saveLocked()
+	}
+}
+
+// webhookRoomUrl adds room id to webhook url, so we know which joined room the callback is for (room can be
+// joined by name), and the token that authenticates the callback
+func webhookRoomUrl(webhookUrl, roomId, token string) string {
+
+	u, err := url.Parse(webhookUrl)
 	if err != nil {
-		logger.Errorf("saving rooms, cannot marshal: %v", err)
+		return webhookUrl
+	}
+	q := u.Query()
+	q.Set("room", roomId)
+	q.Set("token", token)
+	u.RawQuery = q.Encode()
+	return u.String()
+}
+
+// save saves the backup, must not be called with the rooms lock held
+func (r *Rooms) save() {
+
+	r.saving.Lock()
+	defer r.saving.Unlock()
+
+	if r.closed {
 		return
 	}
+	// backup includes all changes
+	atomic.StoreInt32(&r.dirty, 0)
+	r.saveLocked()
+}
+
+// saveLocked saves the backup, the saving lock must be held
+func (r *Rooms) saveLocked() {
+
+	r.RLock()
+	backup := bkp.NewBackup()
+	for k, room := range r.rooms {
+		backup.Rooms = append(backup.Rooms, bkp.Room{
+			Id:            k,
+			JoinedAt:      room.joinedAt,
+			JoinedBy:      room.joinedBy,
+			LastMessageId: room.LastMessageId(),
+			Options:       room.backupOptions(),
+			Tags:          room.tags,
+		})
+	}
+	r.RUnlock()
+
+	if err := r.store.Save(backup); err != nil {
+		logger.Errorf("cannot save rooms: %v", err)
+	}
+}
+
+// normalizeTags trims tags and removes empty and duplicate tags
+func normalizeTags(tags []string) []string {
+
+	normalized := []string{}
+	seen := map[string]bool{}
+	for _, t := range tags {
+		t = strings.TrimSpace(t)
+		if t != "" && !seen[t] {
+			seen[t] = true
+			normalized = append(normalized, t)
+		}
+	}
+
+	if len(normalized) == 0 {
+		return nil
+	}
+	return normalized
+}
+
+func toSet(values []string) map[string]bool {
 
-	if err := ioutil.WriteFile(r.backupPath, b, 0644); err != nil {
-		logger.Errorf("saving rooms, cannot write to file=%q: %v", r.backupPath, err)
+	set := map[string]bool{}
+	for _, v := range values {
+		set[v] = true
 	}
+	return set
 }
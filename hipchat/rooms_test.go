@@ -17,13 +17,18 @@ limitations under the License.
 package hipchat
 
 import (
+	"errors"
+	"github.com/HotelsDotCom/flyte-hipchat/bkp"
 	"github.com/stretchr/testify/assert"
 	"github.com/tbruyelle/hipchat-go/hipchat"
+	"io/ioutil"
+	"net/http"
 	"os"
-	"path/filepath"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
+	"time"
 )
 
 func TestAddAndRemoveRoom(t *testing.T) {
@@ -31,10 +36,10 @@ func TestAddAndRemoveRoom(t *testing.T) {
 	path := roomsPath()
 	defer func() { os.Remove(path) }()
 
-	rooms, _ := NewRooms(path, NewHipchatClientMock(), nil)
+	rooms, _ := NewRooms(bkp.NewFileStore(path), NewHipchatClientMock(), nil, Options{})
 	assert.Equal(t, 0, len(rooms.ListIds()))
 
-	rooms.Add("test room")
+	rooms.Add("test room", JoinedByCommand, nil)
 	assert.Equal(t, 1, len(rooms.ListIds()))
 	assert.Equal(t, "test room", rooms.Get("test room").roomId)
 
@@ -47,15 +52,15 @@ func TestAddRoomTwice(t *testing.T) {
 	path := roomsPath()
 	defer func() { os.Remove(path) }()
 
-	rooms, _ := NewRooms(path, NewHipchatClientMock(), nil)
+	rooms, _ := NewRooms(bkp.NewFileStore(path), NewHipchatClientMock(), nil, Options{})
 	assert.Equal(t, 0, len(rooms.ListIds()))
 
-	ok := rooms.Add("test room")
+	ok := rooms.Add("test room", JoinedByCommand, nil)
 	assert.True(t, ok)
 	assert.Equal(t, 1, len(rooms.ListIds()))
 	assert.Equal(t, "test room", rooms.Get("test room").roomId)
 
-	ok = rooms.Add("test room")
+	ok = rooms.Add("test room", JoinedByCommand, nil)
 	assert.False(t, ok)
 	assert.Equal(t, 1, len(rooms.ListIds()))
 	assert.Equal(t, "test room", rooms.Get("test room").roomId)
@@ -66,9 +71,9 @@ func TestConcurrentlyRemoveRooms(t *testing.T) {
 	path := roomsPath()
 	defer func() { os.Remove(path) }()
 
-	rooms, _ := NewRooms(path, NewHipchatClientMock(), nil)
+	rooms, _ := NewRooms(bkp.NewFileStore(path), NewHipchatClientMock(), nil, Options{})
 	for i := 0; i < 501; i++ {
-		rooms.Add(strconv.Itoa(i))
+		rooms.Add(strconv.Itoa(i), JoinedByCommand, nil)
 	}
 
 	var wg sync.WaitGroup
@@ -91,14 +96,14 @@ func TestConcurrentlyAddRooms(t *testing.T) {
 	path := roomsPath()
 	defer func() { os.Remove(path) }()
 
-	rooms, _ := NewRooms(path, NewHipchatClientMock(), nil)
+	rooms, _ := NewRooms(bkp.NewFileStore(path), NewHipchatClientMock(), nil, Options{})
 
 	var wg sync.WaitGroup
 	wg.Add(500)
 	for i := 0; i < 500; i++ {
 		go func(i int) {
 			defer wg.Done()
-			rooms.Add(strconv.Itoa(i))
+			rooms.Add(strconv.Itoa(i), JoinedByCommand, nil)
 		}(i)
 	}
 
@@ -106,38 +111,488 @@ func TestConcurrentlyAddRooms(t *testing.T) {
 	assert.Equal(t, 500, len(rooms.ListIds()))
 }
 
+func TestRegisteringWebhookDoesNotBlockRooms(t *testing.T) {
+
+	path := roomsPath()
+	defer func() { os.Remove(path) }()
+
+	registering := make(chan bool)
+	registered := make(chan bool)
+	client := NewHipchatClientMock()
+	client.createWebhook = func(roomID string, _ *hipchat.CreateWebhookRequest) (string, error) {
+		if roomID == "slow" {
+			registering <- true
+			<-registered
+		}
+		return "1", nil
+	}
+	rooms, _ := NewRooms(bkp.NewFileStore(path), client, nil, Options{Webhook: &WebhookOptions{Url: "https://flyte/webhook"}})
+
+	go rooms.Add("slow", JoinedByCommand, nil)
+	<-registering
+
+	done := make(chan bool)
+	go func() {
+		rooms.Add("fast", JoinedByCommand, nil)
+		rooms.Filter(RoomFilter{})
+		rooms.save()
+		done <- true
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("rooms are blocked while webhook is being registered")
+	}
+	assert.NotNil(t, rooms.Get("slow"))
+	close(registered)
+}
+
 func TestRoomsPersistence(t *testing.T) {
 
 	path := roomsPath()
 	defer func() { os.Remove(path) }()
 
-	rooms, _ := NewRooms(path, NewHipchatClientMock(), nil)
+	rooms, _ := NewRooms(bkp.NewFileStore(path), NewHipchatClientMock(), nil, Options{})
 
 	var wg sync.WaitGroup
 	wg.Add(500)
 	for i := 0; i < 500; i++ {
 		go func(i int) {
 			defer wg.Done()
-			rooms.Add(strconv.Itoa(i))
+			rooms.Add(strconv.Itoa(i), JoinedByCommand, nil)
 		}(i)
 	}
 	wg.Wait()
 
-	rooms2, _ := NewRooms(path, NewHipchatClientMock(), nil)
+	rooms2, _ := NewRooms(bkp.NewFileStore(path), NewHipchatClientMock(), nil, Options{})
 	assert.Equal(t, 500, len(rooms2.ListIds()))
 	assert.Equal(t, "483", rooms2.Get("483").roomId)
 }
 
+func TestLoadRoomIdsBackup(t *testing.T) {
+
+	path := bkp.CreateBkpFile(createTestBkpDir(), "cursor-rooms.json")
+	defer os.Remove(path)
+	ioutil.WriteFile(path, []byte(`["123", "456"]`), 0644)
+
+	rooms, err := NewRooms(bkp.NewFileStore(path), NewHipchatClientMock(), nil, Options{MaxReplay: 10})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 2, len(rooms.ListIds()))
+	assert.Equal(t, "", rooms.Get("123").LastMessageId())
+}
+
+func TestResumeFromBackedUpLastMessageId(t *testing.T) {
+
+	path := bkp.CreateBkpFile(createTestBkpDir(), "cursor-rooms.json")
+	defer os.Remove(path)
+	ioutil.WriteFile(path, []byte(`[{"roomId": "123", "lastMessageId": "msg-1"}]`), 0644)
+
+	options := make(chan *hipchat.LatestHistoryOptions, 1)
+	client := NewHipchatClientMock()
+	client.getMessages = func(roomID string, o *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
+		select {
+		case options <- o:
+		default:
+		}
+		return []hipchat.Message{}, nil
+	}
+
+	rooms, _ := NewRooms(bkp.NewFileStore(path), client, nil, Options{MaxReplay: 50})
+
+	assert.Equal(t, "msg-1", rooms.Get("123").LastMessageId())
+	select {
+	case o := <-options:
+		assert.Equal(t, "msg-1", o.NotBefore)
+		assert.Equal(t, 51, o.MaxResults)
+	case <-time.After(time.Second):
+		t.Error("room history was not requested")
+	}
+}
+
+func TestSaveLastMessageId(t *testing.T) {
+
+	path := bkp.CreateBkpFile(createTestBkpDir(), "cursor-rooms.json")
+	defer os.Remove(path)
+	ioutil.WriteFile(path, []byte(`[]`), 0644)
+
+	client := NewHipchatClientMock()
+	client.getMessages = func(string, *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
+		return []hipchat.Message{{ID: "msg-2"}}, nil
+	}
+
+	messages := make(chan Message)
+	rooms, _ := NewRooms(bkp.NewFileStore(path), client, messages, Options{MaxReplay: 10})
+	rooms.Add("123", JoinedByCommand, nil)
+	<-messages
+
+	var b []byte
+	for i := 0; i < 100; i++ {
+		// saved after message is handed over
+		rooms.flush()
+		if b, _ = ioutil.ReadFile(path); strings.Contains(string(b), "msg-2") {
+			break
+		}
+		time.Sleep(time.Millisecond)
+	}
+	backup, err := bkp.Decode(b)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(backup.Rooms))
+	assert.Equal(t, "123", backup.Rooms[0].Id)
+	assert.Equal(t, "msg-2", backup.Rooms[0].LastMessageId)
+
+	rooms2, _ := NewRooms(bkp.NewFileStore(path), NewHipchatClientMock(), nil, Options{MaxReplay: 10})
+	assert.Equal(t, "msg-2", rooms2.Get("123").LastMessageId())
+}
+
+func TestSaveRoomJoinDetails(t *testing.T) {
+
+	path := bkp.CreateBkpFile(createTestBkpDir(), "details-rooms.json")
+	defer os.Remove(path)
+	ioutil.WriteFile(path, []byte(`{"version": 1, "rooms": []}`), 0644)
+
+	rooms, _ := NewRooms(bkp.NewFileStore(path), NewHipchatClientMock(), nil, Options{})
+	rooms.Add("123", JoinedByConfig, nil)
+
+	b, _ := ioutil.ReadFile(path)
+	backup, err := bkp.Decode(b)
+	assert.Nil(t, err)
+	assert.Equal(t, bkp.Version, backup.Version)
+	assert.Equal(t, 1, len(backup.Rooms))
+	assert.Equal(t, JoinedByConfig, backup.Rooms[0].JoinedBy)
+	assert.False(t, backup.Rooms[0].JoinedAt.IsZero())
+}
+
+func TestWebhookIdIsBackedUp(t *testing.T) {
+
+	path := roomsPath()
+	defer os.Remove(path)
+	opts := Options{Webhook: &WebhookOptions{Url: "https://flyte/webhook"}}
+
+	client := NewHipchatClientMock()
+	client.createWebhook = func(string, *hipchat.CreateWebhookRequest) (string, error) { return "41", nil }
+	rooms, _ := NewRooms(bkp.NewFileStore(path), client, nil, opts)
+	rooms.Add("123", JoinedByCommand, nil)
+	rooms.flush()
+	assert.Equal(t, map[string]string{"webhookId": "41"}, loadBackup(t, path).Rooms[0].Options)
+
+	// restart, webhook registered before is deleted
+	deleted := []string{}
+	client.createWebhook = func(string, *hipchat.CreateWebhookRequest) (string, error) { return "42", nil }
+	client.deleteWebhook = func(roomID, webhookID string) error {
+		deleted = append(deleted, roomID+"/"+webhookID)
+		return nil
+	}
+	rooms, _ = NewRooms(bkp.NewFileStore(path), client, nil, opts)
+	rooms.flush()
+
+	assert.Equal(t, []string{"123/41"}, deleted)
+	assert.Equal(t, map[string]string{"webhookId": "42"}, loadBackup(t, path).Rooms[0].Options)
+}
+
+func TestWebhookListenerStartedBeforeRoomsAreLoaded(t *testing.T) {
+
+	path := roomsPath()
+	defer os.Remove(path)
+	ioutil.WriteFile(path, []byte(`{"version": 1, "rooms": [{"id": "123"}]}`), 0644)
+
+	events := []string{}
+	client := NewHipchatClientMock()
+	client.createWebhook = func(roomID string, _ *hipchat.CreateWebhookRequest) (string, error) {
+		events = append(events, "registered "+roomID)
+		return "42", nil
+	}
+	listen := func(handler http.Handler) error {
+		assert.NotNil(t, handler)
+		events = append(events, "listening")
+		return nil
+	}
+	opts := Options{Webhook: &WebhookOptions{Url: "https://flyte/webhook", Listen: listen}}
+
+	_, err := NewRooms(bkp.NewFileStore(path), client, nil, opts)
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"listening", "registered 123"}, events)
+}
+
+func TestRoomsNotLoadedIfWebhookListenerFailed(t *testing.T) {
+
+	path := roomsPath()
+	defer os.Remove(path)
+	ioutil.WriteFile(path, []byte(`{"version": 1, "rooms": [{"id": "123"}]}`), 0644)
+
+	registered := false
+	client := NewHipchatClientMock()
+	client.createWebhook = func(string, *hipchat.CreateWebhookRequest) (string, error) {
+		registered = true
+		return "42", nil
+	}
+	listen := func(http.Handler) error { return errors.New("address already in use") }
+	opts := Options{Webhook: &WebhookOptions{Url: "https://flyte/webhook", Listen: listen}}
+
+	rooms, err := NewRooms(bkp.NewFileStore(path), client, nil, opts)
+
+	assert.EqualError(t, err, "cannot listen for webhooks: address already in use")
+	assert.False(t, registered)
+	assert.Equal(t, []string{}, rooms.ListIds())
+}
+
+func TestStaleWebhookIsDeletedWhenPolling(t *testing.T) {
+
+	path := roomsPath()
+	defer os.Remove(path)
+	ioutil.WriteFile(path, []byte(`{"version": 1, "rooms": [{"id": "123", "options": {"webhookId": "41", "a": "b"}}]}`), 0644)
+
+	deleted := []string{}
+	client := NewHipchatClientMock()
+	client.deleteWebhook = func(roomID, webhookID string) error {
+		deleted = append(deleted, roomID+"/"+webhookID)
+		return nil
+	}
+	rooms, _ := NewRooms(bkp.NewFileStore(path), client, nil, Options{})
+	rooms.flush()
+
+	assert.Equal(t, []string{"123/41"}, deleted)
+	assert.Equal(t, map[string]string{"a": "b"}, loadBackup(t, path).Rooms[0].Options)
+}
+
+func TestLoadVersionedBackup(t *testing.T) {
+
+	path := bkp.CreateBkpFile(createTestBkpDir(), "versioned-rooms.json")
+	defer os.Remove(path)
+	ioutil.WriteFile(path, []byte(`{"version": 1, "rooms": [{"id": "123", "joinedAt": "2018-01-02T10:00:00Z", `+
+		`"joinedBy": "command", "options": {"colour": "red"}}]}`), 0644)
+
+	rooms, err := NewRooms(bkp.NewFileStore(path), NewHipchatClientMock(), nil, Options{})
+
+	assert.Nil(t, err)
+	room := rooms.Get("123")
+	assert.Equal(t, "command", room.joinedBy)
+	assert.Equal(t, time.Date(2018, 1, 2, 10, 0, 0, 0, time.UTC), room.joinedAt)
+	assert.Equal(t, map[string]string{"colour": "red"}, room.options)
+}
+
+func TestSaveRoomTags(t *testing.T) {
+
+	path := roomsPath()
+	defer os.Remove(path)
+
+	rooms, _ := NewRooms(bkp.NewFileStore(path), NewHipchatClientMock(), nil, Options{})
+	rooms.Add("123", JoinedByCommand, []string{" ops ", "eu", "ops", ""})
+
+	rooms2, _ := NewRooms(bkp.NewFileStore(path), NewHipchatClientMock(), nil, Options{})
+	assert.Equal(t, []string{"ops", "eu"}, rooms2.Get("123").tags)
+}
+
+func TestAddJoinedRoomReplacesTags(t *testing.T) {
+
+	path := roomsPath()
+	defer os.Remove(path)
+
+	rooms, _ := NewRooms(bkp.NewFileStore(path), NewHipchatClientMock(), nil, Options{})
+	rooms.Add("123", JoinedByCommand, []string{"ops"})
+
+	assert.False(t, rooms.Add("123", JoinedByCommand, nil))
+	assert.Equal(t, []string{"ops"}, rooms.Get("123").tags)
+
+	assert.False(t, rooms.Add("123", JoinedByCommand, []string{"eu"}))
+	assert.Equal(t, []string{"eu"}, rooms.Get("123").tags)
+}
+
+func TestFilterRooms(t *testing.T) {
+
+	path := roomsPath()
+	defer os.Remove(path)
+
+	rooms, _ := NewRooms(bkp.NewFileStore(path), NewHipchatClientMock(), nil, Options{})
+	rooms.Add("1", JoinedByCommand, []string{"ops", "eu"})
+	rooms.Add("2", JoinedByCommand, []string{"ops"})
+	rooms.Add("3", JoinedByCommand, []string{"dev"})
+	rooms.Add("4", JoinedByConfig, nil)
+
+	cases := []struct {
+		filter            RoomFilter
+		expectedSelected  []string
+		expectedNotJoined []string
+	}{
+		{RoomFilter{}, []string{"1", "2", "3", "4"}, []string{}},
+		{RoomFilter{Tags: []string{"ops"}}, []string{"1", "2"}, []string{}},
+		{RoomFilter{Tags: []string{"eu", "dev"}}, []string{"1", "3"}, []string{}},
+		{RoomFilter{Tags: []string{"ops"}, IncludeRooms: []string{"4", "5"}}, []string{"1", "2", "4"}, []string{"5"}},
+		{RoomFilter{IncludeRooms: []string{"3"}}, []string{"3"}, []string{}},
+		{RoomFilter{Tags: []string{"ops"}, ExcludeRooms: []string{"2"}}, []string{"1"}, []string{}},
+		{RoomFilter{ExcludeRooms: []string{"1", "2"}}, []string{"3", "4"}, []string{}},
+		{RoomFilter{IncludeRooms: []string{"5"}, ExcludeRooms: []string{"5"}}, []string{}, []string{}},
+		{RoomFilter{Tags: []string{"qa"}}, []string{}, []string{}},
+	}
+
+	for _, c := range cases {
+		selected, notJoined := rooms.Filter(c.filter)
+		assert.Equal(t, c.expectedSelected, selected, "filter: %+v", c.filter)
+		assert.Equal(t, c.expectedNotJoined, notJoined, "filter: %+v", c.filter)
+	}
+}
+
+func TestInvalidBackupIsMovedAside(t *testing.T) {
+
+	path := bkp.CreateBkpFile(createTestBkpDir(), "invalid-rooms.json")
+	defer os.Remove(path)
+	defer os.Remove(path + ".invalid")
+	defer os.Remove(path + bkp.PrevSuffix)
+	ioutil.WriteFile(path, []byte(`{"version": 7, "rooms": []}`), 0644)
+
+	rooms, err := NewRooms(bkp.NewFileStore(path), NewHipchatClientMock(), nil, Options{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(rooms.ListIds()))
+	b, _ := ioutil.ReadFile(path + ".invalid")
+	assert.Equal(t, `{"version": 7, "rooms": []}`, string(b))
+
+	rooms.Add("123", JoinedByCommand, nil)
+	b, _ = ioutil.ReadFile(path + ".invalid")
+	assert.Equal(t, `{"version": 7, "rooms": []}`, string(b), "invalid backup should not be overwritten")
+}
+
+func TestLoadPreviousBackupWhenBackupIsTruncated(t *testing.T) {
+
+	path := bkp.CreateBkpFile(createTestBkpDir(), "truncated-rooms.json")
+	defer os.Remove(path)
+	defer os.Remove(path + bkp.PrevSuffix)
+	defer os.Remove(path + ".invalid")
+	ioutil.WriteFile(path, []byte(`{"version": 1, "rooms": []}`), 0644)
+
+	rooms, _ := NewRooms(bkp.NewFileStore(path), NewHipchatClientMock(), nil, Options{})
+	rooms.Add("123", JoinedByCommand, nil)
+	rooms.Add("456", JoinedByCommand, nil)
+
+	// crash while backup was written
+	b, _ := ioutil.ReadFile(path)
+	ioutil.WriteFile(path, b[:len(b)/2], 0644)
+
+	rooms, err := NewRooms(bkp.NewFileStore(path), NewHipchatClientMock(), nil, Options{})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"123"}, rooms.ListIds())
+	invalid, _ := ioutil.ReadFile(path + ".invalid")
+	assert.Equal(t, string(b[:len(b)/2]), string(invalid))
+}
+
+func TestRoomChangesAreSavedByFlush(t *testing.T) {
+
+	store := NewStoreMock()
+	rooms, _ := NewRooms(store, NewHipchatClientMock(), nil, Options{})
+
+	rooms.markChanged()
+	assert.Equal(t, 0, store.Saves(), "changes are not saved one by one")
+
+	rooms.flush()
+	assert.Equal(t, 1, store.Saves())
+
+	rooms.flush()
+	assert.Equal(t, 1, store.Saves(), "nothing changed since the last backup")
+}
+
+func TestRoomChangesAreSavedPeriodically(t *testing.T) {
+
+	defer func(interval time.Duration) { backupInterval = interval }(backupInterval)
+	backupInterval = 10 * time.Millisecond
+
+	store := NewStoreMock()
+	rooms, _ := NewRooms(store, NewHipchatClientMock(), nil, Options{})
+	defer rooms.Close()
+	rooms.markChanged()
+
+	for i := 0; i < 100 && store.Saves() == 0; i++ {
+		time.Sleep(5 * time.Millisecond)
+	}
+	assert.Equal(t, 1, store.Saves())
+}
+
+func TestCloseSavesChanges(t *testing.T) {
+
+	store := NewStoreMock()
+	rooms, _ := NewRooms(store, NewHipchatClientMock(), nil, Options{})
+
+	rooms.markChanged()
+	rooms.Close()
+	assert.Equal(t, 1, store.Saves())
+
+	rooms.markChanged()
+	rooms.flush()
+	rooms.save()
+	assert.Equal(t, 1, store.Saves(), "no backup is saved after rooms are closed")
+}
+
+func TestSavingDoesNotBlockRooms(t *testing.T) {
+
+	saving := make(chan bool)
+	saved := make(chan bool)
+	store := NewStoreMock()
+	rooms, _ := NewRooms(store, NewHipchatClientMock(), nil, Options{})
+	rooms.Add("123", JoinedByCommand, nil)
+	store.mu.Lock()
+	store.save = func(bkp.Backup) error {
+		saving <- true
+		<-saved
+		return nil
+	}
+	store.mu.Unlock()
+
+	rooms.markChanged()
+	go rooms.flush()
+	<-saving
+
+	done := make(chan bool)
+	go func() {
+		rooms.Get("123")
+		rooms.ListIds()
+		rooms.Filter(RoomFilter{})
+		done <- true
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Error("rooms are blocked while the backup is being saved")
+	}
+	close(saved)
+}
+
+func loadBackup(t *testing.T, path string) bkp.Backup {
+
+	b, _ := ioutil.ReadFile(path)
+	backup, err := bkp.Decode(b)
+	assert.Nil(t, err)
+	return backup
+}
+
 func roomsPath() string {
 
-	path, _ := filepath.Abs(filepath.Dir(filepath.Join(os.Args[0], "test_rooms.json")))
+	path := bkp.CreateBkpFile(createTestBkpDir(), "test_rooms.json")
+	os.Remove(path + bkp.PrevSuffix)
+	ioutil.WriteFile(path, []byte(`{"version": 1, "rooms": []}`), 0644)
 	return path
 }
 
 type HipchatClientMock struct {
-	sendMessage      func(roomID, message string) error
-	sendNotification func(roomID string, notification *hipchat.NotificationRequest) error
-	getMessages      func(roomID string, options *hipchat.LatestHistoryOptions) ([]hipchat.Message, error)
+	sendMessage        func(roomID, message string) error
+	sendNotification   func(roomID string, notification *hipchat.NotificationRequest) error
+	sendPrivateMessage func(userID string, message *hipchat.MessageRequest) error
+	getMessages        func(roomID string, options *hipchat.LatestHistoryOptions) ([]hipchat.Message, error)
+	getHistory         func(roomID string, options *hipchat.HistoryOptions) ([]hipchat.Message, error)
+	getPrivateMessages func(userID string, options *hipchat.LatestHistoryOptions) ([]hipchat.Message, error)
+	getUser            func(userID string) (*hipchat.User, error)
+	getTokenOwner      func() (*hipchat.User, error)
+	setTopic           func(roomID, topic string) error
+	createRoom         func(room *hipchat.CreateRoomRequest) (*hipchat.Room, error)
+	archiveRoom        func(roomID string) error
+	addMember          func(roomID, userID string) error
+	removeMember       func(roomID, userID string) error
+	shareFile          func(roomID, filename string, content []byte, message string) error
+	shareLink          func(roomID, link, message string) error
+	createWebhook      func(roomID string, webhook *hipchat.CreateWebhookRequest) (string, error)
+	deleteWebhook      func(roomID, webhookID string) error
 }
 
 func NewHipchatClientMock() HipchatClientMock {
@@ -145,9 +600,27 @@ func NewHipchatClientMock() HipchatClientMock {
 	hc := HipchatClientMock{}
 	hc.sendMessage = func(string, string) error { return nil }
 	hc.sendNotification = func(string, *hipchat.NotificationRequest) error { return nil }
+	hc.sendPrivateMessage = func(string, *hipchat.MessageRequest) error { return nil }
 	hc.getMessages = func(string, *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
 		return []hipchat.Message{}, nil
 	}
+	hc.getHistory = func(string, *hipchat.HistoryOptions) ([]hipchat.Message, error) {
+		return []hipchat.Message{}, nil
+	}
+	hc.getPrivateMessages = func(string, *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
+		return []hipchat.Message{}, nil
+	}
+	hc.getUser = func(string) (*hipchat.User, error) { return &hipchat.User{}, nil }
+	hc.getTokenOwner = func() (*hipchat.User, error) { return &hipchat.User{}, nil }
+	hc.setTopic = func(string, string) error { return nil }
+	hc.createRoom = func(*hipchat.CreateRoomRequest) (*hipchat.Room, error) { return &hipchat.Room{ID: 1}, nil }
+	hc.archiveRoom = func(string) error { return nil }
+	hc.addMember = func(string, string) error { return nil }
+	hc.removeMember = func(string, string) error { return nil }
+	hc.shareFile = func(string, string, []byte, string) error { return nil }
+	hc.shareLink = func(string, string, string) error { return nil }
+	hc.createWebhook = func(string, *hipchat.CreateWebhookRequest) (string, error) { return "1", nil }
+	hc.deleteWebhook = func(string, string) error { return nil }
 	return hc
 }
 
@@ -159,6 +632,96 @@ func (hc HipchatClientMock) SendNotification(roomID string, notification *hipcha
 	return hc.sendNotification(roomID, notification)
 }
 
+func (hc HipchatClientMock) SendPrivateMessage(userID string, message *hipchat.MessageRequest) error {
+	return hc.sendPrivateMessage(userID, message)
+}
+
 func (hc HipchatClientMock) GetMessages(roomID string, options *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
 	return hc.getMessages(roomID, options)
 }
+
+func (hc HipchatClientMock) GetHistory(roomID string, options *hipchat.HistoryOptions) ([]hipchat.Message, error) {
+	return hc.getHistory(roomID, options)
+}
+
+func (hc HipchatClientMock) GetPrivateMessages(userID string, options *hipchat.LatestHistoryOptions) ([]hipchat.Message, error) {
+	return hc.getPrivateMessages(userID, options)
+}
+
+func (hc HipchatClientMock) GetUser(userID string) (*hipchat.User, error) {
+	return hc.getUser(userID)
+}
+
+func (hc HipchatClientMock) GetTokenOwner() (*hipchat.User, error) {
+	return hc.getTokenOwner()
+}
+
+func (hc HipchatClientMock) SetTopic(roomID, topic string) error {
+	return hc.setTopic(roomID, topic)
+}
+
+func (hc HipchatClientMock) CreateRoom(room *hipchat.CreateRoomRequest) (*hipchat.Room, error) {
+	return hc.createRoom(room)
+}
+
+func (hc HipchatClientMock) ArchiveRoom(roomID string) error {
+	return hc.archiveRoom(roomID)
+}
+
+func (hc HipchatClientMock) AddMember(roomID, userID string) error {
+	return hc.addMember(roomID, userID)
+}
+
+func (hc HipchatClientMock) RemoveMember(roomID, userID string) error {
+	return hc.removeMember(roomID, userID)
+}
+
+func (hc HipchatClientMock) ShareFile(roomID, filename string, content []byte, message string) error {
+	return hc.shareFile(roomID, filename, content, message)
+}
+
+func (hc HipchatClientMock) ShareLink(roomID, link, message string) error {
+	return hc.shareLink(roomID, link, message)
+}
+
+func (hc HipchatClientMock) CreateWebhook(roomID string, webhook *hipchat.CreateWebhookRequest) (string, error) {
+	return hc.createWebhook(roomID, webhook)
+}
+
+func (hc HipchatClientMock) DeleteWebhook(roomID, webhookID string) error {
+	return hc.deleteWebhook(roomID, webhookID)
+}
+
+type StoreMock struct {
+	mu    sync.Mutex
+	saves int
+	save  func(backup bkp.Backup) error
+}
+
+func NewStoreMock() *StoreMock {
+	return &StoreMock{save: func(bkp.Backup) error { return nil }}
+}
+
+func (s *StoreMock) Load() (bkp.Backup, error) {
+	return bkp.NewBackup(), nil
+}
+
+func (s *StoreMock) Save(backup bkp.Backup) error {
+
+	s.mu.Lock()
+	s.saves++
+	save := s.save
+	s.mu.Unlock()
+	return save(backup)
+}
+
+func (s *StoreMock) Close() error {
+	return nil
+}
+
+func (s *StoreMock) Saves() int {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.saves
+}
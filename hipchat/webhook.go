@@ -0,0 +1,104 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hipchat
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/HotelsDotCom/go-logger"
+	hc "github.com/tbruyelle/hipchat-go/hipchat"
+	"io/ioutil"
+	"net/http"
+)
+
+const maxWebhookPayload = 1 << 20
+
+type webhookPayload struct {
+	Event string `json:"event"`
+	Item  struct {
+		Message hc.Message `json:"message"`
+	} `json:"item"`
+}
+
+type webhookHandler struct {
+	rooms *Rooms
+}
+
+func (h webhookHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	if !h.validToken(r.URL.Query().Get("token")) {
+		logger.Errorf("webhook token is not valid, ignoring payload")
+		http.Error(w, "invalid token", http.StatusUnauthorized)
+		return
+	}
+
+	body, err := ioutil.ReadAll(http.MaxBytesReader(w, r.Body, maxWebhookPayload))
+	if err != nil {
+		http.Error(w, "cannot read payload", http.StatusBadRequest)
+		return
+	}
+
+	payload := webhookPayload{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		http.Error(w, "payload is not valid", http.StatusBadRequest)
+		return
+	}
+
+	if payload.Event != "room_message" {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	roomId := r.URL.Query().Get("room")
+	room := h.rooms.Get(roomId)
+	if room == nil {
+		logger.Errorf("received webhook message for room=%q which is not joined", roomId)
+		http.Error(w, "room not joined", http.StatusNotFound)
+		return
+	}
+
+	room.receive(toMessage(roomId, payload.Item.Message))
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// validToken checks the token the webhook was registered with. HipChat JWT webhook authentication needs an add-on
+// secret which API tokens do not have, so the token is part of the webhook url.
+func (h webhookHandler) validToken(token string) bool {
+
+	if h.rooms.webhookToken == "" || token == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(h.rooms.webhookToken)) == 1
+}
+
+// newWebhookToken returns random token, a new token is generated on every start so webhooks registered by previous
+// runs are rejected
+func newWebhookToken() (string, error) {
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
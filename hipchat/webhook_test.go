@@ -0,0 +1,201 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package hipchat
+
+import (
+	"github.com/HotelsDotCom/flyte-hipchat/bkp"
+	"github.com/stretchr/testify/assert"
+	"github.com/tbruyelle/hipchat-go/hipchat"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+const roomMessagePayload = `{
+	"event": "room_message",
+	"item": {
+		"message": {
+			"date": "2018-03-01T10:00:00.000000+00:00",
+			"from": {"id": 7, "mention_name": "jane", "name": "Jane Doe"},
+			"id": "msg-1",
+			"mentions": [],
+			"message": "hello flyte",
+			"type": "message"
+		},
+		"room": {"id": 123, "name": "ops"}
+	},
+	"webhook_id": 42
+}`
+
+func TestWebhookReceivesRoomMessage(t *testing.T) {
+
+	messages := make(chan Message, 1)
+	hc, path := newWebhookHipchat(messages)
+	defer os.Remove(path)
+	hc.JoinRoom("ops", nil)
+
+	resp := postWebhook(hc.WebhookHandler(), webhookPath(hc, "ops"), roomMessagePayload)
+
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+	select {
+	case m := <-messages:
+		assert.Equal(t, "msg-1", m.Id)
+		assert.Equal(t, "ops", m.RoomId)
+		assert.Equal(t, "hello flyte", m.Message)
+		assert.Equal(t, User{Id: 7, Name: "Jane Doe", MentionName: "jane"}, m.From)
+	case <-time.After(time.Second):
+		t.Error("message was not received")
+	}
+	assert.Equal(t, "msg-1", hc.rooms.Get("ops").lastMessageId)
+}
+
+func TestWebhookInvalidToken(t *testing.T) {
+
+	messages := make(chan Message, 1)
+	hc, path := newWebhookHipchat(messages)
+	defer os.Remove(path)
+	hc.JoinRoom("ops", nil)
+
+	other, otherPath := newWebhookHipchat(nil)
+	defer os.Remove(otherPath)
+
+	for _, url := range []string{"/webhook?room=ops", "/webhook?room=ops&token=", "/webhook?room=ops&token=abc", webhookPath(other, "ops")} {
+		resp := postWebhook(hc.WebhookHandler(), url, roomMessagePayload)
+		assert.Equal(t, http.StatusUnauthorized, resp.Code, "url %q", url)
+	}
+	assert.Equal(t, 0, len(messages))
+}
+
+func TestWebhookNotConfigured(t *testing.T) {
+
+	path := bkp.CreateBkpFile(createTestBkpDir(), "webhook-rooms.json")
+	defer os.Remove(path)
+	hc, _ := NewHipchat(bkp.NewFileStore(path), NewClientMock(), nil, Options{})
+
+	resp := postWebhook(hc.WebhookHandler(), "/webhook?room=ops&token=", roomMessagePayload)
+
+	assert.Equal(t, http.StatusUnauthorized, resp.Code)
+}
+
+func TestWebhookRoomNotJoined(t *testing.T) {
+
+	messages := make(chan Message, 1)
+	hc, path := newWebhookHipchat(messages)
+	defer os.Remove(path)
+
+	resp := postWebhook(hc.WebhookHandler(), webhookPath(hc, "ops"), roomMessagePayload)
+
+	assert.Equal(t, http.StatusNotFound, resp.Code)
+	assert.Equal(t, 0, len(messages))
+}
+
+func TestWebhookIgnoresOtherEvents(t *testing.T) {
+
+	messages := make(chan Message, 1)
+	hc, path := newWebhookHipchat(messages)
+	defer os.Remove(path)
+	hc.JoinRoom("ops", nil)
+
+	resp := postWebhook(hc.WebhookHandler(), webhookPath(hc, "ops"), `{"event": "room_enter"}`)
+
+	assert.Equal(t, http.StatusNoContent, resp.Code)
+	assert.Equal(t, 0, len(messages))
+}
+
+func TestWebhookInvalidPayload(t *testing.T) {
+
+	hc, path := newWebhookHipchat(nil)
+	defer os.Remove(path)
+
+	resp := postWebhook(hc.WebhookHandler(), webhookPath(hc, "ops"), `invalid payload`)
+
+	assert.Equal(t, http.StatusBadRequest, resp.Code)
+}
+
+func TestWebhookMethodNotAllowed(t *testing.T) {
+
+	hc, path := newWebhookHipchat(nil)
+	defer os.Remove(path)
+
+	resp := httptest.NewRecorder()
+	hc.WebhookHandler().ServeHTTP(resp, httptest.NewRequest("GET", "/webhook?room=ops", nil))
+
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.Code)
+}
+
+func TestJoinRoomRegistersWebhook(t *testing.T) {
+
+	client := NewClientMock()
+	path := bkp.CreateBkpFile(createTestBkpDir(), "webhook-rooms.json")
+	defer os.Remove(path)
+	hc, _ := NewHipchat(bkp.NewFileStore(path), client, nil, Options{Webhook: &WebhookOptions{Url: "https://flyte-hipchat.example.com/webhook"}})
+
+	hc.JoinRoom("ops room", nil)
+
+	assert.Equal(t, "ops room", client.CreateWebhookCall.roomID)
+	expected := "https://flyte-hipchat.example.com/webhook?room=ops+room&token=" + hc.rooms.webhookToken
+	assert.Equal(t, expected, client.CreateWebhookCall.webhook.URL)
+	assert.Equal(t, 64, len(hc.rooms.webhookToken))
+}
+
+func TestLeaveRoomDeletesWebhook(t *testing.T) {
+
+	client := NewClientMock()
+	client.createWebhook = func(string, *hipchat.CreateWebhookRequest) (string, error) { return "42", nil }
+	deleted := make(chan DeleteWebhookCall, 1)
+	client.deleteWebhook = func(roomID, webhookID string) error {
+		deleted <- DeleteWebhookCall{roomID: roomID, webhookID: webhookID}
+		return nil
+	}
+	path := bkp.CreateBkpFile(createTestBkpDir(), "webhook-rooms.json")
+	defer os.Remove(path)
+	hc, _ := NewHipchat(bkp.NewFileStore(path), client, nil, Options{Webhook: &WebhookOptions{Url: "https://flyte-hipchat.example.com/webhook"}})
+
+	hc.JoinRoom("ops", nil)
+	hc.LeaveRoom("ops")
+
+	// room is left asynchronously
+	select {
+	case call := <-deleted:
+		assert.Equal(t, DeleteWebhookCall{roomID: "ops", webhookID: "42"}, call)
+	case <-time.After(time.Second):
+		t.Error("webhook was not deleted")
+	}
+}
+
+func newWebhookHipchat(messages chan Message) (Hipchat, string) {
+
+	path := bkp.CreateBkpFile(createTestBkpDir(), "webhook-rooms.json")
+	opts := Options{Webhook: &WebhookOptions{Url: "https://flyte-hipchat.example.com/webhook"}}
+	hc, _ := NewHipchat(bkp.NewFileStore(path), NewClientMock(), messages, opts)
+	return hc, path
+}
+
+func webhookPath(hc Hipchat, roomId string) string {
+	return "/webhook?room=" + roomId + "&token=" + hc.rooms.webhookToken
+}
+
+func postWebhook(handler http.Handler, url, payload string) *httptest.ResponseRecorder {
+
+	req := httptest.NewRequest("POST", url, strings.NewReader(payload))
+	resp := httptest.NewRecorder()
+	handler.ServeHTTP(resp, req)
+	return resp
+}
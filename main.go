@@ -17,9 +17,13 @@ limitations under the License.
 package main
 
 import (
+	"context"
+	"net"
+	"net/http"
 	"net/url"
 	"os"
 	"os/signal"
+	"path/filepath"
 	api "github.com/HotelsDotCom/flyte-client/client"
 	"github.com/HotelsDotCom/flyte-client/flyte"
 	"github.com/HotelsDotCom/flyte-hipchat/bkp"
@@ -28,6 +32,7 @@ import (
 	"github.com/HotelsDotCom/flyte-hipchat/config"
 	"github.com/HotelsDotCom/flyte-hipchat/event"
 	"github.com/HotelsDotCom/flyte-hipchat/hipchat"
+	"github.com/HotelsDotCom/flyte-hipchat/templates"
 	"github.com/HotelsDotCom/go-logger"
 	"syscall"
 	"time"
@@ -36,12 +41,19 @@ import (
 func main() {
 
 	messages := make(chan hipchat.Message)
-	hc := initHipchat(messages)
+	webhookServer := newWebhookServer()
+	hc := initHipchat(messages, webhookServer)
 
-	p := flyte.NewPack(getPackDef(hc), api.NewClient(config.ApiHost(), 10*time.Second))
+	p := flyte.NewPack(getPackDef(hc, loadTemplates()), api.NewClient(config.ApiHost(), 10*time.Second))
 	p.Start()
 
-	event.HandleReceivedMessages(p, messages)
+	event.HandleReceivedMessages(p, messages, packUser(hc), config.ChatCommandPrefixes())
+
+	if users := config.PrivateChatUsers(); len(users) != 0 {
+		privateMessages := make(chan hipchat.ReceivedPrivateMessage)
+		hc.MonitorPrivateChats(users, privateMessages)
+		event.HandleReceivedPrivateMessages(p, privateMessages)
+	}
 	logger.Infof("joined rooms=%v", hc.JoinedRoomIds())
 
 	// block until we get an exit causing signal
@@ -50,28 +62,54 @@ func main() {
 	select {
 	case <-signalCh:
 		logger.Info("received interrupt, shutting down...")
+		if webhookServer != nil {
+			// wait for in-flight webhook messages, they must be handed over before messages channel is closed
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+			if err := webhookServer.Shutdown(ctx); err != nil {
+				logger.Errorf("cannot shut down webhook server: %v", err)
+			}
+			cancel()
+		}
 		hc.Shutdown()
 		logger.Info("shut down")
 	}
 }
 
-func initHipchat(messages chan hipchat.Message) hipchat.Hipchat {
+func initHipchat(messages chan hipchat.Message, webhookServer *http.Server) hipchat.Hipchat {
 
-	hcClient := client.NewHipChatClient(config.HipchatAuthTokens())
+	hcClient, err := client.NewHipChatClient(config.HipchatAuthTokens(), client.Options{
+		RateLimitInterval:    config.RateLimitInterval(),
+		RateLimitMinInterval: config.RateLimitMinInterval(),
+		ApiURL:               config.HipchatApiUrl(),
+		TokenApiURLs:         config.HipchatTokenApiUrls(),
+		CAFile:               config.HipchatCaFile(),
+		InsecureSkipVerify:   config.HipchatTlsSkipVerify(),
+	})
+	if err != nil {
+		logger.Fatalf("cannot initialize hipchat client: %v", err)
+	}
 
 	bkpDir := config.BkpDir()
 	if bkpDir == "" {
 		bkpDir = bkp.CreateDefaultBkpDir()
 	}
 
-	bkpFile := bkp.CreateBkpFile(bkpDir, "rooms.json")
-	hc, err := hipchat.NewHipchat(bkpFile, hcClient, messages)
+	store := roomStore(bkpDir)
+	opts := hipchat.Options{MaxReplay: config.MaxReplayMessages(), Broadcast: broadcastOptions()}
+	if webhookServer != nil {
+		opts.Webhook = &hipchat.WebhookOptions{
+			Url:    config.WebhookUrl(),
+			Listen: func(handler http.Handler) error { return listenForWebhooks(webhookServer, handler) },
+		}
+	}
+
+	hc, err := hipchat.NewHipchat(store, hcClient, messages, opts)
 	if err != nil {
 		logger.Fatalf("cannot initialize pack: %v", err)
 	}
 
 	if defaultRoom := config.DefaultRoom(); defaultRoom != "" {
-		hc.JoinRoom(defaultRoom)
+		hc.JoinDefaultRoom(defaultRoom)
 	}
 
 	if len(hc.JoinedRoomIds()) == 0 {
@@ -80,7 +118,84 @@ func initHipchat(messages chan hipchat.Message) hipchat.Hipchat {
 	return hc
 }
 
-func getPackDef(hc hipchat.Hipchat) flyte.PackDef {
+// packUser returns the pack's HipChat user, BotMentioned events are not sent if the user cannot be found
+func packUser(hc hipchat.Hipchat) hipchat.User {
+
+	user, err := hc.PackUser()
+	if err != nil {
+		logger.Errorf("cannot get pack user, BotMentioned events are disabled: %v", err)
+		return hipchat.User{}
+	}
+	logger.Infof("pack user=%q", user.MentionName)
+	return user
+}
+
+// broadcastOptions caps broadcast workers at the number of tokens, more workers would only wait for a free token
+func broadcastOptions() hipchat.BroadcastOptions {
+
+	workers := config.BroadcastWorkers()
+	if tokens := len(config.HipchatAuthTokens()); tokens < workers {
+		workers = tokens
+	}
+	return hipchat.BroadcastOptions{Workers: workers, Timeout: config.BroadcastTimeout()}
+}
+
+func roomStore(bkpDir string) bkp.RoomStore {
+
+	if config.BkpStore() == config.BoltBkpStore {
+		store, err := bkp.NewBoltStore(filepath.Join(bkpDir, "rooms.db"))
+		if err != nil {
+			logger.Fatalf("cannot initialize rooms store: %v", err)
+		}
+		return store
+	}
+	return bkp.NewFileStore(bkp.CreateBkpFile(bkpDir, "rooms.json"))
+}
+
+// loadTemplates loads named message templates, no named templates are available if TEMPLATES_DIR is not set
+func loadTemplates() *templates.Templates {
+
+	dir := config.TemplatesDir()
+	if dir == "" {
+		return templates.New()
+	}
+
+	tmpl, err := templates.Load(dir)
+	if err != nil {
+		logger.Fatalf("cannot load templates from %s: %v", dir, err)
+	}
+	return tmpl
+}
+
+// newWebhookServer returns server for room messages sent by HipChat webhooks, returns nil if webhooks are not configured
+func newWebhookServer() *http.Server {
+
+	if config.WebhookUrl() == "" {
+		return nil
+	}
+	return &http.Server{Addr: config.WebhookListenAddr()}
+}
+
+// listenForWebhooks starts serving webhooks, the address is bound before it returns so callbacks for webhooks
+// registered afterwards are not lost
+func listenForWebhooks(server *http.Server, handler http.Handler) error {
+
+	listener, err := net.Listen("tcp", server.Addr)
+	if err != nil {
+		return err
+	}
+
+	server.Handler = handler
+	go func() {
+		logger.Infof("listening for webhooks on %s", server.Addr)
+		if err := server.Serve(listener); err != nil && err != http.ErrServerClosed {
+			logger.Fatalf("webhook server failed: %v", err)
+		}
+	}()
+	return nil
+}
+
+func getPackDef(hc hipchat.Hipchat, tmpl *templates.Templates) flyte.PackDef {
 
 	helpUrl, err := url.Parse("http://github.com/HotelsDotCom/flyte-hipchat/browse/README.md")
 	if err != nil {
@@ -91,14 +206,27 @@ func getPackDef(hc hipchat.Hipchat) flyte.PackDef {
 		Name:    "HipChat",
 		HelpURL: helpUrl,
 		Commands: []flyte.Command{
-			command.SendMessageCommand(hc),
-			command.SendNotificationCommand(hc),
-			command.BroadcastCommand(hc),
+			command.SendMessageCommand(hc, tmpl),
+			command.SendNotificationCommand(hc, tmpl),
+			command.SendPrivateMessageCommand(hc),
+			command.BroadcastCommand(hc, tmpl),
+			command.BroadcastNotificationCommand(hc, tmpl),
 			command.JoinCommand(hc),
 			command.LeaveCommand(hc),
+			command.GetHistoryCommand(hc),
+			command.SetRoomTopicCommand(hc),
+			command.CreateRoomCommand(hc),
+			command.ArchiveRoomCommand(hc),
+			command.InviteUserCommand(hc),
+			command.RemoveMemberCommand(hc),
+			command.ShareFileCommand(hc),
+			command.ShareLinkCommand(hc),
 		},
 		EventDefs: []flyte.EventDef{
 			{Name: "ReceivedMessage"},
+			{Name: "BotMentioned"},
+			{Name: "ChatCommandReceived"},
+			{Name: "ReceivedPrivateMessage"},
 		},
 	}
 }
@@ -0,0 +1,114 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templates
+
+import (
+	"bytes"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	texttemplate "text/template"
+)
+
+// FileExt is extension of files with named templates
+const FileExt = ".tmpl"
+
+// Templates renders message templates, message templates can use named templates e.g. {{template "alert" .}}
+type Templates struct {
+	text *texttemplate.Template
+	html *htmltemplate.Template
+}
+
+// New returns templates without any named templates
+func New() *Templates {
+
+	return &Templates{
+		text: texttemplate.New("").Option("missingkey=error"),
+		html: htmltemplate.New("").Option("missingkey=error"),
+	}
+}
+
+// Load parses named templates from *.tmpl files in dir, template name is the file name without extension
+func Load(dir string) (*Templates, error) {
+
+	t := New()
+	files, err := filepath.Glob(filepath.Join(dir, "*"+FileExt))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		b, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		name := strings.TrimSuffix(filepath.Base(file), FileExt)
+		if _, err := t.text.New(name).Parse(string(b)); err != nil {
+			return nil, err
+		}
+		if _, err := t.html.New(name).Parse(string(b)); err != nil {
+			return nil, err
+		}
+	}
+	return t, nil
+}
+
+// Render executes message template with data, html templates escape data
+func (t *Templates) Render(message string, html bool, data interface{}) (string, error) {
+
+	if html {
+		return t.renderHtml(message, data)
+	}
+	return t.renderText(message, data)
+}
+
+func (t *Templates) renderText(message string, data interface{}) (string, error) {
+
+	// named templates are cloned so messages do not add templates to the shared set
+	tmpl, err := t.text.Clone()
+	if err != nil {
+		return "", err
+	}
+	if tmpl, err = tmpl.New("message").Parse(message); err != nil {
+		return "", err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func (t *Templates) renderHtml(message string, data interface{}) (string, error) {
+
+	tmpl, err := t.html.Clone()
+	if err != nil {
+		return "", err
+	}
+	if tmpl, err = tmpl.New("message").Parse(message); err != nil {
+		return "", err
+	}
+
+	buf := &bytes.Buffer{}
+	if err := tmpl.Execute(buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
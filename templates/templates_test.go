@@ -0,0 +1,116 @@
+/*
+Copyright (C) 2018 Expedia Group.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package templates
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRenderText(t *testing.T) {
+
+	message, err := New().Render(`{{.service}} is {{.status}} <b>`, false, map[string]interface{}{"service": "api", "status": "down"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "api is down <b>", message)
+}
+
+func TestRenderHtmlEscapesData(t *testing.T) {
+
+	message, err := New().Render(`<b>{{.service}}</b> is down`, true, map[string]interface{}{"service": "<i>api</i>"})
+
+	assert.Nil(t, err)
+	assert.Equal(t, "<b>&lt;i&gt;api&lt;/i&gt;</b> is down", message)
+}
+
+func TestRenderMissingKey(t *testing.T) {
+
+	_, err := New().Render(`{{.service}} is down`, false, map[string]interface{}{})
+	assert.NotNil(t, err)
+}
+
+func TestRenderInvalidTemplate(t *testing.T) {
+
+	_, err := New().Render(`{{.service`, false, nil)
+	assert.NotNil(t, err)
+}
+
+func TestLoadNamedTemplates(t *testing.T) {
+
+	dir := createTestDir(t)
+	defer os.RemoveAll(dir)
+	writeTemplate(t, dir, "alert.tmpl", `ALERT {{.service}}: {{template "status" .}}`)
+	writeTemplate(t, dir, "status.tmpl", `{{.status}}`)
+	writeTemplate(t, dir, "readme.txt", `{{not a template`)
+
+	tmpl, err := Load(dir)
+	assert.Nil(t, err)
+
+	data := map[string]interface{}{"service": "api", "status": "<down>"}
+	text, err := tmpl.Render(`{{template "alert" .}}`, false, data)
+	assert.Nil(t, err)
+	assert.Equal(t, "ALERT api: <down>", text)
+
+	html, err := tmpl.Render(`<p>{{template "alert" .}}</p>`, true, data)
+	assert.Nil(t, err)
+	assert.Equal(t, "<p>ALERT api: &lt;down&gt;</p>", html)
+}
+
+func TestRenderDoesNotChangeNamedTemplates(t *testing.T) {
+
+	dir := createTestDir(t)
+	defer os.RemoveAll(dir)
+	writeTemplate(t, dir, "alert.tmpl", `ALERT`)
+
+	tmpl, _ := Load(dir)
+	tmpl.Render(`{{define "alert"}}changed{{end}}`, true, nil)
+	tmpl.Render(`{{define "alert"}}changed{{end}}`, false, nil)
+
+	html, _ := tmpl.Render(`{{template "alert"}}`, true, nil)
+	text, _ := tmpl.Render(`{{template "alert"}}`, false, nil)
+	assert.Equal(t, "ALERT", html)
+	assert.Equal(t, "ALERT", text)
+}
+
+func TestLoadInvalidTemplate(t *testing.T) {
+
+	dir := createTestDir(t)
+	defer os.RemoveAll(dir)
+	writeTemplate(t, dir, "alert.tmpl", `{{.service`)
+
+	_, err := Load(dir)
+	assert.NotNil(t, err)
+}
+
+func createTestDir(t *testing.T) string {
+
+	dir, err := ioutil.TempDir("", "templates")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func writeTemplate(t *testing.T, dir, name, content string) {
+
+	if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}